@@ -1,3 +1,165 @@
 package main
 
-func main() {}
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/configs"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/encryption"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/facades"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/logger"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/workers"
+)
+
+func main() {
+	err := command(os.Args[1:])
+	if err != nil {
+		panic(err)
+	}
+}
+
+func command(args []string) error {
+	config, err := parseFlags(args)
+	if err != nil {
+		return err
+	}
+
+	err = logger.Initialize(config.LogLevel)
+	if err != nil {
+		return err
+	}
+
+	worker, closeAgent, err := newAgent(config)
+	if err != nil {
+		return err
+	}
+	defer closeAgent()
+
+	err = runAgent(
+		context.Background(),
+		worker,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func parseFlags(args []string) (*configs.AgentConfig, error) {
+	return configs.LoadAgentConfig(args, os.LookupEnv)
+}
+
+// newAgent wires the collectors and the transport selected in config. The
+// returned function releases the transport once the agent has stopped.
+func newAgent(
+	config *configs.AgentConfig,
+) (*workers.MetricAgentWorker, func() error, error) {
+	metricFacade, closeFacade, err := newMetricFacade(config)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pollInterval := time.Duration(config.PollInterval) * time.Second
+
+	opts := []workers.MetricAgentWorkerOpt{
+		workers.WithMetricAgentUpdater(metricFacade),
+		workers.WithMetricAgentCollector(workers.CollectRuntimeMetrics, pollInterval),
+		workers.WithMetricAgentReportInterval(time.Duration(config.ReportInterval) * time.Second),
+		workers.WithMetricAgentRateLimit(config.RateLimit),
+	}
+
+	systemCollector, err := workers.NewSystemMetricsCollector()
+	if err != nil {
+		logger.Log.Warnw("system metrics are disabled", "error", err)
+	} else {
+		opts = append(opts, workers.WithMetricAgentCollector(systemCollector.Collect, pollInterval))
+	}
+
+	worker := workers.NewMetricAgentWorker(opts...)
+
+	return worker, closeFacade, nil
+}
+
+func newMetricFacade(
+	config *configs.AgentConfig,
+) (workers.MetricUpdater, func() error, error) {
+	if config.Transport == configs.TransportGRPC || config.Transport == configs.TransportGRPCStream {
+		grpcOpts := []facades.MetricGRPCFacadeOpt{
+			facades.WithMetricGRPCFacadeServerAddress(config.Address),
+			facades.WithMetricGRPCFacadeKey(config.Key),
+			facades.WithMetricGRPCFacadeRetryDelays(config.RetryDelays...),
+		}
+		// gRPC has no encrypted bodies, the connection is encrypted instead
+		// and the server is authenticated by its public key.
+		if config.CryptoKey != "" {
+			publicKey, err := encryption.LoadPublicKey(config.CryptoKey)
+			if err != nil {
+				return nil, nil, err
+			}
+			grpcOpts = append(grpcOpts, facades.WithMetricGRPCFacadeTLS(encryption.ClientTLSConfig(publicKey)))
+		}
+		if config.Transport == configs.TransportGRPCStream {
+			grpcOpts = append(grpcOpts, facades.WithMetricGRPCFacadeStreaming())
+		}
+
+		metricFacade, err := facades.NewMetricGRPCFacade(grpcOpts...)
+		if err != nil {
+			return nil, nil, err
+		}
+		return metricFacade, metricFacade.Close, nil
+	}
+
+	facadeOpts := []facades.MetricHTTPFacadeOpt{
+		facades.WithMetricFacadeServerAddress(config.Address),
+		facades.WithMetricFacadeKey(config.Key),
+		facades.WithMetricFacadeRetryDelays(config.RetryDelays...),
+	}
+
+	if config.CryptoKey != "" {
+		publicKey, err := encryption.LoadPublicKey(config.CryptoKey)
+		if err != nil {
+			return nil, nil, err
+		}
+		facadeOpts = append(facadeOpts, facades.WithMetricFacadePublicKey(publicKey))
+	}
+
+	return facades.NewMetricHTTPFacade(facadeOpts...), func() error { return nil }, nil
+}
+
+type agentWorker interface {
+	Start(ctx context.Context) error
+}
+
+func runAgent(
+	ctx context.Context,
+	worker agentWorker,
+) error {
+	ctx, stop := signal.NotifyContext(
+		ctx,
+		syscall.SIGINT,
+		syscall.SIGTERM,
+		syscall.SIGQUIT,
+	)
+	defer stop()
+
+	errChan := make(chan error, 1)
+
+	go func() {
+		logger.Log.Infow("starting agent")
+		errChan <- worker.Start(ctx)
+		close(errChan)
+	}()
+
+	// On cancellation the worker drains its pending reports within its own
+	// shutdown timeout before returning.
+	err := <-errChan
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/configs"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/pb"
+)
+
+type workerFunc func(ctx context.Context) error
+
+func (f workerFunc) Start(ctx context.Context) error {
+	return f(ctx)
+}
+
+func TestRunAgent_ShutdownOnContextCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan error)
+	go func() {
+		done <- runAgent(ctx, workerFunc(func(ctx context.Context) error {
+			<-ctx.Done()
+			return nil
+		}))
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+
+	cancel()
+
+	err := <-done
+	require.NoError(t, err)
+}
+
+func TestRunAgent_WorkerReturnsError(t *testing.T) {
+	err := runAgent(context.Background(), workerFunc(func(ctx context.Context) error {
+		return errors.New("worker failed")
+	}))
+	require.Error(t, err)
+}
+
+func TestParseFlags(t *testing.T) {
+	config, err := parseFlags([]string{"-a", "localhost:9090", "-p", "1", "-r", "3"})
+	require.NoError(t, err)
+
+	assert.Equal(t, "localhost:9090", config.Address)
+	assert.Equal(t, 1, config.PollInterval)
+	assert.Equal(t, 3, config.ReportInterval)
+
+	_, err = parseFlags([]string{"-p", "never"})
+	require.Error(t, err)
+}
+
+func TestCommand_InvalidFlags(t *testing.T) {
+	require.Error(t, command([]string{"-a", "no-port"}))
+}
+
+func TestNewAgent_ReportsToServer(t *testing.T) {
+	var requests atomic.Int64
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	worker, closeAgent, err := newAgent(configs.NewAgentConfig(
+		configs.WithAgentAddress(srv.URL),
+		configs.WithAgentPollInterval(1),
+		configs.WithAgentReportInterval(1),
+	))
+	require.NoError(t, err)
+	defer closeAgent()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1500*time.Millisecond)
+	defer cancel()
+
+	err = runAgent(ctx, worker)
+	require.NoError(t, err)
+
+	assert.Positive(t, requests.Load())
+}
+
+type countingMetricsServer struct {
+	pb.UnimplementedMetricsServiceServer
+	batches atomic.Int64
+}
+
+func (s *countingMetricsServer) UpdateBatch(ctx context.Context, req *pb.UpdateBatchRequest) (*pb.UpdateBatchResponse, error) {
+	s.batches.Add(1)
+	return &pb.UpdateBatchResponse{Metrics: req.GetMetrics()}, nil
+}
+
+func TestNewAgent_ReportsOverGRPC(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	stub := &countingMetricsServer{}
+	srv := grpc.NewServer()
+	pb.RegisterMetricsServiceServer(srv, stub)
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	worker, closeAgent, err := newAgent(configs.NewAgentConfig(
+		configs.WithAgentAddress(lis.Addr().String()),
+		configs.WithAgentPollInterval(1),
+		configs.WithAgentReportInterval(1),
+		configs.WithAgentTransport(configs.TransportGRPC),
+	))
+	require.NoError(t, err)
+	defer closeAgent()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1500*time.Millisecond)
+	defer cancel()
+
+	require.NoError(t, runAgent(ctx, worker))
+	assert.Positive(t, stub.batches.Load())
+}
+
+func TestNewAgent_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *configs.AgentConfig
+	}{
+		{
+			name:   "missing crypto key",
+			config: configs.NewAgentConfig(configs.WithAgentCryptoKey(filepath.Join(t.TempDir(), "missing.pem"))),
+		},
+		{
+			name: "missing crypto key with grpc transport",
+			config: configs.NewAgentConfig(
+				configs.WithAgentTransport(configs.TransportGRPC),
+				configs.WithAgentCryptoKey(filepath.Join(t.TempDir(), "missing.pem")),
+			),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := newAgent(tt.config)
+			require.Error(t, err)
+		})
+	}
+}
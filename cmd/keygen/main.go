@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/encryption"
+)
+
+func main() {
+	err := command(os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// command generates an RSA keypair: the private key is passed to the server
+// with -crypto-key, the public key to every agent.
+func command(args []string) error {
+	fs := flag.NewFlagSet("keygen", flag.ContinueOnError)
+	bits := fs.Int("bits", 4096, "RSA key size in bits")
+	privatePath := fs.String("private", "private.pem", "output path of the private key")
+	publicPath := fs.String("public", "public.pem", "output path of the public key")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *bits < 2048 {
+		return errors.New("key size must be at least 2048 bits")
+	}
+
+	key, err := encryption.GenerateKey(*bits)
+	if err != nil {
+		return err
+	}
+
+	privatePEM, err := encryption.EncodePrivateKey(key)
+	if err != nil {
+		return err
+	}
+
+	publicPEM, err := encryption.EncodePublicKey(&key.PublicKey)
+	if err != nil {
+		return err
+	}
+
+	// O_EXCL keeps an existing keypair from being overwritten by accident.
+	if err := writeNewFile(*privatePath, privatePEM, 0o600); err != nil {
+		return err
+	}
+
+	if err := writeNewFile(*publicPath, publicPEM, 0o644); err != nil {
+		os.Remove(*privatePath)
+		return err
+	}
+
+	return nil
+}
+
+func writeNewFile(path string, data []byte, perm os.FileMode) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/encryption"
+)
+
+func TestCommand(t *testing.T) {
+	dir := t.TempDir()
+	privatePath := filepath.Join(dir, "private.pem")
+	publicPath := filepath.Join(dir, "public.pem")
+	args := []string{"-bits", "2048", "-private", privatePath, "-public", publicPath}
+
+	require.NoError(t, command(args))
+
+	privateKey, err := encryption.LoadPrivateKey(privatePath)
+	require.NoError(t, err)
+	publicKey, err := encryption.LoadPublicKey(publicPath)
+	require.NoError(t, err)
+	assert.True(t, privateKey.PublicKey.Equal(publicKey))
+
+	info, err := os.Stat(privatePath)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	assert.Error(t, command(args), "existing keys are not overwritten")
+}
+
+func TestCommand_Errors(t *testing.T) {
+	assert.Error(t, command([]string{"-bits", "1024"}))
+	assert.Error(t, command([]string{"-unknown"}))
+}
@@ -2,31 +2,65 @@ package main
 
 import (
 	"context"
+	"crypto/rsa"
+	"database/sql"
+	"errors"
+	"net"
 	"net/http"
+	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/configs"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/configs/db"
 	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/configs/memory"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/encryption"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/graphite"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/grpchandlers"
 	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/handlers"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/interceptors"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/logger"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/middlewares"
 	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/models"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/pb"
 	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/repositories"
 	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/services"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/workers"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
 func main() {
-	err := command()
+	err := command(os.Args[1:])
 	if err != nil {
 		panic(err)
 	}
 }
 
-func command() error {
-	config := parseFlags()
+func command(args []string) error {
+	config, err := parseFlags(args)
+	if err != nil {
+		return err
+	}
 
-	srv, err := newServer(config)
+	err = logger.Initialize(config.LogLevel)
+	if err != nil {
+		return err
+	}
+
+	var conn *sql.DB
+	if config.DatabaseDSN != "" {
+		conn, err = db.NewDB(config.DatabaseDSN)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+	}
+
+	srv, grpcSrv, workers, err := newServer(config, conn)
 	if err != nil {
 		return err
 	}
@@ -34,6 +68,8 @@ func command() error {
 	err = runServer(
 		context.Background(),
 		srv,
+		grpcSrv,
+		workers...,
 	)
 	if err != nil {
 		return err
@@ -42,39 +78,238 @@ func command() error {
 	return nil
 }
 
-func parseFlags() *configs.ServerConfig {
-	return configs.NewServerConfig()
+func parseFlags(args []string) (*configs.ServerConfig, error) {
+	return configs.LoadServerConfig(args, os.LookupEnv)
+}
+
+// worker is a background task that runs alongside the servers until its
+// context is cancelled.
+type worker interface {
+	Start(ctx context.Context) error
 }
 
+// newServer wires the storage, services and handlers. A non-nil conn selects
+// the PostgreSQL storage, otherwise metrics are kept in memory and optionally
+// persisted to the snapshot file. The gRPC server is nil unless a gRPC
+// address is configured.
 func newServer(
 	config *configs.ServerConfig,
-) (*http.Server, error) {
-	memStorage := memory.NewMemory[models.MetricID, models.Metrics]()
+	conn *sql.DB,
+) (*http.Server, *grpcServer, []worker, error) {
+	var (
+		metricsGetter     services.Getter
+		metricsSaver      services.Saver
+		metricsLister     handlers.MetricLister
+		backgroundWorkers []worker
+	)
 
-	metricsMemoryGetRepository := repositories.NewMetricsMemoryGetRepository(memStorage)
-	metricsMemorySaveRepository := repositories.NewMetricsMemorySaveRepository(memStorage)
+	var trustedSubnet *net.IPNet
+	if config.TrustedSubnet != "" {
+		var err error
+		_, trustedSubnet, err = net.ParseCIDR(config.TrustedSubnet)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
 
-	metricUpdateService := services.NewMetricUpdateService(
-		services.WithMetricUpdateGetter(metricsMemoryGetRepository),
-		services.WithMetricUpdateSaver(metricsMemorySaveRepository),
-	)
+	// StatsD packets and Graphite lines are neither signed nor encrypted, the
+	// listeners would accept in the clear the updates that -k and -crypto-key
+	// protect on HTTP.
+	if config.Key != "" || config.CryptoKey != "" {
+		if config.StatsDAddress != "" {
+			return nil, nil, nil, errors.New("statsd listener is not supported with a key or crypto key")
+		}
+		if config.GraphiteAddress != "" {
+			return nil, nil, nil, errors.New("graphite listener is not supported with a key or crypto key")
+		}
+	}
+
+	pingHandlerOpts := []handlers.PingHandlerOption{}
+
+	if conn != nil {
+		dbOpts := []repositories.DBRepositoryOpt{repositories.WithDBRetryDelays(config.RetryDelays...)}
+
+		metricsGetter = repositories.NewMetricsDBGetRepository(conn, dbOpts...)
+		metricsSaver = repositories.NewMetricsDBSaveRepository(conn, dbOpts...)
+		metricsLister = repositories.NewMetricsDBListRepository(conn, dbOpts...)
+
+		pingHandlerOpts = append(pingHandlerOpts, handlers.WithDBPinger(conn))
+	} else {
+		memStorage := memory.NewMemory[models.MetricID, models.Metrics]()
+
+		metricsGetter = repositories.NewMetricsMemoryGetRepository(memStorage)
+		metricsSaver = repositories.NewMetricsMemorySaveRepository(memStorage)
+		metricsLister = repositories.NewMetricsMemoryListRepository(memStorage)
+
+		if config.FileStoragePath != "" {
+			metricsFileRepository := repositories.NewMetricsFileRepository(memStorage, config.FileStoragePath)
+
+			if config.Restore {
+				err := metricsFileRepository.Restore(context.Background())
+				if err != nil {
+					return nil, nil, nil, err
+				}
+			}
+
+			if config.StoreInterval == 0 {
+				metricsSaver = repositories.NewMetricsFileSaveRepository(memStorage, config.FileStoragePath)
+			}
+
+			backgroundWorkers = append(backgroundWorkers, workers.NewMetricSnapshotWorker(
+				workers.WithMetricSnapshotDumper(metricsFileRepository),
+				workers.WithMetricSnapshotInterval(time.Duration(config.StoreInterval)*time.Second),
+			))
+		}
+	}
+
+	updateOpts := []services.MetricUpdateOpt{
+		services.WithMetricUpdateGetter(metricsGetter),
+		services.WithMetricUpdateSaver(metricsSaver),
+	}
+	if len(config.HistogramBuckets) > 0 {
+		updateOpts = append(updateOpts, services.WithMetricUpdateHistogramBuckets(config.HistogramBuckets...))
+	}
+	metricUpdateService := services.NewMetricUpdateService(updateOpts...)
+
+	if config.StatsDAddress != "" {
+		backgroundWorkers = append(backgroundWorkers, workers.NewMetricStatsDWorker(
+			workers.WithMetricStatsDAddress(config.StatsDAddress),
+			workers.WithMetricStatsDUpdater(metricUpdateService),
+			workers.WithMetricStatsDGetter(metricsGetter),
+			workers.WithMetricStatsDTrustedSubnet(trustedSubnet),
+		))
+	}
+
+	if config.GraphiteAddress != "" {
+		rules, err := graphite.NewRules(config.GraphiteCounterPrefixes...)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		backgroundWorkers = append(backgroundWorkers, workers.NewMetricGraphiteWorker(
+			workers.WithMetricGraphiteAddress(config.GraphiteAddress),
+			workers.WithMetricGraphiteRules(rules),
+			workers.WithMetricGraphiteUpdater(metricUpdateService),
+			workers.WithMetricGraphiteTrustedSubnet(trustedSubnet),
+		))
+	}
 
 	metricUpdateHandler := handlers.NewMetricUpdatePathHandler(
 		handlers.WithMetricUpdaterPath(metricUpdateService),
 	)
 
+	metricUpdateBodyHandler := handlers.NewMetricUpdateBodyHandler(
+		handlers.WithMetricUpdaterBody(metricUpdateService),
+	)
+
+	metricUpdatesBodyHandler := handlers.NewMetricUpdatesBodyHandler(
+		handlers.WithMetricUpdaterBatch(metricUpdateService),
+	)
+
+	metricInfluxWriteHandler := handlers.NewMetricInfluxWriteHandler(
+		handlers.WithMetricUpdaterInflux(metricUpdateService),
+	)
+
+	metricGetHandler := handlers.NewMetricGetPathHandler(
+		handlers.WithMetricGetterPath(metricsGetter),
+	)
+
+	metricGetBodyHandler := handlers.NewMetricGetBodyHandler(
+		handlers.WithMetricGetterBody(metricsGetter),
+	)
+
+	metricListHandler := handlers.NewMetricListHTMLHandler(
+		handlers.WithMetricListerHTML(metricsLister),
+	)
+
+	metricPrometheusHandler := handlers.NewMetricPrometheusHandler(
+		handlers.WithMetricListerPrometheus(metricsLister),
+	)
+
+	pingHandler := handlers.NewPingHandler(pingHandlerOpts...)
+
+	var (
+		privateKey *rsa.PrivateKey
+		grpcOpts   []grpc.ServerOption
+	)
+	if config.CryptoKey != "" {
+		var err error
+		privateKey, err = encryption.LoadPrivateKey(config.CryptoKey)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		// Payload encryption is defined for HTTP bodies only, gRPC encrypts
+		// the connection with a certificate for the same key instead.
+		if config.GRPCAddress != "" {
+			tlsConfig, err := encryption.ServerTLSConfig(privateKey)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			grpcOpts = append(grpcOpts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+		}
+	}
+
 	router := chi.NewRouter()
+	router.Use(middlewares.LoggingMiddleware)
+	router.Use(middlewares.DecryptMiddleware(privateKey))
+	router.Use(middlewares.GzipMiddleware)
+	router.Use(middlewares.HashMiddleware(config.Key))
 
-	metricUpdateHandler.RegisterRoute(router)
+	router.Group(func(r chi.Router) {
+		r.Use(middlewares.TrustedSubnetMiddleware(trustedSubnet))
+
+		metricUpdateHandler.RegisterRoute(r)
+		metricUpdateBodyHandler.RegisterRoute(r)
+		metricUpdatesBodyHandler.RegisterRoute(r)
+		metricInfluxWriteHandler.RegisterRoute(r)
+	})
+	metricGetHandler.RegisterRoute(router)
+	metricGetBodyHandler.RegisterRoute(router)
+	metricListHandler.RegisterRoute(router)
+	metricPrometheusHandler.RegisterRoute(router)
+	pingHandler.RegisterRoute(router)
 
 	srv := &http.Server{Addr: config.Address, Handler: router}
 
-	return srv, nil
+	var grpcSrv *grpcServer
+	if config.GRPCAddress != "" {
+		grpcOpts = append(grpcOpts,
+			grpc.ChainUnaryInterceptor(
+				interceptors.LoggingInterceptor,
+				interceptors.TrustedSubnetInterceptor(trustedSubnet,
+					pb.MetricsService_Update_FullMethodName,
+					pb.MetricsService_UpdateBatch_FullMethodName,
+				),
+				interceptors.HashInterceptor(config.Key),
+			),
+			grpc.ChainStreamInterceptor(
+				interceptors.LoggingStreamInterceptor,
+				interceptors.TrustedSubnetStreamInterceptor(trustedSubnet,
+					pb.MetricsService_StreamUpdates_FullMethodName,
+				),
+				interceptors.HashStreamInterceptor(config.Key),
+			),
+		)
+		grpcSrv = &grpcServer{
+			addr:   config.GRPCAddress,
+			Server: grpc.NewServer(grpcOpts...),
+		}
+
+		grpchandlers.NewMetricGRPCHandler(
+			grpchandlers.WithMetricGRPCUpdater(metricUpdateService),
+			grpchandlers.WithMetricGRPCGetter(metricsGetter),
+		).Register(grpcSrv)
+	}
+
+	return srv, grpcSrv, backgroundWorkers, nil
 }
 
 func runServer(
 	ctx context.Context,
 	srv *http.Server,
+	grpcSrv *grpcServer,
+	backgroundWorkers ...worker,
 ) error {
 	ctx, stop := signal.NotifyContext(
 		ctx,
@@ -84,18 +319,60 @@ func runServer(
 	)
 	defer stop()
 
-	errChan := make(chan error, 1)
+	var grpcListener net.Listener
+	if grpcSrv != nil {
+		var err error
+		grpcListener, err = net.Listen("tcp", grpcSrv.addr)
+		if err != nil {
+			return err
+		}
+	}
+
+	workersCtx, stopWorkers := context.WithCancel(ctx)
+	defer stopWorkers()
+
+	var wg sync.WaitGroup
+	workersErrChan := make(chan error, len(backgroundWorkers))
+
+	for _, w := range backgroundWorkers {
+		wg.Add(1)
+		go func(w worker) {
+			defer wg.Done()
+			err := w.Start(workersCtx)
+			if err != nil {
+				workersErrChan <- err
+			}
+		}(w)
+	}
+
+	// Workers perform their final flush once their context is cancelled, so
+	// they are stopped after the servers have stopped accepting requests.
+	waitWorkers := func() error {
+		stopWorkers()
+		wg.Wait()
+		close(workersErrChan)
+		return <-workersErrChan
+	}
+
+	errChan := make(chan error, 2)
 
 	go func() {
+		logger.Log.Infow("starting server", "address", srv.Addr)
 		err := srv.ListenAndServe()
-		if err != nil && err != http.ErrServerClosed {
-			errChan <- err
+		if err == http.ErrServerClosed {
+			err = nil
 		}
-		close(errChan)
+		errChan <- err
 	}()
 
-	select {
-	case <-ctx.Done():
+	if grpcSrv != nil {
+		logger.Log.Infow("starting gRPC server", "address", grpcListener.Addr().String())
+		go func() {
+			errChan <- grpcSrv.Serve(grpcListener)
+		}()
+	}
+
+	shutdown := func() error {
 		shutdownCtx, cancel := context.WithTimeout(
 			context.Background(),
 			5*time.Second,
@@ -103,14 +380,53 @@ func runServer(
 		defer cancel()
 
 		err := srv.Shutdown(shutdownCtx)
+		if grpcSrv != nil {
+			grpcSrv.shutdown(shutdownCtx)
+		}
+		return err
+	}
+
+	select {
+	case <-ctx.Done():
+		err := shutdown()
+		workersErr := waitWorkers()
 		if err != nil {
 			return err
 		}
+		return workersErr
 	case err := <-errChan:
+		shutdown()
+		workersErr := waitWorkers()
 		if err != nil {
 			return err
 		}
+		return workersErr
+	case err := <-workersErrChan:
+		shutdown()
+		waitWorkers()
+		return err
 	}
+}
 
-	return nil
+// grpcServer is the gRPC API together with its listen address.
+type grpcServer struct {
+	*grpc.Server
+	addr string
+}
+
+// shutdown waits for in-flight calls to finish and forcibly stops the server
+// once ctx is done.
+func (s *grpcServer) shutdown(ctx context.Context) {
+	stopped := make(chan struct{})
+	go func() {
+		s.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		s.Stop()
+		<-stopped
+	}
 }
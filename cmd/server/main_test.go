@@ -1,14 +1,36 @@
 package main
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/rsa"
+	"errors"
+	"net"
 	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/go-resty/resty/v2"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/configs"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/encryption"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/facades"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/hash"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/interceptors"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/models"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/pb"
 )
 
 func TestRunServer_ShutdownOnContextCancel(t *testing.T) {
@@ -23,7 +45,7 @@ func TestRunServer_ShutdownOnContextCancel(t *testing.T) {
 
 	done := make(chan error)
 	go func() {
-		done <- runServer(ctx, srv)
+		done <- runServer(ctx, srv, nil)
 	}()
 
 	// wait for server to start listening
@@ -43,7 +65,7 @@ func TestRunServer_ListenAndServeReturnsError(t *testing.T) {
 
 	ctx := context.Background()
 
-	err := runServer(ctx, srv)
+	err := runServer(ctx, srv, nil)
 	require.Error(t, err)
 }
 
@@ -61,7 +83,7 @@ func TestRunServer_ListenAndServeReturnsErrServerClosed(t *testing.T) {
 	// Run server in goroutine
 	done := make(chan error)
 	go func() {
-		err := runServer(ctx, srv)
+		err := runServer(ctx, srv, nil)
 		done <- err
 	}()
 
@@ -77,6 +99,481 @@ func TestRunServer_ListenAndServeReturnsErrServerClosed(t *testing.T) {
 	require.NoError(t, err)
 }
 
+type workerFunc func(ctx context.Context) error
+
+func (f workerFunc) Start(ctx context.Context) error {
+	return f(ctx)
+}
+
+func TestRunServer_StopsWorkersOnShutdown(t *testing.T) {
+	srv := &http.Server{Addr: "127.0.0.1:0"}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	flushed := make(chan struct{})
+	w := workerFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		close(flushed)
+		return nil
+	})
+
+	done := make(chan error)
+	go func() {
+		done <- runServer(ctx, srv, nil, w)
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+
+	cancel()
+
+	require.NoError(t, <-done)
+	<-flushed
+}
+
+func TestRunServer_WorkerReturnsError(t *testing.T) {
+	srv := &http.Server{Addr: "127.0.0.1:0"}
+
+	w := workerFunc(func(ctx context.Context) error {
+		return errors.New("snapshot failed")
+	})
+
+	err := runServer(context.Background(), srv, nil, w)
+	require.Error(t, err)
+}
+
+func TestNewServer_RestoresFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.json")
+	require.NoError(t, os.WriteFile(path, []byte(`[{"id":"restored","type":"gauge","value":4.5}]`), 0o644))
+
+	srv, _, workers, err := newServer(configs.NewServerConfig(
+		configs.WithServerFileStoragePath(path),
+		configs.WithServerRestore(true),
+		configs.WithServerStoreInterval(0),
+	), nil)
+	require.NoError(t, err)
+	require.Len(t, workers, 1)
+
+	rr := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/value/gauge/restored", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, "4.5", rr.Body.String())
+
+	rr = httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/update/gauge/synced/1", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Contains(t, string(data), "synced")
+}
+
+func TestNewServer_HistogramBuckets(t *testing.T) {
+	srv, _, _, err := newServer(configs.NewServerConfig(
+		configs.WithServerFileStoragePath(""),
+		configs.WithServerHistogramBuckets(0.1, 1),
+	), nil)
+	require.NoError(t, err)
+
+	for _, value := range []string{"0.05", "0.5", "2"} {
+		rr := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/update/histogram/latency/"+value, nil))
+		require.Equal(t, http.StatusOK, rr.Code)
+	}
+
+	rr := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/value/histogram/latency", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, "count=3 sum=2.55 buckets=[0.1:1 1:2 +Inf:3]", rr.Body.String())
+}
+
+func TestNewServer_VerifiesSignatures(t *testing.T) {
+	srv, _, _, err := newServer(configs.NewServerConfig(
+		configs.WithServerFileStoragePath(""),
+		configs.WithServerKey("secret"),
+	), nil)
+	require.NoError(t, err)
+
+	body := `[{"id":"signed","type":"gauge","value":1}]`
+
+	req := httptest.NewRequest(http.MethodPost, "/updates/", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/updates/", strings.NewReader(body))
+	req.Header.Set(hash.Header, hash.Sign([]byte(body), "secret"))
+	rr = httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.True(t, hash.Verify(rr.Body.Bytes(), "secret", rr.Header().Get(hash.Header)))
+
+	for _, target := range []string{"/update/gauge/path/1", "/update/set/users?member=alice"} {
+		req = httptest.NewRequest(http.MethodPost, target, nil)
+		rr = httptest.NewRecorder()
+		srv.Handler.ServeHTTP(rr, req)
+		require.Equal(t, http.StatusBadRequest, rr.Code, "unsigned path update %s", target)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/update/gauge/path/1", nil)
+	req.Header.Set(hash.Header, hash.Sign([]byte("POST /update/gauge/path/1"), "secret"))
+	rr = httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+}
+
+// writePrivateKey generates a key pair and stores the private key as PEM.
+func writePrivateKey(t *testing.T) (*rsa.PrivateKey, string) {
+	t.Helper()
+
+	key, err := encryption.GenerateKey(2048)
+	require.NoError(t, err)
+	privatePEM, err := encryption.EncodePrivateKey(key)
+	require.NoError(t, err)
+
+	keyPath := filepath.Join(t.TempDir(), "private.pem")
+	require.NoError(t, os.WriteFile(keyPath, privatePEM, 0o600))
+
+	return key, keyPath
+}
+
+func TestNewServer_DecryptsPayloads(t *testing.T) {
+	key, keyPath := writePrivateKey(t)
+
+	srv, _, _, err := newServer(configs.NewServerConfig(
+		configs.WithServerFileStoragePath(""),
+		configs.WithServerKey("secret"),
+		configs.WithServerCryptoKey(keyPath),
+	), nil)
+	require.NoError(t, err)
+
+	ts := httptest.NewServer(srv.Handler)
+	defer ts.Close()
+
+	value := 1.0
+	metrics := []*models.Metrics{{ID: "secret", MType: models.Gauge, Value: &value}}
+
+	err = facades.NewMetricHTTPFacade(
+		facades.WithMetricFacadeServerAddress(ts.URL),
+		facades.WithMetricFacadeKey("secret"),
+		facades.WithMetricFacadePublicKey(&key.PublicKey),
+	).Updates(context.Background(), metrics)
+	require.NoError(t, err)
+
+	err = facades.NewMetricHTTPFacade(
+		facades.WithMetricFacadeServerAddress(ts.URL),
+		facades.WithMetricFacadeKey("secret"),
+		facades.WithMetricFacadeRetryDelays(),
+	).Updates(context.Background(), metrics)
+	require.Error(t, err, "plain payloads are rejected")
+
+	_, _, _, err = newServer(configs.NewServerConfig(
+		configs.WithServerCryptoKey(filepath.Join(t.TempDir(), "missing.pem")),
+	), nil)
+	require.Error(t, err)
+}
+
+func TestRunServer_GRPCWithTLS(t *testing.T) {
+	key, keyPath := writePrivateKey(t)
+	grpcAddress := freeAddress(t)
+
+	srv, grpcSrv, _, err := newServer(configs.NewServerConfig(
+		configs.WithServerAddress(freeAddress(t)),
+		configs.WithServerFileStoragePath(""),
+		configs.WithServerCryptoKey(keyPath),
+		configs.WithServerGRPCAddress(grpcAddress),
+	), nil)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- runServer(ctx, srv, grpcSrv)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	otherKey, err := encryption.GenerateKey(2048)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		opts        []facades.MetricGRPCFacadeOpt
+		expectedErr bool
+	}{
+		{
+			name: "server authenticated by its public key",
+			opts: []facades.MetricGRPCFacadeOpt{
+				facades.WithMetricGRPCFacadeTLS(encryption.ClientTLSConfig(&key.PublicKey)),
+			},
+		},
+		{
+			name: "server with another key",
+			opts: []facades.MetricGRPCFacadeOpt{
+				facades.WithMetricGRPCFacadeTLS(encryption.ClientTLSConfig(&otherKey.PublicKey)),
+			},
+			expectedErr: true,
+		},
+		{name: "plaintext client", expectedErr: true},
+	}
+
+	value := 1.0
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The first attempts may race the listener start.
+			opts := append([]facades.MetricGRPCFacadeOpt{
+				facades.WithMetricGRPCFacadeServerAddress(grpcAddress),
+				facades.WithMetricGRPCFacadeRetryDelays(100*time.Millisecond, 200*time.Millisecond, 500*time.Millisecond),
+			}, tt.opts...)
+			facade, err := facades.NewMetricGRPCFacade(opts...)
+			require.NoError(t, err)
+			defer facade.Close()
+
+			err = facade.Updates(context.Background(), []*models.Metrics{
+				{ID: "secret", MType: models.Gauge, Value: &value},
+			})
+			if tt.expectedErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestNewServer_UnsignedListeners(t *testing.T) {
+	_, cryptoKey := writePrivateKey(t)
+
+	tests := []struct {
+		name string
+		opts []configs.ServerOpt
+	}{
+		{name: "statsd with key", opts: []configs.ServerOpt{
+			configs.WithServerStatsDAddress(freeAddress(t)),
+			configs.WithServerKey("secret"),
+		}},
+		{name: "statsd with crypto key", opts: []configs.ServerOpt{
+			configs.WithServerStatsDAddress(freeAddress(t)),
+			configs.WithServerCryptoKey(cryptoKey),
+		}},
+		{name: "graphite with key", opts: []configs.ServerOpt{
+			configs.WithServerGraphiteAddress(freeAddress(t)),
+			configs.WithServerKey("secret"),
+		}},
+		{name: "graphite with crypto key", opts: []configs.ServerOpt{
+			configs.WithServerGraphiteAddress(freeAddress(t)),
+			configs.WithServerCryptoKey(cryptoKey),
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := append([]configs.ServerOpt{configs.WithServerFileStoragePath("")}, tt.opts...)
+			_, _, _, err := newServer(configs.NewServerConfig(opts...), nil)
+			require.Error(t, err, "the listener would accept unsigned updates")
+		})
+	}
+}
+
+func TestNewServer_TrustedSubnet(t *testing.T) {
+	srv, _, _, err := newServer(configs.NewServerConfig(
+		configs.WithServerFileStoragePath(""),
+		configs.WithServerTrustedSubnet("10.0.0.0/8"),
+	), nil)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		method       string
+		target       string
+		realIP       string
+		expectedCode int
+	}{
+		{name: "update from trusted agent", method: http.MethodPost, target: "/update/gauge/a/1", realIP: "10.1.2.3", expectedCode: http.StatusOK},
+		{name: "update from untrusted agent", method: http.MethodPost, target: "/update/gauge/a/1", realIP: "192.168.0.1", expectedCode: http.StatusForbidden},
+		{name: "update without X-Real-IP", method: http.MethodPost, target: "/updates/", expectedCode: http.StatusForbidden},
+		{name: "reads are not restricted", method: http.MethodGet, target: "/value/gauge/a", expectedCode: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, nil)
+			if tt.realIP != "" {
+				req.Header.Set("X-Real-IP", tt.realIP)
+			}
+			rr := httptest.NewRecorder()
+			srv.Handler.ServeHTTP(rr, req)
+			require.Equal(t, tt.expectedCode, rr.Code)
+		})
+	}
+}
+
+func freeAddress(t *testing.T) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer lis.Close()
+	return lis.Addr().String()
+}
+
+func TestRunServer_GRPC(t *testing.T) {
+	grpcAddress := freeAddress(t)
+
+	srv, grpcSrv, _, err := newServer(configs.NewServerConfig(
+		configs.WithServerAddress(freeAddress(t)),
+		configs.WithServerFileStoragePath(""),
+		configs.WithServerKey("secret"),
+		configs.WithServerTrustedSubnet("127.0.0.0/8"),
+		configs.WithServerGRPCAddress(grpcAddress),
+	), nil)
+	require.NoError(t, err)
+	require.NotNil(t, grpcSrv)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- runServer(ctx, srv, grpcSrv)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	facade, err := facades.NewMetricGRPCFacade(
+		facades.WithMetricGRPCFacadeServerAddress(grpcAddress),
+		facades.WithMetricGRPCFacadeKey("secret"),
+		facades.WithMetricGRPCFacadeRetryDelays(100*time.Millisecond, 200*time.Millisecond, 500*time.Millisecond, time.Second),
+	)
+	require.NoError(t, err)
+	defer facade.Close()
+
+	delta := int64(3)
+	require.NoError(t, facade.Updates(context.Background(), []*models.Metrics{
+		{ID: "requests", MType: models.Counter, Delta: &delta},
+	}))
+
+	streamFacade, err := facades.NewMetricGRPCFacade(
+		facades.WithMetricGRPCFacadeServerAddress(grpcAddress),
+		facades.WithMetricGRPCFacadeKey("secret"),
+		facades.WithMetricGRPCFacadeStreaming(),
+	)
+	require.NoError(t, err)
+	require.NoError(t, streamFacade.Updates(context.Background(), []*models.Metrics{
+		{ID: "requests", MType: models.Counter, Delta: &delta},
+	}))
+	// Close waits for the final summary, the streamed metric is stored by then.
+	require.NoError(t, streamFacade.Close())
+
+	conn, err := grpc.NewClient(grpcAddress,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithChainUnaryInterceptor(interceptors.HashClientInterceptor("secret")),
+	)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	resp, err := pb.NewMetricsServiceClient(conn).GetValue(context.Background(),
+		&pb.GetValueRequest{Id: "requests", Type: models.Counter})
+	require.NoError(t, err)
+	require.Equal(t, int64(6), resp.GetMetric().GetDelta())
+
+	_, err = pb.NewMetricsServiceClient(conn).UpdateBatch(context.Background(),
+		&pb.UpdateBatchRequest{Metrics: []*pb.Metric{pb.FromModel(&models.Metrics{ID: "requests", MType: models.Counter, Delta: &delta})}})
+	require.Equal(t, codes.PermissionDenied, status.Code(err), "updates without x-real-ip are rejected")
+
+	stream, err := pb.NewMetricsServiceClient(conn).StreamUpdates(context.Background())
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	require.Equal(t, codes.PermissionDenied, status.Code(err), "streams without x-real-ip are rejected")
+}
+
+func TestRunServer_StatsD(t *testing.T) {
+	probe, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	statsdAddress := probe.LocalAddr().String()
+	probe.Close()
+
+	srv, grpcSrv, workers, err := newServer(configs.NewServerConfig(
+		configs.WithServerAddress(freeAddress(t)),
+		configs.WithServerFileStoragePath(""),
+		configs.WithServerStatsDAddress(statsdAddress),
+	), nil)
+	require.NoError(t, err)
+	require.Len(t, workers, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- runServer(ctx, srv, grpcSrv, workers...)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	client, err := net.Dial("udp", statsdAddress)
+	require.NoError(t, err)
+	defer client.Close()
+
+	// Packets sent before the listener is up are lost, an absolute gauge can
+	// be resent until it shows up.
+	require.Eventually(t, func() bool {
+		client.Write([]byte("temperature:21.5|g"))
+
+		rr := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/value/gauge/temperature", nil))
+		return rr.Code == http.StatusOK && rr.Body.String() == "21.5"
+	}, 5*time.Second, 100*time.Millisecond)
+}
+
+func TestRunServer_GRPCListenError(t *testing.T) {
+	srv := &http.Server{Addr: "127.0.0.1:0"}
+
+	err := runServer(context.Background(), srv, &grpcServer{Server: grpc.NewServer(), addr: "invalid_addr"})
+	require.Error(t, err)
+}
+
+func TestParseFlags_DatabaseDSN(t *testing.T) {
+	config, err := parseFlags([]string{"-d", "postgres://localhost/db"})
+	require.NoError(t, err)
+	require.Equal(t, "postgres://localhost/db", config.DatabaseDSN)
+
+	config, err = parseFlags([]string{"-database-dsn", "postgres://localhost/other"})
+	require.NoError(t, err)
+	require.Equal(t, "postgres://localhost/other", config.DatabaseDSN)
+
+	_, err = parseFlags([]string{"-unknown"})
+	require.Error(t, err)
+}
+
+func TestNewServer_DatabaseStorage(t *testing.T) {
+	conn, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	srv, _, workers, err := newServer(configs.NewServerConfig(), conn)
+	require.NoError(t, err)
+	require.Empty(t, workers)
+
+	mock.ExpectPing()
+
+	rr := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/ping", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	mock.ExpectQuery("SELECT id, type, labels, delta, value, histogram, summary, set_sketch").
+		WithArgs("dbGauge", "gauge", `host="a"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "type", "labels", "delta", "value", "histogram", "summary", "set_sketch"}).
+			AddRow("dbGauge", "gauge", `host="a"`, nil, 1.25, nil, nil, nil))
+
+	rr = httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/value/gauge/dbGauge?host=a", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, "1.25", rr.Body.String())
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
 type ServerSuite struct {
 	suite.Suite
 	client *resty.Client
@@ -86,7 +583,7 @@ func (s *ServerSuite) SetupSuite() {
 	errChan := make(chan error, 1)
 
 	go func() {
-		err := command()
+		err := command(nil)
 		if err != nil && err != http.ErrServerClosed {
 			errChan <- err
 		}
@@ -159,6 +656,103 @@ func (s *ServerSuite) TestUpdateMetricScenarios() {
 	}
 }
 
+func (s *ServerSuite) TestGetMetricScenarios() {
+	resp, err := s.client.R().Post("/update/gauge/readBack/12.5")
+	s.Require().NoError(err)
+	s.Require().Equal(http.StatusOK, resp.StatusCode())
+
+	resp, err = s.client.R().Get("/value/gauge/readBack")
+	s.Require().NoError(err)
+	s.Equal(http.StatusOK, resp.StatusCode())
+	s.Equal("12.5", resp.String())
+
+	resp, err = s.client.R().Get("/value/gauge/missing")
+	s.Require().NoError(err)
+	s.Equal(http.StatusNotFound, resp.StatusCode())
+
+	resp, err = s.client.R().Get("/")
+	s.Require().NoError(err)
+	s.Equal(http.StatusOK, resp.StatusCode())
+	s.Contains(resp.String(), "readBack")
+}
+
+func (s *ServerSuite) TestJSONMetricScenarios() {
+	steps := []struct {
+		body string
+		want string
+	}{
+		{
+			body: `{"id":"jsonCounter","type":"counter","delta":3}`,
+			want: `{"id":"jsonCounter","type":"counter","delta":3}`,
+		},
+		{
+			body: `{"id":"jsonCounter","type":"counter","delta":4}`,
+			want: `{"id":"jsonCounter","type":"counter","delta":7}`,
+		},
+	}
+
+	for _, step := range steps {
+		resp, err := s.client.R().
+			SetHeader("Content-Type", "application/json").
+			SetBody(step.body).
+			Post("/update/")
+		s.Require().NoError(err)
+		s.Require().Equal(http.StatusOK, resp.StatusCode())
+		s.JSONEq(step.want, resp.String())
+	}
+
+	resp, err := s.client.R().
+		SetHeader("Content-Type", "application/json").
+		SetBody(`{"id":"jsonCounter","type":"counter"}`).
+		Post("/value/")
+	s.Require().NoError(err)
+	s.Equal(http.StatusOK, resp.StatusCode())
+	s.JSONEq(`{"id":"jsonCounter","type":"counter","delta":7}`, resp.String())
+}
+
+func (s *ServerSuite) TestBatchMetricScenarios() {
+	resp, err := s.client.R().
+		SetHeader("Content-Type", "application/json").
+		SetBody(`[{"id":"batchCounter","type":"counter","delta":2},{"id":"batchCounter","type":"counter","delta":3}]`).
+		Post("/updates/")
+	s.Require().NoError(err)
+	s.Require().Equal(http.StatusOK, resp.StatusCode())
+
+	resp, err = s.client.R().
+		SetHeader("Content-Type", "application/json").
+		SetBody(`[{"id":"batchGauge","type":"gauge","value":1},{"id":"batchCounter","type":"counter"}]`).
+		Post("/updates/")
+	s.Require().NoError(err)
+	s.Require().Equal(http.StatusBadRequest, resp.StatusCode())
+
+	resp, err = s.client.R().Get("/value/counter/batchCounter")
+	s.Require().NoError(err)
+	s.Equal("5", resp.String())
+
+	resp, err = s.client.R().Get("/value/gauge/batchGauge")
+	s.Require().NoError(err)
+	s.Equal(http.StatusNotFound, resp.StatusCode())
+}
+
+func (s *ServerSuite) TestGzipScenarios() {
+	var body bytes.Buffer
+	gz := gzip.NewWriter(&body)
+	_, err := gz.Write([]byte(`{"id":"gzipGauge","type":"gauge","value":2.5}`))
+	s.Require().NoError(err)
+	s.Require().NoError(gz.Close())
+
+	resp, err := s.client.R().
+		SetHeader("Content-Type", "application/json").
+		SetHeader("Content-Encoding", "gzip").
+		SetHeader("Accept-Encoding", "gzip").
+		SetBody(body.Bytes()).
+		Post("/update/")
+	s.Require().NoError(err)
+	s.Require().Equal(http.StatusOK, resp.StatusCode())
+	s.Equal("gzip", resp.Header().Get("Content-Encoding"))
+	s.JSONEq(`{"id":"gzipGauge","type":"gauge","value":2.5}`, resp.String())
+}
+
 func TestServerSuite(t *testing.T) {
 	suite.Run(t, new(ServerSuite))
 }
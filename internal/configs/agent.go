@@ -0,0 +1,233 @@
+package configs
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/retry"
+)
+
+// Transports supported by the agent.
+const (
+	TransportHTTP       = "http"
+	TransportGRPC       = "grpc"
+	TransportGRPCStream = "grpc-stream"
+)
+
+// AgentConfig holds configuration for the agent
+type AgentConfig struct {
+	Address        string          `json:"address"`
+	PollInterval   int             `json:"poll_interval"`
+	ReportInterval int             `json:"report_interval"`
+	LogLevel       string          `json:"log_level"`
+	Key            string          `json:"key"`
+	RateLimit      int             `json:"rate_limit"`
+	CryptoKey      string          `json:"crypto_key"`
+	Transport      string          `json:"transport"`
+	RetryDelays    []time.Duration `json:"retry_delays"`
+}
+
+// AgentOpt is a functional option for configuring AgentConfig
+type AgentOpt func(*AgentConfig)
+
+// WithAgentAddress sets the address of the metrics server
+func WithAgentAddress(addr string) AgentOpt {
+	return func(cfg *AgentConfig) {
+		cfg.Address = addr
+	}
+}
+
+// WithAgentPollInterval sets the metrics poll interval in seconds
+func WithAgentPollInterval(seconds int) AgentOpt {
+	return func(cfg *AgentConfig) {
+		cfg.PollInterval = seconds
+	}
+}
+
+// WithAgentReportInterval sets the metrics report interval in seconds
+func WithAgentReportInterval(seconds int) AgentOpt {
+	return func(cfg *AgentConfig) {
+		cfg.ReportInterval = seconds
+	}
+}
+
+// WithAgentLogLevel sets the agent log level
+func WithAgentLogLevel(level string) AgentOpt {
+	return func(cfg *AgentConfig) {
+		cfg.LogLevel = level
+	}
+}
+
+// WithAgentKey sets the shared secret used to sign payloads
+func WithAgentKey(key string) AgentOpt {
+	return func(cfg *AgentConfig) {
+		cfg.Key = key
+	}
+}
+
+// WithAgentRateLimit sets the maximum number of concurrent requests to the server
+func WithAgentRateLimit(limit int) AgentOpt {
+	return func(cfg *AgentConfig) {
+		cfg.RateLimit = limit
+	}
+}
+
+// WithAgentCryptoKey sets the path to the server's PEM public key used to encrypt request bodies
+func WithAgentCryptoKey(path string) AgentOpt {
+	return func(cfg *AgentConfig) {
+		cfg.CryptoKey = path
+	}
+}
+
+// WithAgentTransport sets the protocol used to report metrics, "http", "grpc" or "grpc-stream"
+func WithAgentTransport(transport string) AgentOpt {
+	return func(cfg *AgentConfig) {
+		cfg.Transport = transport
+	}
+}
+
+// WithAgentRetryDelays sets the pauses between attempts to report metrics, none disables retries
+func WithAgentRetryDelays(delays ...time.Duration) AgentOpt {
+	return func(cfg *AgentConfig) {
+		cfg.RetryDelays = delays
+	}
+}
+
+// NewAgentConfig creates an AgentConfig with optional functional parameters
+func NewAgentConfig(opts ...AgentOpt) *AgentConfig {
+	cfg := &AgentConfig{
+		Address:        "localhost:8080",
+		PollInterval:   2,
+		ReportInterval: 10,
+		LogLevel:       "info",
+		RateLimit:      1,
+		Transport:      TransportHTTP,
+		RetryDelays:    append([]time.Duration(nil), retry.DefaultDelays...),
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+var agentSettings = []setting[AgentConfig]{
+	{
+		flags: []string{"a"},
+		env:   "ADDRESS",
+		key:   "address",
+		usage: "metrics server address host:port",
+		parse: func(value string) (func(*AgentConfig), error) {
+			addr, err := parseAgentAddress(value)
+			return WithAgentAddress(addr), err
+		},
+	},
+	{
+		flags: []string{"p"},
+		env:   "POLL_INTERVAL",
+		key:   "poll_interval",
+		usage: "poll interval in seconds",
+		parse: func(value string) (func(*AgentConfig), error) {
+			seconds, err := parsePositiveSeconds(value)
+			return WithAgentPollInterval(seconds), err
+		},
+	},
+	{
+		flags: []string{"r"},
+		env:   "REPORT_INTERVAL",
+		key:   "report_interval",
+		usage: "report interval in seconds",
+		parse: func(value string) (func(*AgentConfig), error) {
+			seconds, err := parsePositiveSeconds(value)
+			return WithAgentReportInterval(seconds), err
+		},
+	},
+	{
+		flags: []string{"log-level"},
+		env:   "LOG_LEVEL",
+		key:   "log_level",
+		usage: "log level",
+		parse: func(value string) (func(*AgentConfig), error) {
+			level, err := parseLogLevel(value)
+			return WithAgentLogLevel(level), err
+		},
+	},
+	{
+		flags: []string{"k"},
+		env:   "KEY",
+		key:   "key",
+		usage: "shared secret for HMAC-SHA256 payload signatures",
+		parse: func(value string) (func(*AgentConfig), error) {
+			return WithAgentKey(value), nil
+		},
+	},
+	{
+		flags: []string{"l"},
+		env:   "RATE_LIMIT",
+		key:   "rate_limit",
+		usage: "maximum number of concurrent requests to the server",
+		parse: func(value string) (func(*AgentConfig), error) {
+			limit, err := strconv.Atoi(value)
+			if err == nil && limit < 1 {
+				err = errors.New("rate limit must be positive")
+			}
+			return WithAgentRateLimit(limit), err
+		},
+	},
+	{
+		flags: []string{"crypto-key"},
+		env:   "CRYPTO_KEY",
+		key:   "crypto_key",
+		usage: "path to the server's PEM public key for encrypting request bodies and authenticating the gRPC server",
+		parse: func(value string) (func(*AgentConfig), error) {
+			return WithAgentCryptoKey(value), nil
+		},
+	},
+	{
+		flags: []string{"transport"},
+		env:   "TRANSPORT",
+		key:   "transport",
+		usage: "protocol used to report metrics: http, grpc or grpc-stream",
+		parse: func(value string) (func(*AgentConfig), error) {
+			transport := strings.ToLower(value)
+			switch transport {
+			case TransportHTTP, TransportGRPC, TransportGRPCStream:
+				return WithAgentTransport(transport), nil
+			default:
+				return nil, fmt.Errorf("unknown transport %q", value)
+			}
+		},
+	},
+	{
+		flags: []string{"retry-delays"},
+		env:   "RETRY_DELAYS",
+		key:   "retry_delays",
+		usage: "comma separated pauses between attempts to report metrics, empty disables retries",
+		parse: func(value string) (func(*AgentConfig), error) {
+			delays, err := parseDelays(value)
+			return WithAgentRetryDelays(delays...), err
+		},
+	},
+}
+
+// LoadAgentConfig builds an AgentConfig from, in increasing order of precedence,
+// the defaults, the JSON file given by -c/-config or CONFIG, command line flags
+// and environment variables.
+func LoadAgentConfig(args []string, lookupEnv LookupEnvFunc) (*AgentConfig, error) {
+	cfg := NewAgentConfig()
+	if err := load("agent", cfg, agentSettings, args, lookupEnv); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// parseAgentAddress validates the server address, which may carry an http(s) scheme.
+func parseAgentAddress(value string) (string, error) {
+	hostPort := strings.TrimPrefix(strings.TrimPrefix(value, "http://"), "https://")
+	if _, err := parseAddress(hostPort); err != nil {
+		return "", err
+	}
+	return value, nil
+}
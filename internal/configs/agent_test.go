@@ -0,0 +1,34 @@
+package configs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewAgentConfig_Defaults(t *testing.T) {
+	cfg := NewAgentConfig()
+
+	assert.Equal(t, "localhost:8080", cfg.Address)
+	assert.Equal(t, 2, cfg.PollInterval)
+	assert.Equal(t, 10, cfg.ReportInterval)
+	assert.Equal(t, "info", cfg.LogLevel)
+	assert.Equal(t, 1, cfg.RateLimit)
+	assert.Equal(t, TransportHTTP, cfg.Transport)
+}
+
+func TestNewAgentConfig_WithMultipleOpts(t *testing.T) {
+	cfg := NewAgentConfig(
+		WithAgentAddress("0.0.0.0:1234"),
+		WithAgentPollInterval(1),
+		WithAgentReportInterval(5),
+		WithAgentLogLevel("debug"),
+		WithAgentRateLimit(4),
+	)
+
+	assert.Equal(t, "0.0.0.0:1234", cfg.Address)
+	assert.Equal(t, 1, cfg.PollInterval)
+	assert.Equal(t, 5, cfg.ReportInterval)
+	assert.Equal(t, "debug", cfg.LogLevel)
+	assert.Equal(t, 4, cfg.RateLimit)
+}
@@ -0,0 +1,38 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// Opt is a functional option type for configuring the database pool
+type Opt func(*sql.DB)
+
+// WithMaxOpenConns limits the number of open connections in the pool
+func WithMaxOpenConns(n int) Opt {
+	return func(db *sql.DB) {
+		db.SetMaxOpenConns(n)
+	}
+}
+
+// WithConnMaxLifetime limits how long a connection may be reused
+func WithConnMaxLifetime(d time.Duration) Opt {
+	return func(db *sql.DB) {
+		db.SetConnMaxLifetime(d)
+	}
+}
+
+// NewDB opens a PostgreSQL connection pool for the given DSN.
+// The connection itself is established lazily on first use.
+func NewDB(dsn string, opts ...Opt) (*sql.DB, error) {
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, err
+	}
+	for _, opt := range opts {
+		opt(db)
+	}
+	return db, nil
+}
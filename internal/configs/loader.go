@@ -0,0 +1,330 @@
+package configs
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/models"
+)
+
+// LookupEnvFunc looks up an environment variable, os.LookupEnv in production.
+type LookupEnvFunc func(key string) (string, bool)
+
+// setting describes a single configuration value and where it can be read from.
+type setting[T any] struct {
+	flags  []string
+	env    string
+	key    string
+	usage  string
+	isBool bool
+	parse  func(value string) (func(*T), error)
+}
+
+// load applies configuration sources to cfg in increasing order of precedence:
+//
+//  1. values already set in cfg (defaults),
+//  2. the JSON file given by -c/-config or CONFIG,
+//  3. command line flags,
+//  4. environment variables.
+func load[T any](
+	name string,
+	cfg *T,
+	settings []setting[T],
+	args []string,
+	lookupEnv LookupEnvFunc,
+) error {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+
+	var configPath string
+	fs.StringVar(&configPath, "c", "", "path to JSON config file")
+	fs.StringVar(&configPath, "config", "", "path to JSON config file")
+
+	flagValues := make([]*stringValue, len(settings))
+	for i, s := range settings {
+		flagValues[i] = &stringValue{isBool: s.isBool}
+		for _, f := range s.flags {
+			fs.Var(flagValues[i], f, s.usage)
+		}
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if path, ok := lookupEnv("CONFIG"); ok && path != "" {
+		configPath = path
+	}
+
+	fileValues, err := readConfigFile(configPath)
+	if err != nil {
+		return err
+	}
+
+	for i, s := range settings {
+		if raw, ok := fileValues[s.key]; ok {
+			if err := apply(cfg, s, raw, fmt.Sprintf("config file key %q", s.key)); err != nil {
+				return err
+			}
+		}
+
+		if flagValues[i].set {
+			if err := apply(cfg, s, flagValues[i].value, fmt.Sprintf("flag -%s", s.flags[0])); err != nil {
+				return err
+			}
+		}
+
+		if s.env == "" {
+			continue
+		}
+		value, ok := lookupEnv(s.env)
+		if !ok {
+			continue
+		}
+		// A variable that is set but empty applies only where the setting
+		// accepts an empty value, such as disabling a feature, and is treated
+		// as unset otherwise.
+		if value == "" {
+			if opt, err := s.parse(value); err == nil {
+				opt(cfg)
+			}
+			continue
+		}
+		if err := apply(cfg, s, value, fmt.Sprintf("environment variable %s", s.env)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func apply[T any](cfg *T, s setting[T], value string, origin string) error {
+	opt, err := s.parse(value)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", origin, err)
+	}
+	opt(cfg)
+	return nil
+}
+
+// readConfigFile reads a flat JSON object and returns its values as strings.
+// An empty path yields no values.
+func readConfigFile(path string) (map[string]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+
+	values := make(map[string]string, len(raw))
+	for key, msg := range raw {
+		var s string
+		switch {
+		case string(msg) == "null":
+			continue
+		case json.Unmarshal(msg, &s) == nil:
+			values[key] = s
+		default:
+			values[key] = string(msg)
+		}
+	}
+
+	return values, nil
+}
+
+// stringValue is a flag.Value that remembers whether it was set on the command line.
+type stringValue struct {
+	value  string
+	set    bool
+	isBool bool
+}
+
+func (v *stringValue) String() string {
+	if v == nil {
+		return ""
+	}
+	return v.value
+}
+
+func (v *stringValue) Set(value string) error {
+	v.value = value
+	v.set = true
+	return nil
+}
+
+func (v *stringValue) IsBoolFlag() bool {
+	return v.isBool
+}
+
+// parseAddress validates a host:port pair.
+func parseAddress(value string) (string, error) {
+	host, port, err := net.SplitHostPort(value)
+	if err != nil {
+		return "", err
+	}
+	if strings.ContainsAny(host, "/ ") {
+		return "", fmt.Errorf("invalid host %q", host)
+	}
+	n, err := strconv.Atoi(port)
+	if err != nil || n < 0 || n > 65535 {
+		return "", fmt.Errorf("invalid port %q", port)
+	}
+	return value, nil
+}
+
+// parseSeconds parses an interval given either as a number of seconds ("10")
+// or as a duration string ("10s", "1m").
+func parseSeconds(value string) (int, error) {
+	if n, err := strconv.Atoi(value); err == nil {
+		if n < 0 {
+			return 0, errors.New("interval must not be negative")
+		}
+		return n, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid interval %q", value)
+	}
+	if d < 0 {
+		return 0, errors.New("interval must not be negative")
+	}
+	if d%time.Second != 0 {
+		return 0, fmt.Errorf("interval %q is not a whole number of seconds", value)
+	}
+
+	return int(d / time.Second), nil
+}
+
+// parsePositiveSeconds is parseSeconds that additionally rejects zero.
+func parsePositiveSeconds(value string) (int, error) {
+	n, err := parseSeconds(value)
+	if err != nil {
+		return 0, err
+	}
+	if n == 0 {
+		return 0, errors.New("interval must be positive")
+	}
+	return n, nil
+}
+
+// parseList accepts a comma separated list, or a JSON array as written in the
+// config file. Blank items are dropped.
+func parseList(value string) ([]string, error) {
+	var items []string
+	if strings.HasPrefix(strings.TrimSpace(value), "[") {
+		if err := json.Unmarshal([]byte(value), &items); err != nil {
+			return nil, fmt.Errorf("invalid list %s: %w", value, err)
+		}
+	} else {
+		items = strings.Split(value, ",")
+	}
+
+	list := make([]string, 0, len(items))
+	for _, item := range items {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list, nil
+}
+
+// parseBuckets parses histogram bucket bounds given like parseList, as a
+// comma separated list or a JSON array of numbers.
+func parseBuckets(value string) ([]float64, error) {
+	var bounds []float64
+	if strings.HasPrefix(strings.TrimSpace(value), "[") {
+		if err := json.Unmarshal([]byte(value), &bounds); err != nil {
+			return nil, fmt.Errorf("invalid buckets %s: %w", value, err)
+		}
+	} else {
+		items, _ := parseList(value)
+		for _, item := range items {
+			bound, err := strconv.ParseFloat(item, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid bucket bound %q", item)
+			}
+			bounds = append(bounds, bound)
+		}
+	}
+
+	if err := models.ValidateHistogramBounds(bounds); err != nil {
+		return nil, err
+	}
+	return bounds, nil
+}
+
+// parseDelays parses retry delays given like parseList, each as a number of
+// seconds ("1", "0.5") or a duration string ("500ms"). An empty list disables
+// retries.
+func parseDelays(value string) ([]time.Duration, error) {
+	var items []string
+	if strings.HasPrefix(strings.TrimSpace(value), "[") {
+		var raw []json.RawMessage
+		if err := json.Unmarshal([]byte(value), &raw); err != nil {
+			return nil, fmt.Errorf("invalid delays %s: %w", value, err)
+		}
+		for _, item := range raw {
+			var s string
+			if json.Unmarshal(item, &s) != nil {
+				s = string(item)
+			}
+			items = append(items, s)
+		}
+	} else {
+		items, _ = parseList(value)
+	}
+
+	delays := make([]time.Duration, 0, len(items))
+	for _, item := range items {
+		d, err := time.ParseDuration(item)
+		if err != nil {
+			seconds, parseErr := strconv.ParseFloat(item, 64)
+			if parseErr != nil || math.IsNaN(seconds) || math.IsInf(seconds, 0) {
+				return nil, fmt.Errorf("invalid delay %q", item)
+			}
+			d = time.Duration(seconds * float64(time.Second))
+		}
+		if d < 0 {
+			return nil, fmt.Errorf("delay %q must not be negative", item)
+		}
+		delays = append(delays, d)
+	}
+	return delays, nil
+}
+
+// parseSubnet validates a CIDR, an empty value is allowed and means no restriction.
+func parseSubnet(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	if _, _, err := net.ParseCIDR(value); err != nil {
+		return "", err
+	}
+	return value, nil
+}
+
+// parseLogLevel validates a log level name.
+func parseLogLevel(value string) (string, error) {
+	switch strings.ToLower(value) {
+	case "debug", "info", "warn", "error", "dpanic", "panic", "fatal":
+		return strings.ToLower(value), nil
+	default:
+		return "", fmt.Errorf("unknown log level %q", value)
+	}
+}
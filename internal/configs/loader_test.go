@@ -0,0 +1,263 @@
+package configs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func envFrom(values map[string]string) LookupEnvFunc {
+	return func(key string) (string, bool) {
+		v, ok := values[key]
+		return v, ok
+	}
+}
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+func TestLoadServerConfig_Defaults(t *testing.T) {
+	cfg, err := LoadServerConfig(nil, envFrom(nil))
+	require.NoError(t, err)
+
+	assert.Equal(t, NewServerConfig(), cfg)
+}
+
+func TestLoadServerConfig_Precedence(t *testing.T) {
+	path := writeConfigFile(t, `{
+		"address": "file:1",
+		"log_level": "debug",
+		"store_interval": "1m",
+		"file_storage_path": "/file.json",
+		"restore": true,
+		"database_dsn": null,
+		"crypto_key": "/file.pem"
+	}`)
+
+	tests := []struct {
+		name  string
+		args  []string
+		env   map[string]string
+		check func(t *testing.T, cfg *ServerConfig)
+	}{
+		{
+			name: "file overrides defaults",
+			args: []string{"-c", path},
+			check: func(t *testing.T, cfg *ServerConfig) {
+				assert.Equal(t, "file:1", cfg.Address)
+				assert.Equal(t, "debug", cfg.LogLevel)
+				assert.Equal(t, 60, cfg.StoreInterval)
+				assert.Equal(t, "/file.json", cfg.FileStoragePath)
+				assert.True(t, cfg.Restore)
+				assert.Empty(t, cfg.DatabaseDSN)
+				assert.Equal(t, "/file.pem", cfg.CryptoKey)
+			},
+		},
+		{
+			name: "flags override file",
+			args: []string{"-config", path, "-a", "flag:2", "-i", "5", "-r=false", "-crypto-key", "/flag.pem", "-g", ":3200", "-statsd", ":8125"},
+			check: func(t *testing.T, cfg *ServerConfig) {
+				assert.Equal(t, ":3200", cfg.GRPCAddress)
+				assert.Equal(t, ":8125", cfg.StatsDAddress)
+				assert.Equal(t, "/flag.pem", cfg.CryptoKey)
+				assert.Equal(t, "flag:2", cfg.Address)
+				assert.Equal(t, "debug", cfg.LogLevel)
+				assert.Equal(t, 5, cfg.StoreInterval)
+				assert.False(t, cfg.Restore)
+			},
+		},
+		{
+			name: "env overrides flags",
+			args: []string{"-a", "flag:2", "-l", "warn"},
+			env:  map[string]string{"CONFIG": path, "ADDRESS": "env:3", "DATABASE_DSN": "postgres://env", "KEY": "secret", "TRUSTED_SUBNET": "10.0.0.0/8"},
+			check: func(t *testing.T, cfg *ServerConfig) {
+				assert.Equal(t, "env:3", cfg.Address)
+				assert.Equal(t, "warn", cfg.LogLevel)
+				assert.Equal(t, 60, cfg.StoreInterval)
+				assert.Equal(t, "postgres://env", cfg.DatabaseDSN)
+				assert.Equal(t, "secret", cfg.Key)
+				assert.Equal(t, "10.0.0.0/8", cfg.TrustedSubnet)
+			},
+		},
+		{
+			name: "empty env disables what the file and flags enable",
+			args: []string{"-c", path, "-t", "10.0.0.0/8", "-g", ":3200", "-k", "secret"},
+			env:  map[string]string{"FILE_STORAGE_PATH": "", "TRUSTED_SUBNET": "", "GRPC_ADDRESS": "", "KEY": ""},
+			check: func(t *testing.T, cfg *ServerConfig) {
+				assert.Empty(t, cfg.FileStoragePath)
+				assert.Empty(t, cfg.TrustedSubnet)
+				assert.Empty(t, cfg.GRPCAddress)
+				assert.Empty(t, cfg.Key)
+			},
+		},
+		{
+			name: "empty env is ignored where an empty value is invalid",
+			args: []string{"-c", path, "-i", "5"},
+			env:  map[string]string{"ADDRESS": "", "LOG_LEVEL": "", "STORE_INTERVAL": "", "RESTORE": ""},
+			check: func(t *testing.T, cfg *ServerConfig) {
+				assert.Equal(t, "file:1", cfg.Address)
+				assert.Equal(t, "debug", cfg.LogLevel)
+				assert.Equal(t, 5, cfg.StoreInterval)
+				assert.True(t, cfg.Restore)
+			},
+		},
+		{
+			name: "boolean flag without value",
+			args: []string{"-r"},
+			check: func(t *testing.T, cfg *ServerConfig) {
+				assert.True(t, cfg.Restore)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := LoadServerConfig(tt.args, envFrom(tt.env))
+			require.NoError(t, err)
+			tt.check(t, cfg)
+		})
+	}
+}
+
+func TestLoadServerConfig_GraphiteCounterPrefixes(t *testing.T) {
+	path := writeConfigFile(t, `{"graphite_address":":2003","graphite_counter_prefixes":["stats.counters","collectd"]}`)
+
+	cfg, err := LoadServerConfig([]string{"-c", path}, envFrom(nil))
+	require.NoError(t, err)
+	assert.Equal(t, ":2003", cfg.GraphiteAddress)
+	assert.Equal(t, []string{"stats.counters", "collectd"}, cfg.GraphiteCounterPrefixes)
+
+	cfg, err = LoadServerConfig([]string{"-c", path}, envFrom(map[string]string{"GRAPHITE_COUNTER_PREFIXES": " a.b , ,c "}))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.b", "c"}, cfg.GraphiteCounterPrefixes)
+}
+
+func TestLoadServerConfig_HistogramBuckets(t *testing.T) {
+	cfg, err := LoadServerConfig(nil, envFrom(nil))
+	require.NoError(t, err)
+	assert.Nil(t, cfg.HistogramBuckets)
+
+	cfg, err = LoadServerConfig([]string{"-c", writeConfigFile(t, `{"histogram_buckets":[0.1,1,10]}`)}, envFrom(nil))
+	require.NoError(t, err)
+	assert.Equal(t, []float64{0.1, 1, 10}, cfg.HistogramBuckets)
+
+	cfg, err = LoadServerConfig([]string{"-histogram-buckets", "0.5, 1,2.5"}, envFrom(nil))
+	require.NoError(t, err)
+	assert.Equal(t, []float64{0.5, 1, 2.5}, cfg.HistogramBuckets)
+}
+
+func TestLoadServerConfig_RetryDelays(t *testing.T) {
+	cfg, err := LoadServerConfig(nil, envFrom(nil))
+	require.NoError(t, err)
+	assert.Equal(t, []time.Duration{time.Second, 3 * time.Second, 5 * time.Second}, cfg.RetryDelays)
+
+	cfg, err = LoadServerConfig([]string{"-c", writeConfigFile(t, `{"retry_delays":["500ms",2]}`)}, envFrom(nil))
+	require.NoError(t, err)
+	assert.Equal(t, []time.Duration{500 * time.Millisecond, 2 * time.Second}, cfg.RetryDelays)
+
+	cfg, err = LoadServerConfig([]string{"-retry-delays", "0.25, 1s"}, envFrom(nil))
+	require.NoError(t, err)
+	assert.Equal(t, []time.Duration{250 * time.Millisecond, time.Second}, cfg.RetryDelays)
+
+	cfg, err = LoadServerConfig([]string{"-retry-delays", "1s"}, envFrom(map[string]string{"RETRY_DELAYS": ""}))
+	require.NoError(t, err)
+	assert.Empty(t, cfg.RetryDelays, "an empty value disables retries")
+}
+
+func TestLoadServerConfig_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+	}{
+		{name: "unknown flag", args: []string{"-unknown"}},
+		{name: "address without port", args: []string{"-a", "localhost"}},
+		{name: "address with bad port", env: map[string]string{"ADDRESS": "localhost:http"}},
+		{name: "malformed interval", args: []string{"-i", "soon"}},
+		{name: "negative interval", env: map[string]string{"STORE_INTERVAL": "-1"}},
+		{name: "fractional interval", args: []string{"-i", "1.5s"}},
+		{name: "malformed restore", env: map[string]string{"RESTORE": "maybe"}},
+		{name: "unknown log level", args: []string{"-l", "loud"}},
+		{name: "malformed trusted subnet", args: []string{"-t", "10.0.0.0"}},
+		{name: "gRPC address without port", env: map[string]string{"GRPC_ADDRESS": "localhost"}},
+		{name: "StatsD address without port", env: map[string]string{"STATSD_ADDRESS": "localhost"}},
+		{name: "Graphite address without port", args: []string{"-graphite", "localhost"}},
+		{name: "malformed Graphite prefixes in config file", args: []string{"-c", writeConfigFile(t, `{"graphite_counter_prefixes":["a",1]}`)}},
+		{name: "malformed histogram bucket", args: []string{"-histogram-buckets", "0.1,fast"}},
+		{name: "descending histogram buckets", env: map[string]string{"HISTOGRAM_BUCKETS": "1,0.5"}},
+		{name: "malformed histogram buckets in config file", args: []string{"-c", writeConfigFile(t, `{"histogram_buckets":["a"]}`)}},
+		{name: "malformed retry delay", args: []string{"-retry-delays", "1s,soon"}},
+		{name: "negative retry delay", env: map[string]string{"RETRY_DELAYS": "-1"}},
+		{name: "malformed retry delays in config file", args: []string{"-c", writeConfigFile(t, `{"retry_delays":[true]}`)}},
+		{name: "missing config file", args: []string{"-c", "/does/not/exist.json"}},
+		{name: "malformed config file", args: []string{"-c", writeConfigFile(t, `{"address":`)}},
+		{name: "invalid value in config file", args: []string{"-c", writeConfigFile(t, `{"store_interval":"later"}`)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadServerConfig(tt.args, envFrom(tt.env))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestLoadAgentConfig(t *testing.T) {
+	path := writeConfigFile(t, `{"address":"file:1","poll_interval":"3s","report_interval":20}`)
+
+	cfg, err := LoadAgentConfig(
+		[]string{"-c", path, "-p", "1", "-a", "http://flag:2", "-k", "secret", "-crypto-key", "/flag.pem"},
+		envFrom(map[string]string{"REPORT_INTERVAL": "7", "LOG_LEVEL": "DEBUG", "RATE_LIMIT": "3", "CRYPTO_KEY": "/env.pem", "TRANSPORT": "GRPC", "RETRY_DELAYS": "100ms,1"}),
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, "http://flag:2", cfg.Address)
+	assert.Equal(t, 1, cfg.PollInterval)
+	assert.Equal(t, 7, cfg.ReportInterval)
+	assert.Equal(t, "debug", cfg.LogLevel)
+	assert.Equal(t, "secret", cfg.Key)
+	assert.Equal(t, 3, cfg.RateLimit)
+	assert.Equal(t, "/env.pem", cfg.CryptoKey)
+	assert.Equal(t, TransportGRPC, cfg.Transport)
+	assert.Equal(t, []time.Duration{100 * time.Millisecond, time.Second}, cfg.RetryDelays)
+}
+
+func TestLoadAgentConfig_Transport(t *testing.T) {
+	for _, transport := range []string{TransportHTTP, TransportGRPC, TransportGRPCStream} {
+		t.Run(transport, func(t *testing.T) {
+			cfg, err := LoadAgentConfig([]string{"-transport", strings.ToUpper(transport)}, envFrom(nil))
+			require.NoError(t, err)
+			assert.Equal(t, transport, cfg.Transport)
+		})
+	}
+}
+
+func TestLoadAgentConfig_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{name: "zero poll interval", args: []string{"-p", "0"}},
+		{name: "malformed report interval", args: []string{"-r", "often"}},
+		{name: "malformed address", args: []string{"-a", "http://localhost"}},
+		{name: "zero rate limit", args: []string{"-l", "0"}},
+		{name: "malformed rate limit", args: []string{"-l", "many"}},
+		{name: "unknown transport", args: []string{"-transport", "udp"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadAgentConfig(tt.args, envFrom(nil))
+			assert.Error(t, err)
+		})
+	}
+}
@@ -1,9 +1,29 @@
 package configs
 
+import (
+	"strconv"
+	"time"
+
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/retry"
+)
+
 // ServerConfig holds configuration for the server
 type ServerConfig struct {
-	Address  string `json:"address"`
-	LogLevel string `json:"log_level"`
+	Address                 string          `json:"address"`
+	LogLevel                string          `json:"log_level"`
+	StoreInterval           int             `json:"store_interval"`
+	FileStoragePath         string          `json:"file_storage_path"`
+	Restore                 bool            `json:"restore"`
+	DatabaseDSN             string          `json:"database_dsn"`
+	Key                     string          `json:"key"`
+	CryptoKey               string          `json:"crypto_key"`
+	TrustedSubnet           string          `json:"trusted_subnet"`
+	GRPCAddress             string          `json:"grpc_address"`
+	StatsDAddress           string          `json:"statsd_address"`
+	GraphiteAddress         string          `json:"graphite_address"`
+	GraphiteCounterPrefixes []string        `json:"graphite_counter_prefixes"`
+	HistogramBuckets        []float64       `json:"histogram_buckets"`
+	RetryDelays             []time.Duration `json:"retry_delays"`
 }
 
 // ServerOpt is a functional option for configuring ServerConfig
@@ -23,14 +43,279 @@ func WithServerLogLevel(level string) ServerOpt {
 	}
 }
 
+// WithServerStoreInterval sets the snapshot interval in seconds, 0 makes every write synchronous
+func WithServerStoreInterval(seconds int) ServerOpt {
+	return func(cfg *ServerConfig) {
+		cfg.StoreInterval = seconds
+	}
+}
+
+// WithServerFileStoragePath sets the snapshot file path, an empty path disables file storage
+func WithServerFileStoragePath(path string) ServerOpt {
+	return func(cfg *ServerConfig) {
+		cfg.FileStoragePath = path
+	}
+}
+
+// WithServerRestore sets whether metrics are restored from the snapshot file on startup
+func WithServerRestore(restore bool) ServerOpt {
+	return func(cfg *ServerConfig) {
+		cfg.Restore = restore
+	}
+}
+
+// WithServerDatabaseDSN sets the PostgreSQL DSN, a non-empty DSN selects the database storage
+func WithServerDatabaseDSN(dsn string) ServerOpt {
+	return func(cfg *ServerConfig) {
+		cfg.DatabaseDSN = dsn
+	}
+}
+
+// WithServerKey sets the shared secret used to sign and verify payloads
+func WithServerKey(key string) ServerOpt {
+	return func(cfg *ServerConfig) {
+		cfg.Key = key
+	}
+}
+
+// WithServerCryptoKey sets the path to the PEM private key used to decrypt request bodies
+func WithServerCryptoKey(path string) ServerOpt {
+	return func(cfg *ServerConfig) {
+		cfg.CryptoKey = path
+	}
+}
+
+// WithServerTrustedSubnet sets the CIDR agents must report in X-Real-IP, empty allows any
+func WithServerTrustedSubnet(cidr string) ServerOpt {
+	return func(cfg *ServerConfig) {
+		cfg.TrustedSubnet = cidr
+	}
+}
+
+// WithServerGRPCAddress sets the gRPC listen address host:port, empty disables gRPC
+func WithServerGRPCAddress(addr string) ServerOpt {
+	return func(cfg *ServerConfig) {
+		cfg.GRPCAddress = addr
+	}
+}
+
+// WithServerStatsDAddress sets the StatsD UDP listen address host:port, empty disables the listener
+func WithServerStatsDAddress(addr string) ServerOpt {
+	return func(cfg *ServerConfig) {
+		cfg.StatsDAddress = addr
+	}
+}
+
+// WithServerGraphiteAddress sets the Graphite TCP listen address host:port, empty disables the listener
+func WithServerGraphiteAddress(addr string) ServerOpt {
+	return func(cfg *ServerConfig) {
+		cfg.GraphiteAddress = addr
+	}
+}
+
+// WithServerGraphiteCounterPrefixes sets the Graphite path prefixes stored as counters instead of gauges
+func WithServerGraphiteCounterPrefixes(prefixes ...string) ServerOpt {
+	return func(cfg *ServerConfig) {
+		cfg.GraphiteCounterPrefixes = prefixes
+	}
+}
+
+// WithServerHistogramBuckets sets the bucket bounds of histograms created by an observation
+func WithServerHistogramBuckets(bounds ...float64) ServerOpt {
+	return func(cfg *ServerConfig) {
+		cfg.HistogramBuckets = bounds
+	}
+}
+
+// WithServerRetryDelays sets the pauses between attempts for retriable database errors, none disables retries
+func WithServerRetryDelays(delays ...time.Duration) ServerOpt {
+	return func(cfg *ServerConfig) {
+		cfg.RetryDelays = delays
+	}
+}
+
 // NewServerConfig creates a ServerConfig with optional functional parameters
 func NewServerConfig(opts ...ServerOpt) *ServerConfig {
 	cfg := &ServerConfig{
-		Address:  "localhost:8080",
-		LogLevel: "info",
+		Address:         "localhost:8080",
+		LogLevel:        "info",
+		StoreInterval:   300,
+		FileStoragePath: "/tmp/metrics-db.json",
+		Restore:         false,
+		RetryDelays:     append([]time.Duration(nil), retry.DefaultDelays...),
 	}
 	for _, opt := range opts {
 		opt(cfg)
 	}
 	return cfg
 }
+
+var serverSettings = []setting[ServerConfig]{
+	{
+		flags: []string{"a"},
+		env:   "ADDRESS",
+		key:   "address",
+		usage: "server address host:port",
+		parse: func(value string) (func(*ServerConfig), error) {
+			addr, err := parseAddress(value)
+			return WithServerAddress(addr), err
+		},
+	},
+	{
+		flags: []string{"l"},
+		env:   "LOG_LEVEL",
+		key:   "log_level",
+		usage: "log level",
+		parse: func(value string) (func(*ServerConfig), error) {
+			level, err := parseLogLevel(value)
+			return WithServerLogLevel(level), err
+		},
+	},
+	{
+		flags: []string{"i"},
+		env:   "STORE_INTERVAL",
+		key:   "store_interval",
+		usage: "snapshot interval in seconds, 0 makes writes synchronous",
+		parse: func(value string) (func(*ServerConfig), error) {
+			seconds, err := parseSeconds(value)
+			return WithServerStoreInterval(seconds), err
+		},
+	},
+	{
+		flags: []string{"f"},
+		env:   "FILE_STORAGE_PATH",
+		key:   "file_storage_path",
+		usage: "snapshot file path, empty disables file storage",
+		parse: func(value string) (func(*ServerConfig), error) {
+			return WithServerFileStoragePath(value), nil
+		},
+	},
+	{
+		flags:  []string{"r"},
+		env:    "RESTORE",
+		key:    "restore",
+		usage:  "restore metrics from the snapshot file on startup",
+		isBool: true,
+		parse: func(value string) (func(*ServerConfig), error) {
+			restore, err := strconv.ParseBool(value)
+			return WithServerRestore(restore), err
+		},
+	},
+	{
+		flags: []string{"d", "database-dsn"},
+		env:   "DATABASE_DSN",
+		key:   "database_dsn",
+		usage: "PostgreSQL DSN",
+		parse: func(value string) (func(*ServerConfig), error) {
+			return WithServerDatabaseDSN(value), nil
+		},
+	},
+	{
+		flags: []string{"k"},
+		env:   "KEY",
+		key:   "key",
+		usage: "shared secret for HMAC-SHA256 payload signatures",
+		parse: func(value string) (func(*ServerConfig), error) {
+			return WithServerKey(value), nil
+		},
+	},
+	{
+		flags: []string{"crypto-key"},
+		env:   "CRYPTO_KEY",
+		key:   "crypto_key",
+		usage: "path to the PEM private key for decrypting request bodies and the gRPC TLS certificate",
+		parse: func(value string) (func(*ServerConfig), error) {
+			return WithServerCryptoKey(value), nil
+		},
+	},
+	{
+		flags: []string{"t"},
+		env:   "TRUSTED_SUBNET",
+		key:   "trusted_subnet",
+		usage: "CIDR of agents allowed to update metrics, empty allows any",
+		parse: func(value string) (func(*ServerConfig), error) {
+			cidr, err := parseSubnet(value)
+			return WithServerTrustedSubnet(cidr), err
+		},
+	},
+	{
+		flags: []string{"g"},
+		env:   "GRPC_ADDRESS",
+		key:   "grpc_address",
+		usage: "gRPC server address host:port, empty disables gRPC",
+		parse: func(value string) (func(*ServerConfig), error) {
+			if value == "" {
+				return WithServerGRPCAddress(""), nil
+			}
+			addr, err := parseAddress(value)
+			return WithServerGRPCAddress(addr), err
+		},
+	},
+	{
+		flags: []string{"statsd"},
+		env:   "STATSD_ADDRESS",
+		key:   "statsd_address",
+		usage: "StatsD UDP listen address host:port, empty disables the listener; not allowed with -k or -crypto-key",
+		parse: func(value string) (func(*ServerConfig), error) {
+			if value == "" {
+				return WithServerStatsDAddress(""), nil
+			}
+			addr, err := parseAddress(value)
+			return WithServerStatsDAddress(addr), err
+		},
+	},
+	{
+		flags: []string{"graphite"},
+		env:   "GRAPHITE_ADDRESS",
+		key:   "graphite_address",
+		usage: "Graphite plaintext TCP listen address host:port, empty disables the listener; not allowed with -k or -crypto-key",
+		parse: func(value string) (func(*ServerConfig), error) {
+			if value == "" {
+				return WithServerGraphiteAddress(""), nil
+			}
+			addr, err := parseAddress(value)
+			return WithServerGraphiteAddress(addr), err
+		},
+	},
+	{
+		flags: []string{"graphite-counters"},
+		env:   "GRAPHITE_COUNTER_PREFIXES",
+		key:   "graphite_counter_prefixes",
+		usage: "comma separated Graphite path prefixes stored as counters, other paths are gauges",
+		parse: func(value string) (func(*ServerConfig), error) {
+			prefixes, err := parseList(value)
+			return WithServerGraphiteCounterPrefixes(prefixes...), err
+		},
+	},
+	{
+		flags: []string{"histogram-buckets"},
+		env:   "HISTOGRAM_BUCKETS",
+		key:   "histogram_buckets",
+		usage: "comma separated ascending bucket bounds of new histograms, empty uses the Prometheus defaults",
+		parse: func(value string) (func(*ServerConfig), error) {
+			bounds, err := parseBuckets(value)
+			return WithServerHistogramBuckets(bounds...), err
+		},
+	},
+	{
+		flags: []string{"retry-delays"},
+		env:   "RETRY_DELAYS",
+		key:   "retry_delays",
+		usage: "comma separated pauses between attempts for retriable database errors, empty disables retries",
+		parse: func(value string) (func(*ServerConfig), error) {
+			delays, err := parseDelays(value)
+			return WithServerRetryDelays(delays...), err
+		},
+	},
+}
+
+// LoadServerConfig builds a ServerConfig from, in increasing order of precedence,
+// the defaults, the JSON file given by -c/-config or CONFIG, command line flags
+// and environment variables.
+func LoadServerConfig(args []string, lookupEnv LookupEnvFunc) (*ServerConfig, error) {
+	cfg := NewServerConfig()
+	if err := load("server", cfg, serverSettings, args, lookupEnv); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
@@ -40,3 +40,29 @@ func TestNewServerConfig_WithMultipleOpts(t *testing.T) {
 	assert.Equal(t, "0.0.0.0:1234", cfg.Address)
 	assert.Equal(t, "warn", cfg.LogLevel)
 }
+
+func TestNewServerConfig_FileStorage(t *testing.T) {
+	cfg := NewServerConfig()
+
+	assert.Equal(t, 300, cfg.StoreInterval)
+	assert.Equal(t, "/tmp/metrics-db.json", cfg.FileStoragePath)
+	assert.False(t, cfg.Restore)
+
+	cfg = NewServerConfig(
+		WithServerStoreInterval(0),
+		WithServerFileStoragePath("/var/lib/metrics.json"),
+		WithServerRestore(true),
+	)
+
+	assert.Equal(t, 0, cfg.StoreInterval)
+	assert.Equal(t, "/var/lib/metrics.json", cfg.FileStoragePath)
+	assert.True(t, cfg.Restore)
+}
+
+func TestNewServerConfig_WithDatabaseDSN(t *testing.T) {
+	cfg := NewServerConfig()
+	assert.Empty(t, cfg.DatabaseDSN)
+
+	cfg = NewServerConfig(WithServerDatabaseDSN("postgres://localhost/db"))
+	assert.Equal(t, "postgres://localhost/db", cfg.DatabaseDSN)
+}
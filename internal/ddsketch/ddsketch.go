@@ -0,0 +1,246 @@
+// Package ddsketch implements DDSketch, a mergeable quantile sketch with a
+// relative error guarantee: every quantile it returns is within
+// RelativeAccuracy of the true value, whatever the distribution.
+package ddsketch
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// DefaultRelativeAccuracy is the accuracy of sketches created for a single
+// observation.
+const DefaultRelativeAccuracy = 0.01
+
+// maxBins bounds the bins of each store. When exceeded the bins of the values
+// closest to zero are collapsed, so the high quantiles keep their accuracy.
+const maxBins = 2048
+
+// ErrAccuracyMismatch is returned when sketches with a different relative
+// accuracy are merged.
+var ErrAccuracyMismatch = errors.New("sketch relative accuracy differs")
+
+// Sketch counts observations in logarithmically sized bins. Bin i of Positive
+// holds the values in (gamma^(i-1), gamma^i], Negative does the same for the
+// absolute value of negative observations, and Zero counts zeros.
+type Sketch struct {
+	RelativeAccuracy float64          `json:"relative_accuracy"`
+	Positive         map[int32]uint64 `json:"positive,omitempty"`
+	Negative         map[int32]uint64 `json:"negative,omitempty"`
+	Zero             uint64           `json:"zero,omitempty"`
+	Count            uint64           `json:"count"`
+	Sum              float64          `json:"sum"`
+	Min              float64          `json:"min"`
+	Max              float64          `json:"max"`
+}
+
+// New creates an empty sketch.
+func New(relativeAccuracy float64) (*Sketch, error) {
+	if err := validateAccuracy(relativeAccuracy); err != nil {
+		return nil, err
+	}
+	return &Sketch{RelativeAccuracy: relativeAccuracy}, nil
+}
+
+// validateAccuracy keeps the bin indexes of every finite value within int32.
+func validateAccuracy(relativeAccuracy float64) error {
+	if !(relativeAccuracy >= 1e-6 && relativeAccuracy < 1) {
+		return fmt.Errorf("relative accuracy %v is not in [1e-6, 1)", relativeAccuracy)
+	}
+	return nil
+}
+
+// Validate checks a sketch received from a client.
+func (s *Sketch) Validate() error {
+	if err := validateAccuracy(s.RelativeAccuracy); err != nil {
+		return err
+	}
+	total := s.Zero
+	for _, c := range s.Positive {
+		total += c
+	}
+	for _, c := range s.Negative {
+		total += c
+	}
+	if total != s.Count {
+		return fmt.Errorf("sketch count %d does not match the bin total %d", s.Count, total)
+	}
+	for _, v := range []float64{s.Sum, s.Min, s.Max} {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return errors.New("sketch sum, min and max must be finite")
+		}
+	}
+	if s.Count > 0 && s.Min > s.Max {
+		return errors.New("sketch min is greater than max")
+	}
+	return nil
+}
+
+func (s *Sketch) gamma() float64 {
+	return (1 + s.RelativeAccuracy) / (1 - s.RelativeAccuracy)
+}
+
+func (s *Sketch) index(v float64) int32 {
+	return int32(math.Ceil(math.Log(v) / math.Log(s.gamma())))
+}
+
+// value returns the estimate for bin i, the point with the same relative
+// distance to both bin bounds.
+func (s *Sketch) value(i int32) float64 {
+	gamma := s.gamma()
+	return 2 * math.Pow(gamma, float64(i)) / (gamma + 1)
+}
+
+// Add adds an observation, which must be finite.
+func (s *Sketch) Add(v float64) {
+	switch {
+	case v > 0:
+		if s.Positive == nil {
+			s.Positive = make(map[int32]uint64)
+		}
+		s.Positive[s.index(v)]++
+		collapse(s.Positive)
+	case v < 0:
+		if s.Negative == nil {
+			s.Negative = make(map[int32]uint64)
+		}
+		s.Negative[s.index(-v)]++
+		collapse(s.Negative)
+	default:
+		s.Zero++
+	}
+
+	if s.Count == 0 || v < s.Min {
+		s.Min = v
+	}
+	if s.Count == 0 || v > s.Max {
+		s.Max = v
+	}
+	s.Count++
+	s.Sum += v
+}
+
+// Merge adds the observations of other, which must have the same accuracy.
+func (s *Sketch) Merge(other *Sketch) error {
+	if s.RelativeAccuracy != other.RelativeAccuracy {
+		return ErrAccuracyMismatch
+	}
+	if other.Count == 0 {
+		return nil
+	}
+
+	s.Positive = mergeBins(s.Positive, other.Positive)
+	s.Negative = mergeBins(s.Negative, other.Negative)
+	s.Zero += other.Zero
+
+	if s.Count == 0 || other.Min < s.Min {
+		s.Min = other.Min
+	}
+	if s.Count == 0 || other.Max > s.Max {
+		s.Max = other.Max
+	}
+	s.Count += other.Count
+	s.Sum += other.Sum
+	return nil
+}
+
+func mergeBins(dst, src map[int32]uint64) map[int32]uint64 {
+	if len(src) == 0 {
+		return dst
+	}
+	if dst == nil {
+		dst = make(map[int32]uint64, len(src))
+	}
+	for i, c := range src {
+		dst[i] += c
+	}
+	collapse(dst)
+	return dst
+}
+
+// collapse folds the lowest bins into the lowest remaining one until at most
+// maxBins remain.
+func collapse(bins map[int32]uint64) {
+	if len(bins) <= maxBins {
+		return
+	}
+	indexes := sortedIndexes(bins)
+	lowest := indexes[len(indexes)-maxBins]
+	for _, i := range indexes[:len(indexes)-maxBins] {
+		bins[lowest] += bins[i]
+		delete(bins, i)
+	}
+}
+
+func sortedIndexes(bins map[int32]uint64) []int32 {
+	indexes := make([]int32, 0, len(bins))
+	for i := range bins {
+		indexes = append(indexes, i)
+	}
+	sort.Slice(indexes, func(a, b int) bool { return indexes[a] < indexes[b] })
+	return indexes
+}
+
+// Quantile estimates the q-quantile, q in [0, 1]. It returns NaN for an
+// empty sketch.
+func (s *Sketch) Quantile(q float64) float64 {
+	if s.Count == 0 || q < 0 || q > 1 {
+		return math.NaN()
+	}
+
+	rank := q * float64(s.Count-1)
+	var seen float64
+
+	estimate := s.Max
+	found := false
+
+	// Negative values from the most negative one, zeros, then positive values.
+	negative := sortedIndexes(s.Negative)
+	for i := len(negative) - 1; i >= 0 && !found; i-- {
+		seen += float64(s.Negative[negative[i]])
+		if seen > rank {
+			estimate, found = -s.value(negative[i]), true
+		}
+	}
+	if !found {
+		seen += float64(s.Zero)
+		if seen > rank {
+			estimate, found = 0, true
+		}
+	}
+	for _, i := range sortedIndexes(s.Positive) {
+		if found {
+			break
+		}
+		seen += float64(s.Positive[i])
+		if seen > rank {
+			estimate, found = s.value(i), true
+		}
+	}
+
+	return math.Max(s.Min, math.Min(s.Max, estimate))
+}
+
+// Clone returns a deep copy, nil stays nil.
+func (s *Sketch) Clone() *Sketch {
+	if s == nil {
+		return nil
+	}
+	c := *s
+	c.Positive = cloneBins(s.Positive)
+	c.Negative = cloneBins(s.Negative)
+	return &c
+}
+
+func cloneBins(bins map[int32]uint64) map[int32]uint64 {
+	if bins == nil {
+		return nil
+	}
+	c := make(map[int32]uint64, len(bins))
+	for i, n := range bins {
+		c[i] = n
+	}
+	return c
+}
@@ -0,0 +1,136 @@
+package ddsketch
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// exactQuantile uses the same rank as Sketch.Quantile.
+func exactQuantile(sorted []float64, q float64) float64 {
+	return sorted[int(q*float64(len(sorted)-1))]
+}
+
+func TestSketch_RelativeAccuracy(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+
+	distributions := map[string]func() float64{
+		"uniform":     func() float64 { return rnd.Float64() * 1000 },
+		"exponential": func() float64 { return rnd.ExpFloat64() * 0.2 },
+		"lognormal":   func() float64 { return math.Exp(rnd.NormFloat64() * 3) },
+		"signed":      func() float64 { return rnd.NormFloat64() * 50 },
+	}
+
+	for name, next := range distributions {
+		t.Run(name, func(t *testing.T) {
+			s, err := New(DefaultRelativeAccuracy)
+			require.NoError(t, err)
+
+			values := make([]float64, 10000)
+			for i := range values {
+				values[i] = next()
+				s.Add(values[i])
+			}
+			sort.Float64s(values)
+
+			for _, q := range []float64{0, 0.01, 0.25, 0.5, 0.9, 0.95, 0.99, 1} {
+				expected := exactQuantile(values, q)
+				assert.InDelta(t, expected, s.Quantile(q), math.Abs(expected)*DefaultRelativeAccuracy+1e-12, "q=%v", q)
+			}
+			assert.Equal(t, uint64(len(values)), s.Count)
+			assert.Equal(t, values[0], s.Min)
+			assert.Equal(t, values[len(values)-1], s.Max)
+		})
+	}
+}
+
+func TestSketch_Merge(t *testing.T) {
+	a, _ := New(DefaultRelativeAccuracy)
+	b, _ := New(DefaultRelativeAccuracy)
+	all, _ := New(DefaultRelativeAccuracy)
+
+	for i := 1; i <= 1000; i++ {
+		v := float64(i)
+		if i%2 == 0 {
+			a.Add(v)
+		} else {
+			b.Add(-v)
+			v = -v
+		}
+		all.Add(v)
+	}
+	a.Add(0)
+	all.Add(0)
+
+	clone := a.Clone()
+	require.NoError(t, a.Merge(b))
+	assert.Equal(t, all, a)
+	assert.Equal(t, uint64(501), clone.Count, "a clone does not share bins")
+
+	other, _ := New(0.05)
+	other.Add(1)
+	assert.ErrorIs(t, a.Merge(other), ErrAccuracyMismatch)
+}
+
+func TestSketch_Collapse(t *testing.T) {
+	s, _ := New(0.001)
+	for i := 0; i < 10000; i++ {
+		s.Add(math.Pow(1.01, float64(i%5000)))
+	}
+
+	assert.LessOrEqual(t, len(s.Positive), maxBins)
+	assert.NoError(t, s.Validate())
+	assert.InDelta(t, s.Max, s.Quantile(1), s.Max*0.001)
+	assert.InDelta(t, math.Pow(1.01, 4949), s.Quantile(0.99), math.Pow(1.01, 4949)*0.011)
+}
+
+func TestSketch_Empty(t *testing.T) {
+	s, _ := New(DefaultRelativeAccuracy)
+	assert.True(t, math.IsNaN(s.Quantile(0.5)))
+
+	s.Add(3)
+	assert.True(t, math.IsNaN(s.Quantile(1.5)))
+	assert.Equal(t, 3.0, s.Quantile(0.5))
+}
+
+func TestSketch_JSON(t *testing.T) {
+	s, _ := New(DefaultRelativeAccuracy)
+	for _, v := range []float64{-2, 0, 0.5, 10} {
+		s.Add(v)
+	}
+
+	data, err := json.Marshal(s)
+	require.NoError(t, err)
+
+	var decoded Sketch
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, s, &decoded)
+	assert.NoError(t, decoded.Validate())
+}
+
+func TestSketch_Validate(t *testing.T) {
+	tests := []struct {
+		name   string
+		sketch Sketch
+	}{
+		{name: "zero accuracy", sketch: Sketch{}},
+		{name: "accuracy of one", sketch: Sketch{RelativeAccuracy: 1}},
+		{name: "count does not match", sketch: Sketch{RelativeAccuracy: 0.01, Positive: map[int32]uint64{1: 2}, Count: 1}},
+		{name: "infinite sum", sketch: Sketch{RelativeAccuracy: 0.01, Zero: 1, Count: 1, Sum: math.Inf(1)}},
+		{name: "min above max", sketch: Sketch{RelativeAccuracy: 0.01, Zero: 1, Count: 1, Min: 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Error(t, tt.sketch.Validate())
+		})
+	}
+
+	_, err := New(0)
+	assert.Error(t, err)
+}
@@ -0,0 +1,185 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Header marks an encrypted request body, its value names the scheme.
+const Header = "Content-Encryption"
+
+// Scheme is the only supported encryption scheme: a random AES-256-GCM key
+// encrypts the body and is itself wrapped with RSA-OAEP (SHA-256).
+const Scheme = "rsa-oaep-aes256gcm"
+
+// ErrMalformed is returned when a ciphertext cannot be decoded or authenticated.
+var ErrMalformed = errors.New("malformed ciphertext")
+
+// Encrypt seals plaintext for the owner of pub. The result is laid out as
+// a 2-byte length of the wrapped key, the wrapped key, the GCM nonce and the
+// GCM ciphertext, so payloads of any size are supported.
+func Encrypt(pub *rsa.PublicKey, plaintext []byte) ([]byte, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+
+	wrapped, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, pub, key, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 2, 2+len(wrapped)+len(nonce)+len(plaintext)+gcm.Overhead())
+	binary.BigEndian.PutUint16(out, uint16(len(wrapped)))
+	out = append(out, wrapped...)
+	out = append(out, nonce...)
+
+	return gcm.Seal(out, nonce, plaintext, nil), nil
+}
+
+// Decrypt opens a ciphertext produced by Encrypt with the matching private key.
+func Decrypt(priv *rsa.PrivateKey, ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) < 2 {
+		return nil, ErrMalformed
+	}
+	wrappedLen := int(binary.BigEndian.Uint16(ciphertext))
+	ciphertext = ciphertext[2:]
+	if len(ciphertext) < wrappedLen {
+		return nil, ErrMalformed
+	}
+
+	key, err := rsa.DecryptOAEP(sha256.New(), nil, priv, ciphertext[:wrappedLen], nil)
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	ciphertext = ciphertext[wrappedLen:]
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, ErrMalformed
+	}
+
+	plaintext, err := gcm.Open(nil, ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, ErrMalformed
+	}
+
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// GenerateKey creates an RSA private key of the given size in bits.
+func GenerateKey(bits int) (*rsa.PrivateKey, error) {
+	return rsa.GenerateKey(rand.Reader, bits)
+}
+
+// EncodePrivateKey returns the PKCS#8 PEM encoding of key.
+func EncodePrivateKey(key *rsa.PrivateKey) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// EncodePublicKey returns the PKIX PEM encoding of key.
+func EncodePublicKey(key *rsa.PublicKey) ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
+
+// LoadPrivateKey reads a PEM encoded RSA private key in PKCS#1 or PKCS#8 form.
+func LoadPrivateKey(path string) (*rsa.PrivateKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		rsaKey, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("%s: not an RSA private key", path)
+		}
+		return rsaKey, nil
+	default:
+		return nil, fmt.Errorf("%s: unexpected PEM block %q", path, block.Type)
+	}
+}
+
+// LoadPublicKey reads a PEM encoded RSA public key in PKIX or PKCS#1 form.
+func LoadPublicKey(path string) (*rsa.PublicKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch block.Type {
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	case "PUBLIC KEY":
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		rsaKey, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("%s: not an RSA public key", path)
+		}
+		return rsaKey, nil
+	default:
+		return nil, fmt.Errorf("%s: unexpected PEM block %q", path, block.Type)
+	}
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM data found", path)
+	}
+
+	return block, nil
+}
@@ -0,0 +1,117 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := GenerateKey(2048)
+	require.NoError(t, err)
+	return key
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	key := testKey(t)
+
+	tests := []struct {
+		name      string
+		plaintext []byte
+	}{
+		{name: "empty", plaintext: []byte{}},
+		{name: "small", plaintext: []byte(`[{"id":"a","type":"gauge","value":1}]`)},
+		{name: "larger than the RSA modulus", plaintext: bytes.Repeat([]byte("metric"), 10000)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ciphertext, err := Encrypt(&key.PublicKey, tt.plaintext)
+			require.NoError(t, err)
+			if len(tt.plaintext) > 0 {
+				assert.NotContains(t, string(ciphertext), string(tt.plaintext))
+			}
+
+			plaintext, err := Decrypt(key, ciphertext)
+			require.NoError(t, err)
+			assert.Equal(t, string(tt.plaintext), string(plaintext))
+		})
+	}
+}
+
+func TestDecrypt_Errors(t *testing.T) {
+	key := testKey(t)
+
+	ciphertext, err := Encrypt(&key.PublicKey, []byte("payload"))
+	require.NoError(t, err)
+
+	tampered := bytes.Clone(ciphertext)
+	tampered[len(tampered)-1] ^= 0xff
+
+	tests := []struct {
+		name       string
+		key        *rsa.PrivateKey
+		ciphertext []byte
+	}{
+		{name: "empty", key: key, ciphertext: nil},
+		{name: "truncated key", key: key, ciphertext: ciphertext[:100]},
+		{name: "truncated nonce", key: key, ciphertext: ciphertext[:2+256+4]},
+		{name: "tampered body", key: key, ciphertext: tampered},
+		{name: "wrong key", key: testKey(t), ciphertext: ciphertext},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decrypt(tt.key, tt.ciphertext)
+			assert.ErrorIs(t, err, ErrMalformed)
+		})
+	}
+}
+
+func TestLoadKeys(t *testing.T) {
+	key := testKey(t)
+	dir := t.TempDir()
+
+	write := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, data, 0o600))
+		return path
+	}
+
+	privatePEM, err := EncodePrivateKey(key)
+	require.NoError(t, err)
+	publicPEM, err := EncodePublicKey(&key.PublicKey)
+	require.NoError(t, err)
+
+	pkcs1Private := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	pkcs1Public := pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(&key.PublicKey)})
+
+	for _, path := range []string{write("private.pem", privatePEM), write("private-pkcs1.pem", pkcs1Private)} {
+		loaded, err := LoadPrivateKey(path)
+		require.NoError(t, err, path)
+		assert.True(t, key.Equal(loaded), path)
+	}
+
+	for _, path := range []string{write("public.pem", publicPEM), write("public-pkcs1.pem", pkcs1Public)} {
+		loaded, err := LoadPublicKey(path)
+		require.NoError(t, err, path)
+		assert.True(t, key.PublicKey.Equal(loaded), path)
+	}
+
+	_, err = LoadPrivateKey(write("public-as-private.pem", publicPEM))
+	assert.Error(t, err)
+	_, err = LoadPublicKey(write("private-as-public.pem", privatePEM))
+	assert.Error(t, err)
+	_, err = LoadPublicKey(write("garbage.pem", []byte("not pem")))
+	assert.Error(t, err)
+	_, err = LoadPrivateKey(filepath.Join(dir, "missing.pem"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,75 @@
+package encryption
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"time"
+)
+
+// ErrUnknownServer is returned by a client TLS handshake with a server whose
+// certificate does not carry the provisioned public key.
+var ErrUnknownServer = errors.New("server certificate does not match the public key")
+
+// serverCertValidity is how long the self-signed certificate is valid, it is
+// created anew on every start.
+const serverCertValidity = 10 * 365 * 24 * time.Hour
+
+// ServerTLSConfig returns a TLS configuration presenting a self-signed
+// certificate for key. There is no CA: clients authenticate the server by the
+// matching public key, see ClientTLSConfig.
+func ServerTLSConfig(key *rsa.PrivateKey) (*tls.Config, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "metrics server"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(serverCertValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+// ClientTLSConfig returns a TLS configuration that accepts only a server
+// whose certificate carries pub. The handshake proves that the server holds
+// the matching private key, so the chain and host name are not checked.
+func ClientTLSConfig(pub *rsa.PublicKey) *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		// The certificate is verified against pub below instead of a CA.
+		InsecureSkipVerify: true,
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				return ErrUnknownServer
+			}
+			cert, err := x509.ParseCertificate(rawCerts[0])
+			if err != nil {
+				return err
+			}
+			certKey, ok := cert.PublicKey.(*rsa.PublicKey)
+			if !ok || !certKey.Equal(pub) {
+				return ErrUnknownServer
+			}
+			return nil
+		},
+	}
+}
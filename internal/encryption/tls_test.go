@@ -0,0 +1,56 @@
+package encryption
+
+import (
+	"crypto/rsa"
+	"crypto/tls"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTLSConfig(t *testing.T) {
+	key := testKey(t)
+
+	serverConfig, err := ServerTLSConfig(key)
+	require.NoError(t, err)
+
+	lis, err := tls.Listen("tcp", "127.0.0.1:0", serverConfig)
+	require.NoError(t, err)
+	defer lis.Close()
+
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			// The handshake runs on the first read, the client closes first.
+			go func() {
+				defer conn.Close()
+				conn.Read(make([]byte, 1))
+			}()
+		}
+	}()
+
+	tests := []struct {
+		name        string
+		pub         *rsa.PublicKey
+		expectedErr error
+	}{
+		{name: "matching public key", pub: &key.PublicKey},
+		{name: "other public key", pub: &testKey(t).PublicKey, expectedErr: ErrUnknownServer},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := tls.Dial("tcp", lis.Addr().String(), ClientTLSConfig(tt.pub))
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			conn.Close()
+		})
+	}
+}
@@ -0,0 +1,209 @@
+package facades
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/encryption"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/hash"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/middlewares"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/models"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/retry"
+)
+
+// MetricHTTPFacadeOpt is a functional option for configuring MetricHTTPFacade
+type MetricHTTPFacadeOpt func(*MetricHTTPFacade)
+
+// WithMetricFacadeClient sets the HTTP client used to reach the server
+func WithMetricFacadeClient(client *resty.Client) MetricHTTPFacadeOpt {
+	return func(f *MetricHTTPFacade) {
+		f.client = client
+	}
+}
+
+// WithMetricFacadeServerAddress sets the metrics server address
+func WithMetricFacadeServerAddress(addr string) MetricHTTPFacadeOpt {
+	return func(f *MetricHTTPFacade) {
+		f.serverAddress = addr
+	}
+}
+
+// WithMetricFacadeCompression enables or disables gzip compression of request bodies
+func WithMetricFacadeCompression(enabled bool) MetricHTTPFacadeOpt {
+	return func(f *MetricHTTPFacade) {
+		f.compress = enabled
+	}
+}
+
+// WithMetricFacadeKey sets the shared secret used to sign request bodies
+func WithMetricFacadeKey(key string) MetricHTTPFacadeOpt {
+	return func(f *MetricHTTPFacade) {
+		f.key = key
+	}
+}
+
+// WithMetricFacadePublicKey sets the server's public key used to encrypt request bodies
+func WithMetricFacadePublicKey(key *rsa.PublicKey) MetricHTTPFacadeOpt {
+	return func(f *MetricHTTPFacade) {
+		f.publicKey = key
+	}
+}
+
+// WithMetricFacadeRetryDelays sets the pauses between attempts for retriable failures
+func WithMetricFacadeRetryDelays(delays ...time.Duration) MetricHTTPFacadeOpt {
+	return func(f *MetricHTTPFacade) {
+		f.retryDelays = delays
+	}
+}
+
+// MetricHTTPFacade sends metrics to the server over HTTP.
+type MetricHTTPFacade struct {
+	client        *resty.Client
+	serverAddress string
+	compress      bool
+	key           string
+	publicKey     *rsa.PublicKey
+	retryDelays   []time.Duration
+}
+
+func NewMetricHTTPFacade(opts ...MetricHTTPFacadeOpt) *MetricHTTPFacade {
+	f := &MetricHTTPFacade{
+		client:      resty.New(),
+		compress:    true,
+		retryDelays: retry.DefaultDelays,
+	}
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
+}
+
+// Updates sends the metrics as a single JSON batch to the /updates/ route,
+// gzip-compressed unless compression is disabled and encrypted when a public
+// key is set. Network failures and 5xx responses are retried; any other
+// response is returned as an error at once.
+func (f *MetricHTTPFacade) Updates(
+	ctx context.Context,
+	metrics []*models.Metrics,
+) error {
+	batch := make([]*models.Metrics, 0, len(metrics))
+	for _, metric := range metrics {
+		if metric != nil {
+			batch = append(batch, metric)
+		}
+	}
+
+	if len(batch) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+
+	headers := map[string]string{
+		"Content-Type":    "application/json",
+		"Accept-Encoding": "gzip",
+	}
+
+	// The server may only accept agents from a trusted subnet, so report the
+	// address of the interface the request leaves through.
+	if ip, err := f.outboundIP(); err == nil {
+		headers[middlewares.RealIPHeader] = ip.String()
+	}
+
+	// The signature covers the uncompressed body, which is what the server
+	// sees after its gzip middleware.
+	if f.key != "" {
+		headers[hash.Header] = hash.Sign(body, f.key)
+	}
+
+	if f.compress {
+		body, err = compress(body)
+		if err != nil {
+			return err
+		}
+		headers["Content-Encoding"] = "gzip"
+	}
+
+	// Encryption is the outermost layer, the server removes it first.
+	if f.publicKey != nil {
+		body, err = encryption.Encrypt(f.publicKey, body)
+		if err != nil {
+			return err
+		}
+		headers[encryption.Header] = encryption.Scheme
+	}
+
+	return retry.Do(ctx, f.retryDelays, retry.IsRetriable, func(ctx context.Context) error {
+		resp, err := f.client.R().
+			SetContext(ctx).
+			SetHeaders(headers).
+			SetBody(body).
+			Post(f.baseURL() + "/updates/")
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case resp.StatusCode() >= http.StatusInternalServerError:
+			return retry.MarkRetriable(fmt.Errorf("unexpected status code %d", resp.StatusCode()))
+		case resp.StatusCode() != http.StatusOK:
+			return fmt.Errorf("unexpected status code %d", resp.StatusCode())
+		}
+
+		return nil
+	})
+}
+
+func (f *MetricHTTPFacade) baseURL() string {
+	if strings.HasPrefix(f.serverAddress, "http://") || strings.HasPrefix(f.serverAddress, "https://") {
+		return f.serverAddress
+	}
+	return "http://" + f.serverAddress
+}
+
+func (f *MetricHTTPFacade) outboundIP() (net.IP, error) {
+	u, err := url.Parse(f.baseURL())
+	if err != nil {
+		return nil, err
+	}
+	return outboundIP(u.Host)
+}
+
+// outboundIP returns the local address used to reach hostPort. Dialing UDP
+// only consults the routing table, no packet is sent.
+func outboundIP(hostPort string) (net.IP, error) {
+	conn, err := net.Dial("udp", hostPort)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	return conn.LocalAddr().(*net.UDPAddr).IP, nil
+}
+
+func compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(data); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
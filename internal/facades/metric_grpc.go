@@ -0,0 +1,422 @@
+package facades
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/interceptors"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/logger"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/models"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/pb"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/retry"
+)
+
+// MetricGRPCFacadeOpt is a functional option for configuring MetricGRPCFacade
+type MetricGRPCFacadeOpt func(*MetricGRPCFacade)
+
+// WithMetricGRPCFacadeServerAddress sets the gRPC server address host:port
+func WithMetricGRPCFacadeServerAddress(addr string) MetricGRPCFacadeOpt {
+	return func(f *MetricGRPCFacade) {
+		f.serverAddress = addr
+	}
+}
+
+// WithMetricGRPCFacadeKey sets the shared secret used to sign requests
+func WithMetricGRPCFacadeKey(key string) MetricGRPCFacadeOpt {
+	return func(f *MetricGRPCFacade) {
+		f.key = key
+	}
+}
+
+// WithMetricGRPCFacadeTLS makes the connection use TLS with the given configuration
+func WithMetricGRPCFacadeTLS(cfg *tls.Config) MetricGRPCFacadeOpt {
+	return func(f *MetricGRPCFacade) {
+		f.creds = credentials.NewTLS(cfg)
+	}
+}
+
+// WithMetricGRPCFacadeRetryDelays sets the pauses between attempts for retriable failures
+func WithMetricGRPCFacadeRetryDelays(delays ...time.Duration) MetricGRPCFacadeOpt {
+	return func(f *MetricGRPCFacade) {
+		f.retryDelays = delays
+	}
+}
+
+// WithMetricGRPCFacadeDialOptions appends options used to create the client connection
+func WithMetricGRPCFacadeDialOptions(opts ...grpc.DialOption) MetricGRPCFacadeOpt {
+	return func(f *MetricGRPCFacade) {
+		f.dialOptions = append(f.dialOptions, opts...)
+	}
+}
+
+// WithMetricGRPCFacadeStreaming makes the facade send metrics over a single
+// long-lived StreamUpdates call instead of one UpdateBatch call per report
+func WithMetricGRPCFacadeStreaming() MetricGRPCFacadeOpt {
+	return func(f *MetricGRPCFacade) {
+		f.streaming = true
+	}
+}
+
+// WithMetricGRPCFacadeStreamAckTimeout sets how long a streamed report waits
+// for the server to acknowledge it before the stream is considered broken
+func WithMetricGRPCFacadeStreamAckTimeout(d time.Duration) MetricGRPCFacadeOpt {
+	return func(f *MetricGRPCFacade) {
+		f.ackTimeout = d
+	}
+}
+
+const (
+	// defaultStreamAckTimeout leaves the server several ack intervals.
+	defaultStreamAckTimeout = 10 * time.Second
+	// streamCloseTimeout bounds how long Close waits for the final summary.
+	streamCloseTimeout = 5 * time.Second
+)
+
+// MetricGRPCFacade sends metrics to the server over gRPC.
+type MetricGRPCFacade struct {
+	serverAddress string
+	key           string
+	creds         credentials.TransportCredentials
+	retryDelays   []time.Duration
+	dialOptions   []grpc.DialOption
+	streaming     bool
+	ackTimeout    time.Duration
+
+	conn   *grpc.ClientConn
+	client pb.MetricsServiceClient
+
+	// mu serialises senders on the stream, gRPC streams are not safe for
+	// concurrent SendMsg calls.
+	mu     sync.Mutex
+	stream *metricStream
+}
+
+// metricStream is an open StreamUpdates call and the goroutine reading its
+// summaries.
+type metricStream struct {
+	stream pb.MetricsService_StreamUpdatesClient
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	// err is written by the reader and may be read once done is closed.
+	err error
+
+	// sent is the number of metrics written to the stream, guarded by the
+	// facade's mu.
+	sent int64
+
+	ackMu sync.Mutex
+	// acked is the number of metrics the server has processed. Metrics are
+	// processed in order, so these are the first acked ones sent.
+	acked int64
+	// ackCh is closed and replaced whenever acked grows.
+	ackCh chan struct{}
+}
+
+// NewMetricGRPCFacade creates the facade and its client connection. The
+// connection is established lazily on the first call.
+func NewMetricGRPCFacade(opts ...MetricGRPCFacadeOpt) (*MetricGRPCFacade, error) {
+	f := &MetricGRPCFacade{
+		retryDelays: retry.DefaultDelays,
+		ackTimeout:  defaultStreamAckTimeout,
+		creds:       insecure.NewCredentials(),
+	}
+	for _, opt := range opts {
+		opt(f)
+	}
+
+	// The agent address may be given with an HTTP scheme, gRPC only needs host:port.
+	target := strings.TrimPrefix(strings.TrimPrefix(f.serverAddress, "http://"), "https://")
+	realIP := func() (net.IP, error) {
+		return outboundIP(target)
+	}
+
+	dialOptions := append([]grpc.DialOption{
+		grpc.WithTransportCredentials(f.creds),
+		grpc.WithChainUnaryInterceptor(
+			interceptors.RealIPClientInterceptor(realIP),
+			interceptors.HashClientInterceptor(f.key),
+		),
+		grpc.WithChainStreamInterceptor(
+			interceptors.RealIPStreamClientInterceptor(realIP),
+			interceptors.HashStreamClientInterceptor(f.key),
+		),
+	}, f.dialOptions...)
+
+	conn, err := grpc.NewClient(target, dialOptions...)
+	if err != nil {
+		return nil, err
+	}
+
+	f.conn = conn
+	f.client = pb.NewMetricsServiceClient(conn)
+
+	return f, nil
+}
+
+// Updates sends the metrics as a single UpdateBatch call, or on the open
+// stream when streaming is enabled. Unavailable and Aborted responses are
+// retried; any other failure is returned at once.
+//
+// A streamed report returns once the server has acknowledged every metric.
+// Retries resend only the unacknowledged ones, and a failure after part of
+// the report was acknowledged is a *models.PartialUpdateError.
+func (f *MetricGRPCFacade) Updates(
+	ctx context.Context,
+	metrics []*models.Metrics,
+) error {
+	sources := make([]*models.Metrics, 0, len(metrics))
+	payload := make([]*pb.Metric, 0, len(metrics))
+	for _, metric := range metrics {
+		if metric != nil {
+			sources = append(sources, metric)
+			payload = append(payload, pb.FromModel(metric))
+		}
+	}
+
+	if len(payload) == 0 {
+		return nil
+	}
+
+	send := func(ctx context.Context) error {
+		_, err := f.client.UpdateBatch(ctx, &pb.UpdateBatchRequest{Metrics: payload})
+		return err
+	}
+	pending := payload
+	if f.streaming {
+		send = func(ctx context.Context) error {
+			var err error
+			pending, err = f.sendStream(ctx, pending)
+			return err
+		}
+	}
+
+	err := retry.Do(ctx, f.retryDelays, retry.IsRetriable, func(ctx context.Context) error {
+		err := send(ctx)
+		switch status.Code(err) {
+		case codes.OK:
+			return nil
+		case codes.Unavailable, codes.Aborted:
+			return retry.MarkRetriable(err)
+		default:
+			return err
+		}
+	})
+	if err != nil && len(pending) < len(payload) {
+		return &models.PartialUpdateError{Pending: sources[len(payload)-len(pending):], Err: err}
+	}
+	return err
+}
+
+// sendStream writes the metrics to the open stream, opening one if needed,
+// and waits until the server acknowledges them. It returns the metrics that
+// were not acknowledged. A broken stream is dropped so that the next attempt
+// opens a new one. Send blocks while the server applies backpressure;
+// cancelling ctx aborts the stream rather than leaving the caller stuck.
+func (f *MetricGRPCFacade) sendStream(ctx context.Context, metrics []*pb.Metric) ([]*pb.Metric, error) {
+	f.mu.Lock()
+
+	if f.stream == nil {
+		s, err := f.openStream()
+		if err != nil {
+			f.mu.Unlock()
+			return metrics, err
+		}
+		f.stream = s
+	}
+
+	s := f.stream
+	start := s.sent
+
+	stop := context.AfterFunc(ctx, s.cancel)
+	for _, metric := range metrics {
+		if err := s.stream.Send(&pb.StreamUpdatesRequest{Metric: metric}); err != nil {
+			stop()
+			f.stream = nil
+			f.mu.Unlock()
+
+			// Send only reports io.EOF for a stream ended by the server, the
+			// actual status and the last summary are delivered to the reader.
+			if errors.Is(err, io.EOF) {
+				<-s.done
+				err = s.streamErr()
+			}
+			s.cancel()
+			<-s.done
+			return metrics[s.ackedSince(start, len(metrics)):], err
+		}
+		s.sent++
+	}
+	stop()
+	end := s.sent
+	f.mu.Unlock()
+
+	// Other reports may be written to the stream meanwhile, only the metrics
+	// up to end have to be acknowledged. A server that stops acknowledging is
+	// treated as a broken stream.
+	waitCtx, cancel := context.WithTimeout(ctx, f.ackTimeout)
+	defer cancel()
+
+	if err := s.waitAcked(waitCtx, end); err != nil {
+		switch {
+		case ctx.Err() != nil:
+		case errors.Is(err, context.DeadlineExceeded):
+			f.dropStream(s)
+			<-s.done
+			err = status.Error(codes.Unavailable, "stream not acknowledged by server")
+		default:
+			f.dropStream(s)
+		}
+		return metrics[s.ackedSince(start, len(metrics)):], err
+	}
+
+	return nil, nil
+}
+
+// dropStream forgets a broken stream so that the next report opens a new one.
+func (f *MetricGRPCFacade) dropStream(s *metricStream) {
+	f.mu.Lock()
+	if f.stream == s {
+		f.stream = nil
+	}
+	f.mu.Unlock()
+	s.cancel()
+}
+
+func (f *MetricGRPCFacade) openStream() (*metricStream, error) {
+	// The stream outlives any single report, it is bound to the facade.
+	ctx, cancel := context.WithCancel(context.Background())
+
+	stream, err := f.client.StreamUpdates(ctx)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	s := &metricStream{
+		stream: stream,
+		cancel: cancel,
+		done:   make(chan struct{}),
+		ackCh:  make(chan struct{}),
+	}
+	go s.readSummaries()
+
+	return s, nil
+}
+
+// readSummaries consumes the server acknowledgements until the stream ends.
+func (s *metricStream) readSummaries() {
+	defer close(s.done)
+
+	var rejected int64
+	for {
+		summary, err := s.stream.Recv()
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				s.err = err
+			}
+			return
+		}
+
+		if summary.GetRejected() > rejected {
+			logger.Log.Warnw("server rejected streamed metrics",
+				"rejected", summary.GetRejected()-rejected,
+				"received", summary.GetReceived(),
+			)
+			rejected = summary.GetRejected()
+		}
+
+		s.ack(summary.GetReceived())
+	}
+}
+
+func (s *metricStream) ack(received int64) {
+	s.ackMu.Lock()
+	defer s.ackMu.Unlock()
+
+	if received > s.acked {
+		s.acked = received
+		close(s.ackCh)
+		s.ackCh = make(chan struct{})
+	}
+}
+
+// ackedSince returns how many of n metrics sent after the first start ones
+// are acknowledged.
+func (s *metricStream) ackedSince(start int64, n int) int {
+	s.ackMu.Lock()
+	defer s.ackMu.Unlock()
+
+	return int(min(max(s.acked-start, 0), int64(n)))
+}
+
+// waitAcked blocks until the first n metrics are acknowledged, the stream
+// ends or ctx is done.
+func (s *metricStream) waitAcked(ctx context.Context, n int64) error {
+	for {
+		s.ackMu.Lock()
+		acked, ackCh := s.acked, s.ackCh
+		s.ackMu.Unlock()
+
+		if acked >= n {
+			return nil
+		}
+
+		select {
+		case <-ackCh:
+		case <-s.done:
+			// The reader acknowledges the final summary before it closes done.
+			s.ackMu.Lock()
+			acked = s.acked
+			s.ackMu.Unlock()
+			if acked >= n {
+				return nil
+			}
+			return s.streamErr()
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// streamErr returns the status the stream ended with, it may be called once
+// done is closed.
+func (s *metricStream) streamErr() error {
+	if s.err != nil {
+		return s.err
+	}
+	return status.Error(codes.Unavailable, "stream closed by server")
+}
+
+// Close ends the open stream, waiting briefly for the final summary, and
+// releases the client connection.
+func (f *MetricGRPCFacade) Close() error {
+	f.mu.Lock()
+	s := f.stream
+	f.stream = nil
+	f.mu.Unlock()
+
+	if s != nil {
+		if err := s.stream.CloseSend(); err == nil {
+			select {
+			case <-s.done:
+			case <-time.After(streamCloseTimeout):
+			}
+		}
+		s.cancel()
+	}
+
+	return f.conn.Close()
+}
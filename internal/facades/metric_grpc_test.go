@@ -0,0 +1,379 @@
+package facades
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/hash"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/models"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/pb"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/retry"
+)
+
+type stubMetricsServer struct {
+	pb.UnimplementedMetricsServiceServer
+	updateBatch   func(ctx context.Context, req *pb.UpdateBatchRequest) (*pb.UpdateBatchResponse, error)
+	streamUpdates func(stream pb.MetricsService_StreamUpdatesServer) error
+}
+
+func (s *stubMetricsServer) UpdateBatch(ctx context.Context, req *pb.UpdateBatchRequest) (*pb.UpdateBatchResponse, error) {
+	return s.updateBatch(ctx, req)
+}
+
+func (s *stubMetricsServer) StreamUpdates(stream pb.MetricsService_StreamUpdatesServer) error {
+	return s.streamUpdates(stream)
+}
+
+func startStubServer(t *testing.T, stub *stubMetricsServer) string {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	srv := grpc.NewServer()
+	pb.RegisterMetricsServiceServer(srv, stub)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	return lis.Addr().String()
+}
+
+func TestMetricGRPCFacade_Updates(t *testing.T) {
+	delta := int64(5)
+	value := 1.5
+
+	var received *pb.UpdateBatchRequest
+	addr := startStubServer(t, &stubMetricsServer{
+		updateBatch: func(ctx context.Context, req *pb.UpdateBatchRequest) (*pb.UpdateBatchResponse, error) {
+			md, _ := metadata.FromIncomingContext(ctx)
+			assert.Equal(t, []string{"127.0.0.1"}, md.Get("x-real-ip"))
+
+			data, err := proto.MarshalOptions{Deterministic: true}.Marshal(req)
+			require.NoError(t, err)
+			require.Len(t, md.Get(hash.Header), 1)
+			assert.True(t, hash.Verify(data, "secret", md.Get(hash.Header)[0]))
+
+			received = req
+			return &pb.UpdateBatchResponse{}, nil
+		},
+	})
+
+	f, err := NewMetricGRPCFacade(
+		WithMetricGRPCFacadeServerAddress("http://"+addr),
+		WithMetricGRPCFacadeKey("secret"),
+	)
+	require.NoError(t, err)
+	defer f.Close()
+
+	err = f.Updates(context.Background(), []*models.Metrics{
+		{ID: "PollCount", MType: models.Counter, Delta: &delta},
+		nil,
+		{ID: "Alloc", MType: models.Gauge, Value: &value},
+	})
+	require.NoError(t, err)
+
+	require.Len(t, received.GetMetrics(), 2)
+	assert.Equal(t, int64(5), received.GetMetrics()[0].GetDelta())
+	assert.Equal(t, 1.5, received.GetMetrics()[1].GetValue())
+}
+
+func TestMetricGRPCFacade_Updates_Retries(t *testing.T) {
+	value := 1.0
+
+	tests := []struct {
+		name          string
+		code          codes.Code
+		expectedCalls int64
+	}{
+		{name: "unavailable is retried", code: codes.Unavailable, expectedCalls: 3},
+		{name: "invalid argument is not retried", code: codes.InvalidArgument, expectedCalls: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int64
+			addr := startStubServer(t, &stubMetricsServer{
+				updateBatch: func(ctx context.Context, req *pb.UpdateBatchRequest) (*pb.UpdateBatchResponse, error) {
+					calls.Add(1)
+					return nil, status.Error(tt.code, "failed")
+				},
+			})
+
+			f, err := NewMetricGRPCFacade(
+				WithMetricGRPCFacadeServerAddress(addr),
+				WithMetricGRPCFacadeRetryDelays(time.Millisecond, time.Millisecond),
+			)
+			require.NoError(t, err)
+			defer f.Close()
+
+			err = f.Updates(context.Background(), []*models.Metrics{
+				{ID: "Alloc", MType: models.Gauge, Value: &value},
+			})
+			assert.Equal(t, tt.code, status.Code(err))
+			assert.Equal(t, tt.code == codes.Unavailable, retry.IsRetriable(err))
+			assert.Equal(t, tt.expectedCalls, calls.Load())
+		})
+	}
+}
+
+func TestMetricGRPCFacade_Updates_Empty(t *testing.T) {
+	f, err := NewMetricGRPCFacade(WithMetricGRPCFacadeServerAddress("localhost:0"))
+	require.NoError(t, err)
+	defer f.Close()
+
+	assert.NoError(t, f.Updates(context.Background(), []*models.Metrics{nil}))
+}
+
+func TestMetricGRPCFacade_Updates_Streaming(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		received []string
+		streams  int
+		finished = make(chan struct{})
+	)
+
+	var summary pb.StreamUpdatesSummary
+
+	addr := startStubServer(t, &stubMetricsServer{
+		streamUpdates: func(stream pb.MetricsService_StreamUpdatesServer) error {
+			mu.Lock()
+			streams++
+			mu.Unlock()
+
+			md, _ := metadata.FromIncomingContext(stream.Context())
+			assert.Equal(t, []string{"127.0.0.1"}, md.Get("x-real-ip"))
+
+			for {
+				req, err := stream.Recv()
+				if errors.Is(err, io.EOF) {
+					close(finished)
+					return stream.Send(&summary)
+				}
+				if err != nil {
+					return err
+				}
+
+				data, err := proto.MarshalOptions{Deterministic: true}.Marshal(req.GetMetric())
+				require.NoError(t, err)
+				assert.True(t, hash.Verify(data, "secret", req.GetHash()))
+
+				mu.Lock()
+				received = append(received, req.GetMetric().GetId())
+				mu.Unlock()
+
+				summary.Received++
+				summary.Applied++
+				if err := stream.Send(&summary); err != nil {
+					return err
+				}
+			}
+		},
+	})
+
+	f, err := NewMetricGRPCFacade(
+		WithMetricGRPCFacadeServerAddress(addr),
+		WithMetricGRPCFacadeKey("secret"),
+		WithMetricGRPCFacadeStreaming(),
+	)
+	require.NoError(t, err)
+
+	value := 1.0
+	for _, id := range []string{"a", "b"} {
+		err = f.Updates(context.Background(), []*models.Metrics{
+			{ID: id, MType: models.Gauge, Value: &value},
+		})
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, f.Close())
+
+	select {
+	case <-finished:
+	case <-time.After(time.Second):
+		t.Fatal("stream was not closed by the client")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"a", "b"}, received)
+	assert.Equal(t, 1, streams, "reports share a single stream")
+}
+
+func TestMetricGRPCFacade_Updates_StreamingReopensBrokenStream(t *testing.T) {
+	var calls atomic.Int64
+	addr := startStubServer(t, &stubMetricsServer{
+		streamUpdates: func(stream pb.MetricsService_StreamUpdatesServer) error {
+			if calls.Add(1) == 1 {
+				return status.Error(codes.Unavailable, "restarting")
+			}
+			return ackEach(stream, nil)
+		},
+	})
+
+	f, err := NewMetricGRPCFacade(
+		WithMetricGRPCFacadeServerAddress(addr),
+		WithMetricGRPCFacadeStreaming(),
+		WithMetricGRPCFacadeRetryDelays(10*time.Millisecond, 10*time.Millisecond, 10*time.Millisecond),
+	)
+	require.NoError(t, err)
+	defer f.Close()
+
+	value := 1.0
+	metrics := []*models.Metrics{{ID: "a", MType: models.Gauge, Value: &value}}
+
+	// The first stream may fail on the first or a later send depending on
+	// when the server status arrives, keep reporting until it is reopened.
+	require.Eventually(t, func() bool {
+		return f.Updates(context.Background(), metrics) == nil && calls.Load() == 2
+	}, time.Second, 10*time.Millisecond)
+}
+
+// ackEach acknowledges every metric received on the stream, handle may end the
+// stream early by returning an error.
+func ackEach(stream pb.MetricsService_StreamUpdatesServer, handle func(req *pb.StreamUpdatesRequest) error) error {
+	var summary pb.StreamUpdatesSummary
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return stream.Send(&summary)
+		}
+		if err != nil {
+			return err
+		}
+		if handle != nil {
+			if err := handle(req); err != nil {
+				return err
+			}
+		}
+
+		summary.Received++
+		summary.Applied++
+		if err := stream.Send(&summary); err != nil {
+			return err
+		}
+	}
+}
+
+func TestMetricGRPCFacade_Updates_StreamingResendsOnlyUnacknowledged(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		received [][]string
+	)
+
+	addr := startStubServer(t, &stubMetricsServer{
+		streamUpdates: func(stream pb.MetricsService_StreamUpdatesServer) error {
+			mu.Lock()
+			received = append(received, nil)
+			n := len(received)
+			mu.Unlock()
+
+			return ackEach(stream, func(req *pb.StreamUpdatesRequest) error {
+				// The first stream breaks before acknowledging its second metric.
+				if n == 1 && req.GetMetric().GetId() == "b" {
+					return status.Error(codes.Unavailable, "restarting")
+				}
+				mu.Lock()
+				received[n-1] = append(received[n-1], req.GetMetric().GetId())
+				mu.Unlock()
+				return nil
+			})
+		},
+	})
+
+	f, err := NewMetricGRPCFacade(
+		WithMetricGRPCFacadeServerAddress(addr),
+		WithMetricGRPCFacadeStreaming(),
+		WithMetricGRPCFacadeRetryDelays(10*time.Millisecond),
+	)
+	require.NoError(t, err)
+	defer f.Close()
+
+	delta := int64(1)
+	err = f.Updates(context.Background(), []*models.Metrics{
+		{ID: "a", MType: models.Counter, Delta: &delta},
+		{ID: "b", MType: models.Counter, Delta: &delta},
+		{ID: "c", MType: models.Counter, Delta: &delta},
+	})
+	require.NoError(t, err)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, [][]string{{"a"}, {"b", "c"}}, received)
+}
+
+func TestMetricGRPCFacade_Updates_StreamingReportsPendingMetrics(t *testing.T) {
+	tests := []struct {
+		name          string
+		streamUpdates func(stream pb.MetricsService_StreamUpdatesServer) error
+		expectedCode  codes.Code
+	}{
+		{
+			name: "storage failure after the first metric",
+			streamUpdates: func(stream pb.MetricsService_StreamUpdatesServer) error {
+				return ackEach(stream, func(req *pb.StreamUpdatesRequest) error {
+					if req.GetMetric().GetId() != "a" {
+						return status.Error(codes.Internal, "failed to update metric")
+					}
+					return nil
+				})
+			},
+			expectedCode: codes.Internal,
+		},
+		{
+			name: "server stops acknowledging",
+			streamUpdates: func(stream pb.MetricsService_StreamUpdatesServer) error {
+				if _, err := stream.Recv(); err != nil {
+					return err
+				}
+				if err := stream.Send(&pb.StreamUpdatesSummary{Received: 1, Applied: 1}); err != nil {
+					return err
+				}
+				<-stream.Context().Done()
+				return nil
+			},
+			expectedCode: codes.Unavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr := startStubServer(t, &stubMetricsServer{streamUpdates: tt.streamUpdates})
+
+			f, err := NewMetricGRPCFacade(
+				WithMetricGRPCFacadeServerAddress(addr),
+				WithMetricGRPCFacadeStreaming(),
+				WithMetricGRPCFacadeRetryDelays(),
+				WithMetricGRPCFacadeStreamAckTimeout(50*time.Millisecond),
+			)
+			require.NoError(t, err)
+			defer f.Close()
+
+			delta := int64(1)
+			metrics := []*models.Metrics{
+				{ID: "a", MType: models.Counter, Delta: &delta},
+				{ID: "b", MType: models.Counter, Delta: &delta},
+				{ID: "c", MType: models.Counter, Delta: &delta},
+			}
+			err = f.Updates(context.Background(), metrics)
+
+			var partial *models.PartialUpdateError
+			require.ErrorAs(t, err, &partial)
+			assert.Equal(t, metrics[1:], partial.Pending)
+			assert.Equal(t, tt.expectedCode, status.Code(partial.Err))
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package facades
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/encryption"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/hash"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/models"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/retry"
+)
+
+func TestMetricHTTPFacade_Updates(t *testing.T) {
+	delta := int64(5)
+	value := 1.5
+
+	input := []*models.Metrics{
+		{ID: "PollCount", MType: models.Counter, Delta: &delta},
+		nil,
+		{ID: "Alloc", MType: models.Gauge, Value: &value},
+	}
+	expected := []*models.Metrics{input[0], input[2]}
+
+	tests := []struct {
+		name     string
+		compress bool
+	}{
+		{name: "gzip by default", compress: true},
+		{name: "plain JSON", compress: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var received []*models.Metrics
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/updates/", r.URL.Path)
+				assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+				assert.Equal(t, "127.0.0.1", r.Header.Get("X-Real-IP"))
+
+				var body io.Reader = r.Body
+				if tt.compress {
+					assert.Equal(t, "gzip", r.Header.Get("Content-Encoding"))
+					gz, err := gzip.NewReader(r.Body)
+					require.NoError(t, err)
+					body = gz
+				} else {
+					assert.Empty(t, r.Header.Get("Content-Encoding"))
+				}
+
+				require.NoError(t, json.NewDecoder(body).Decode(&received))
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer srv.Close()
+
+			f := NewMetricHTTPFacade(
+				WithMetricFacadeServerAddress(srv.URL),
+				WithMetricFacadeCompression(tt.compress),
+			)
+
+			require.NoError(t, f.Updates(context.Background(), input))
+			assert.Equal(t, expected, received)
+		})
+	}
+}
+
+func TestMetricHTTPFacade_Updates_Signed(t *testing.T) {
+	value := 1.0
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gz, err := gzip.NewReader(r.Body)
+		require.NoError(t, err)
+		body, err := io.ReadAll(gz)
+		require.NoError(t, err)
+
+		assert.True(t, hash.Verify(body, "secret", r.Header.Get(hash.Header)))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	f := NewMetricHTTPFacade(
+		WithMetricFacadeServerAddress(srv.URL),
+		WithMetricFacadeKey("secret"),
+	)
+
+	require.NoError(t, f.Updates(context.Background(), []*models.Metrics{
+		{ID: "Alloc", MType: models.Gauge, Value: &value},
+	}))
+}
+
+func TestMetricHTTPFacade_Updates_Encrypted(t *testing.T) {
+	key, err := encryption.GenerateKey(2048)
+	require.NoError(t, err)
+
+	value := 1.0
+	var received []*models.Metrics
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, encryption.Scheme, r.Header.Get(encryption.Header))
+
+		ciphertext, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		compressed, err := encryption.Decrypt(key, ciphertext)
+		require.NoError(t, err)
+
+		gz, err := gzip.NewReader(bytes.NewReader(compressed))
+		require.NoError(t, err)
+		require.NoError(t, json.NewDecoder(gz).Decode(&received))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	f := NewMetricHTTPFacade(
+		WithMetricFacadeServerAddress(srv.URL),
+		WithMetricFacadePublicKey(&key.PublicKey),
+	)
+
+	require.NoError(t, f.Updates(context.Background(), []*models.Metrics{
+		{ID: "Alloc", MType: models.Gauge, Value: &value},
+	}))
+	require.Len(t, received, 1)
+	assert.Equal(t, "Alloc", received[0].ID)
+}
+
+func TestMetricHTTPFacade_Updates_Retries(t *testing.T) {
+	value := 1.0
+	metrics := []*models.Metrics{{ID: "Alloc", MType: models.Gauge, Value: &value}}
+
+	tests := []struct {
+		name          string
+		statuses      []int
+		expectErr     bool
+		expectedCalls int32
+	}{
+		{name: "bad request is not retried", statuses: []int{http.StatusBadRequest}, expectErr: true, expectedCalls: 1},
+		{name: "server error is retried", statuses: []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusOK}, expectedCalls: 3},
+		{name: "server errors exhaust retries", statuses: []int{500, 500, 500, 500}, expectErr: true, expectedCalls: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := calls.Add(1)
+				w.WriteHeader(tt.statuses[n-1])
+			}))
+			defer srv.Close()
+
+			f := NewMetricHTTPFacade(
+				WithMetricFacadeServerAddress(srv.URL),
+				WithMetricFacadeRetryDelays(time.Millisecond, time.Millisecond),
+			)
+
+			err := f.Updates(context.Background(), metrics)
+			if tt.expectErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.expectedCalls, calls.Load())
+		})
+	}
+}
+
+func TestMetricHTTPFacade_Updates_RetriesRefusedConnection(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	addr := srv.URL
+	srv.Close()
+
+	value := 1.0
+
+	f := NewMetricHTTPFacade(
+		WithMetricFacadeServerAddress(addr),
+		WithMetricFacadeRetryDelays(time.Millisecond),
+	)
+
+	err := f.Updates(context.Background(), []*models.Metrics{
+		{ID: "Alloc", MType: models.Gauge, Value: &value},
+	})
+	assert.Error(t, err)
+	assert.True(t, retry.IsRetriable(err))
+}
+
+func TestMetricHTTPFacade_Updates_Empty(t *testing.T) {
+	f := NewMetricHTTPFacade(WithMetricFacadeServerAddress("localhost:0"))
+
+	assert.NoError(t, f.Updates(context.Background(), []*models.Metrics{nil}))
+}
+
+func TestMetricHTTPFacade_BaseURL(t *testing.T) {
+	assert.Equal(t, "http://localhost:8080", NewMetricHTTPFacade(WithMetricFacadeServerAddress("localhost:8080")).baseURL())
+	assert.Equal(t, "https://example.com", NewMetricHTTPFacade(WithMetricFacadeServerAddress("https://example.com")).baseURL())
+}
@@ -0,0 +1,99 @@
+// Package graphite parses the Graphite plaintext protocol and maps its paths
+// onto metrics.
+package graphite
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/models"
+)
+
+// Line is a single "path value timestamp" line.
+type Line struct {
+	Path  string
+	Value float64
+	// Timestamp is in Unix seconds, -1 asks the receiver to use the current time.
+	Timestamp int64
+}
+
+// ParseLine parses a line without its trailing newline. Fields may be
+// separated by any run of spaces or tabs.
+func ParseLine(line string) (Line, error) {
+	fields := strings.Fields(line)
+	if len(fields) != 3 {
+		return Line{}, fmt.Errorf("expected 3 fields, got %d", len(fields))
+	}
+
+	path := fields[0]
+	if strings.HasPrefix(path, ".") || strings.HasSuffix(path, ".") || strings.Contains(path, "..") {
+		return Line{}, fmt.Errorf("invalid path %q", path)
+	}
+
+	value, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return Line{}, fmt.Errorf("invalid value %q", fields[1])
+	}
+
+	// Senders such as collectd write fractional timestamps.
+	ts, err := strconv.ParseFloat(fields[2], 64)
+	if err != nil || math.IsNaN(ts) || math.IsInf(ts, 0) || (ts < 0 && ts != -1) {
+		return Line{}, fmt.Errorf("invalid timestamp %q", fields[2])
+	}
+
+	return Line{Path: path, Value: value, Timestamp: int64(ts)}, nil
+}
+
+// Rules decide the metric type of a path. Paths under a counter prefix are
+// counters, every other path is a gauge.
+type Rules struct {
+	counterPrefixes []string
+}
+
+// NewRules creates Rules from dotted prefixes such as "stats.counters". A
+// prefix matches the path itself and every path below it, a trailing dot is
+// ignored.
+func NewRules(counterPrefixes ...string) (*Rules, error) {
+	r := &Rules{}
+	for _, prefix := range counterPrefixes {
+		prefix = strings.TrimSuffix(strings.TrimSpace(prefix), ".")
+		if prefix == "" {
+			return nil, errors.New("empty counter prefix")
+		}
+		r.counterPrefixes = append(r.counterPrefixes, prefix)
+	}
+	return r, nil
+}
+
+// Type returns models.Counter or models.Gauge for path.
+func (r *Rules) Type(path string) string {
+	for _, prefix := range r.counterPrefixes {
+		if path == prefix || strings.HasPrefix(path, prefix+".") {
+			return models.Counter
+		}
+	}
+	return models.Gauge
+}
+
+// Metric converts a line into a metric whose ID is the dotted path. Counter
+// values are rounded to whole increments.
+func (r *Rules) Metric(line Line) (*models.Metrics, error) {
+	metric := &models.Metrics{ID: line.Path, MType: r.Type(line.Path)}
+
+	if metric.MType == models.Counter {
+		rounded := math.Round(line.Value)
+		if rounded < math.MinInt64 || rounded >= math.MaxInt64 {
+			return nil, fmt.Errorf("counter %q: %v overflows a counter", line.Path, line.Value)
+		}
+		delta := int64(rounded)
+		metric.Delta = &delta
+	} else {
+		value := line.Value
+		metric.Value = &value
+	}
+
+	return metric, nil
+}
@@ -0,0 +1,109 @@
+package graphite
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/models"
+)
+
+func TestParseLine(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		expected Line
+	}{
+		{
+			name:     "plain line",
+			line:     "servers.web1.cpu.load 0.75 1700000000",
+			expected: Line{Path: "servers.web1.cpu.load", Value: 0.75, Timestamp: 1700000000},
+		},
+		{
+			name:     "tabs and fractional timestamp",
+			line:     "collectd.web1.memory.used\t1024\t1700000000.123",
+			expected: Line{Path: "collectd.web1.memory.used", Value: 1024, Timestamp: 1700000000},
+		},
+		{
+			name:     "current time",
+			line:     "queue.size -3 -1",
+			expected: Line{Path: "queue.size", Value: -3, Timestamp: -1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line, err := ParseLine(tt.line)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, line)
+		})
+	}
+}
+
+func TestParseLine_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+	}{
+		{name: "missing timestamp", line: "a.b 1"},
+		{name: "extra field", line: "a.b 1 2 3"},
+		{name: "empty path segment", line: "a..b 1 2"},
+		{name: "leading dot", line: ".a 1 2"},
+		{name: "invalid value", line: "a.b one 2"},
+		{name: "NaN value", line: "a.b nan 2"},
+		{name: "invalid timestamp", line: "a.b 1 now"},
+		{name: "negative timestamp", line: "a.b 1 -5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseLine(tt.line)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestRules(t *testing.T) {
+	rules, err := NewRules("stats.counters.", "collectd.web1.if_octets")
+	require.NoError(t, err)
+
+	tests := []struct {
+		line     Line
+		expected *models.Metrics
+	}{
+		{
+			line:     Line{Path: "stats.counters.requests", Value: 4.6},
+			expected: &models.Metrics{ID: "stats.counters.requests", MType: models.Counter, Delta: int64Ptr(5)},
+		},
+		{
+			line:     Line{Path: "collectd.web1.if_octets", Value: 10},
+			expected: &models.Metrics{ID: "collectd.web1.if_octets", MType: models.Counter, Delta: int64Ptr(10)},
+		},
+		{
+			line:     Line{Path: "stats.countersx.requests", Value: 1.5},
+			expected: &models.Metrics{ID: "stats.countersx.requests", MType: models.Gauge, Value: float64Ptr(1.5)},
+		},
+		{
+			line:     Line{Path: "stats.gauges.load", Value: 0.5},
+			expected: &models.Metrics{ID: "stats.gauges.load", MType: models.Gauge, Value: float64Ptr(0.5)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line.Path, func(t *testing.T) {
+			metric, err := rules.Metric(tt.line)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, metric)
+		})
+	}
+
+	_, err = rules.Metric(Line{Path: "stats.counters.huge", Value: 1e30})
+	assert.Error(t, err)
+
+	_, err = NewRules("stats", " ")
+	assert.Error(t, err)
+}
+
+func int64Ptr(v int64) *int64       { return &v }
+func float64Ptr(v float64) *float64 { return &v }
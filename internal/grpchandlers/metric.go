@@ -0,0 +1,311 @@
+package grpchandlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/models"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/pb"
+)
+
+// MetricUpdater defines an interface for updating multiple metrics.
+type MetricUpdater interface {
+	Update(ctx context.Context, metrics []*models.Metrics) ([]*models.Metrics, error)
+}
+
+// MetricGetter defines an interface for reading a single metric by its identifier.
+type MetricGetter interface {
+	Get(ctx context.Context, id models.MetricID) (*models.Metrics, error)
+}
+
+// MetricGRPCHandlerOption is a functional option for configuring MetricGRPCHandler
+type MetricGRPCHandlerOption func(*MetricGRPCHandler)
+
+// WithMetricGRPCUpdater sets the service used to apply updates
+func WithMetricGRPCUpdater(svc MetricUpdater) MetricGRPCHandlerOption {
+	return func(h *MetricGRPCHandler) {
+		h.svc = svc
+	}
+}
+
+// WithMetricGRPCGetter sets the repository used to read metrics
+func WithMetricGRPCGetter(getter MetricGetter) MetricGRPCHandlerOption {
+	return func(h *MetricGRPCHandler) {
+		h.getter = getter
+	}
+}
+
+// WithMetricGRPCStreamAckEvery sets how many metrics are received on a stream
+// between two summaries
+func WithMetricGRPCStreamAckEvery(n int64) MetricGRPCHandlerOption {
+	return func(h *MetricGRPCHandler) {
+		h.ackEvery = n
+	}
+}
+
+// WithMetricGRPCStreamAckInterval sets how often a stream with unacknowledged
+// metrics gets a summary, however few metrics it received
+func WithMetricGRPCStreamAckInterval(d time.Duration) MetricGRPCHandlerOption {
+	return func(h *MetricGRPCHandler) {
+		h.ackInterval = d
+	}
+}
+
+const (
+	// defaultStreamAckEvery is the number of streamed metrics between summaries.
+	defaultStreamAckEvery = 100
+	// defaultStreamAckInterval bounds how long a streamed metric waits for its
+	// summary.
+	defaultStreamAckInterval = time.Second
+)
+
+// MetricGRPCHandler implements pb.MetricsServiceServer on top of the same
+// updater and getter as the HTTP handlers.
+type MetricGRPCHandler struct {
+	pb.UnimplementedMetricsServiceServer
+
+	svc         MetricUpdater
+	getter      MetricGetter
+	ackEvery    int64
+	ackInterval time.Duration
+}
+
+func NewMetricGRPCHandler(opts ...MetricGRPCHandlerOption) *MetricGRPCHandler {
+	h := &MetricGRPCHandler{
+		ackEvery:    defaultStreamAckEvery,
+		ackInterval: defaultStreamAckInterval,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+func (h *MetricGRPCHandler) Update(ctx context.Context, req *pb.UpdateRequest) (*pb.UpdateResponse, error) {
+	metric, err := toModel(req.GetMetric())
+	if err != nil {
+		return nil, err
+	}
+
+	updated, err := h.svc.Update(ctx, []*models.Metrics{metric})
+	if err != nil {
+		return nil, updateError(err, "failed to update metric")
+	}
+
+	result := metric
+	for _, m := range updated {
+		if m != nil && m.Key() == metric.Key() {
+			result = m
+			break
+		}
+	}
+
+	return &pb.UpdateResponse{Metric: pb.FromModel(result)}, nil
+}
+
+func (h *MetricGRPCHandler) UpdateBatch(ctx context.Context, req *pb.UpdateBatchRequest) (*pb.UpdateBatchResponse, error) {
+	metrics := make([]*models.Metrics, 0, len(req.GetMetrics()))
+	for _, m := range req.GetMetrics() {
+		metric, err := toModel(m)
+		if err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, metric)
+	}
+
+	updated, err := h.svc.Update(ctx, metrics)
+	if err != nil {
+		return nil, updateError(err, "failed to update metrics")
+	}
+
+	resp := &pb.UpdateBatchResponse{Metrics: make([]*pb.Metric, 0, len(updated))}
+	for _, m := range updated {
+		resp.Metrics = append(resp.Metrics, pb.FromModel(m))
+	}
+
+	return resp, nil
+}
+
+// StreamUpdates applies metrics as they arrive. Each metric is stored before
+// the next one is read, so a slow repository stalls the sender through gRPC
+// flow control instead of buffering on the server. Invalid metrics are
+// counted as rejected and do not end the stream, a storage failure does.
+// A histogram, summary or set that cannot be merged counts as rejected too.
+//
+// A summary acknowledges every metric counted as received: it is sent every
+// ackEvery metrics, after ackInterval if metrics are still unacknowledged,
+// when the client closes its side and before a storage failure ends the
+// stream, so the client knows exactly which metrics to send again.
+func (h *MetricGRPCHandler) StreamUpdates(stream pb.MetricsService_StreamUpdatesServer) error {
+	acks := &streamAcker{stream: stream, summary: &pb.StreamUpdatesSummary{}}
+	if h.ackInterval > 0 {
+		stop := acks.every(h.ackInterval)
+		defer stop()
+	}
+
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return acks.send()
+		}
+		if err != nil {
+			return err
+		}
+
+		applied := false
+		metric, err := toModel(req.GetMetric())
+		if err == nil {
+			_, err := h.svc.Update(stream.Context(), []*models.Metrics{metric})
+			switch {
+			case models.IsMergeConflict(err):
+			case err != nil:
+				// The failed metric is not received, the client sends it again.
+				acks.send()
+				return status.Error(codes.Internal, "failed to update metric")
+			default:
+				applied = true
+			}
+		}
+
+		received := acks.record(applied)
+		if h.ackEvery > 0 && received%h.ackEvery == 0 {
+			if err := acks.send(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// streamAcker keeps the summary of a stream and sends it from the receiving
+// goroutine and the ack timer alike, gRPC streams are not safe for concurrent
+// SendMsg calls.
+type streamAcker struct {
+	stream pb.MetricsService_StreamUpdatesServer
+
+	mu      sync.Mutex
+	summary *pb.StreamUpdatesSummary
+	// acked is the received count of the last summary sent.
+	acked int64
+}
+
+// record counts a processed metric and returns the number received so far.
+func (a *streamAcker) record(applied bool) int64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.summary.Received++
+	if applied {
+		a.summary.Applied++
+	} else {
+		a.summary.Rejected++
+	}
+	return a.summary.Received
+}
+
+func (a *streamAcker) send() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.acked = a.summary.Received
+	return a.stream.Send(a.summary)
+}
+
+// every sends the summary each interval while metrics are unacknowledged. The
+// returned function stops the timer and waits for a send in progress, the
+// stream must not be used once the handler returns.
+func (a *streamAcker) every(interval time.Duration) (stop func()) {
+	quit := make(chan struct{})
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-quit:
+				return
+			case <-ticker.C:
+				a.mu.Lock()
+				pending := a.summary.Received > a.acked
+				a.mu.Unlock()
+				// A failed send breaks the stream, Recv reports it.
+				if pending && a.send() != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(quit)
+		<-done
+	}
+}
+
+func (h *MetricGRPCHandler) GetValue(ctx context.Context, req *pb.GetValueRequest) (*pb.GetValueResponse, error) {
+	switch req.GetType() {
+	case models.Counter, models.Gauge, models.Histogram, models.Summary, models.Set:
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "unknown metric type %q", req.GetType())
+	}
+
+	labels, err := models.NewLabels(req.GetLabels())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	metric, err := h.getter.Get(ctx, models.MetricID{ID: req.GetId(), MType: req.GetType(), Labels: labels})
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to get metric")
+	}
+	if metric == nil {
+		return nil, status.Error(codes.NotFound, "metric not found")
+	}
+
+	return &pb.GetValueResponse{Metric: pb.FromModel(metric)}, nil
+}
+
+// Register registers the handler on a gRPC server.
+func (h *MetricGRPCHandler) Register(s grpc.ServiceRegistrar) {
+	pb.RegisterMetricsServiceServer(s, h)
+}
+
+// toModel converts and validates a metric received from a client.
+func toModel(m *pb.Metric) (*models.Metrics, error) {
+	metric, err := m.ToModel()
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err := validateMetric(metric); err != nil {
+		return nil, err
+	}
+	return metric, nil
+}
+
+// validateMetric returns an InvalidArgument status for a metric that cannot
+// be passed to the updater.
+func validateMetric(metric *models.Metrics) error {
+	if err := metric.Validate(); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return nil
+}
+
+// updateError maps an updater error to a status: merge conflicts are an
+// invalid argument, anything else a storage failure.
+func updateError(err error, msg string) error {
+	if models.IsMergeConflict(err) {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return status.Error(codes.Internal, msg)
+}
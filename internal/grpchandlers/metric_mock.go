@@ -0,0 +1,89 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: /home/sergey/Github/go-yandex-practicum-metric/internal/grpchandlers/metric.go
+
+// Package grpchandlers is a generated GoMock package.
+package grpchandlers
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+	models "github.com/sbilibin2017/go-yandex-practicum-metric/internal/models"
+)
+
+// MockMetricUpdater is a mock of MetricUpdater interface.
+type MockMetricUpdater struct {
+	ctrl     *gomock.Controller
+	recorder *MockMetricUpdaterMockRecorder
+}
+
+// MockMetricUpdaterMockRecorder is the mock recorder for MockMetricUpdater.
+type MockMetricUpdaterMockRecorder struct {
+	mock *MockMetricUpdater
+}
+
+// NewMockMetricUpdater creates a new mock instance.
+func NewMockMetricUpdater(ctrl *gomock.Controller) *MockMetricUpdater {
+	mock := &MockMetricUpdater{ctrl: ctrl}
+	mock.recorder = &MockMetricUpdaterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockMetricUpdater) EXPECT() *MockMetricUpdaterMockRecorder {
+	return m.recorder
+}
+
+// Update mocks base method.
+func (m *MockMetricUpdater) Update(ctx context.Context, metrics []*models.Metrics) ([]*models.Metrics, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Update", ctx, metrics)
+	ret0, _ := ret[0].([]*models.Metrics)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Update indicates an expected call of Update.
+func (mr *MockMetricUpdaterMockRecorder) Update(ctx, metrics interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockMetricUpdater)(nil).Update), ctx, metrics)
+}
+
+// MockMetricGetter is a mock of MetricGetter interface.
+type MockMetricGetter struct {
+	ctrl     *gomock.Controller
+	recorder *MockMetricGetterMockRecorder
+}
+
+// MockMetricGetterMockRecorder is the mock recorder for MockMetricGetter.
+type MockMetricGetterMockRecorder struct {
+	mock *MockMetricGetter
+}
+
+// NewMockMetricGetter creates a new mock instance.
+func NewMockMetricGetter(ctrl *gomock.Controller) *MockMetricGetter {
+	mock := &MockMetricGetter{ctrl: ctrl}
+	mock.recorder = &MockMetricGetterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockMetricGetter) EXPECT() *MockMetricGetterMockRecorder {
+	return m.recorder
+}
+
+// Get mocks base method.
+func (m *MockMetricGetter) Get(ctx context.Context, id models.MetricID) (*models.Metrics, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", ctx, id)
+	ret0, _ := ret[0].(*models.Metrics)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Get indicates an expected call of Get.
+func (mr *MockMetricGetterMockRecorder) Get(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockMetricGetter)(nil).Get), ctx, id)
+}
@@ -0,0 +1,486 @@
+package grpchandlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/ddsketch"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/hyperloglog"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/models"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/pb"
+)
+
+func int64Ptr(v int64) *int64       { return &v }
+func float64Ptr(v float64) *float64 { return &v }
+
+func TestMetricGRPCHandler_Update(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUpdater := NewMockMetricUpdater(ctrl)
+	h := NewMetricGRPCHandler(WithMetricGRPCUpdater(mockUpdater))
+
+	set, _ := hyperloglog.New(4)
+	set.Add("alice")
+	setBytes, _ := set.MarshalBinary()
+
+	tests := []struct {
+		name         string
+		metric       *pb.Metric
+		setup        func()
+		expectedCode codes.Code
+		expected     *pb.Metric
+	}{
+		{
+			name:   "counter is summed by the service",
+			metric: &pb.Metric{Id: "c", Type: models.Counter, Delta: int64Ptr(1)},
+			setup: func() {
+				mockUpdater.EXPECT().
+					Update(gomock.Any(), []*models.Metrics{{ID: "c", MType: models.Counter, Delta: int64Ptr(1)}}).
+					Return([]*models.Metrics{{ID: "c", MType: models.Counter, Delta: int64Ptr(6)}}, nil)
+			},
+			expectedCode: codes.OK,
+			expected:     &pb.Metric{Id: "c", Type: models.Counter, Delta: int64Ptr(6)},
+		},
+		{
+			name: "labels pick the series",
+			metric: &pb.Metric{
+				Id: "c", Type: models.Counter, Delta: int64Ptr(1),
+				Labels: map[string]string{"route": "/a", "method": ""},
+			},
+			setup: func() {
+				mockUpdater.EXPECT().
+					Update(gomock.Any(), []*models.Metrics{{ID: "c", MType: models.Counter, Labels: `route="/a"`, Delta: int64Ptr(1)}}).
+					Return([]*models.Metrics{
+						{ID: "c", MType: models.Counter, Delta: int64Ptr(9)},
+						{ID: "c", MType: models.Counter, Labels: `route="/a"`, Delta: int64Ptr(3)},
+					}, nil)
+			},
+			expectedCode: codes.OK,
+			expected:     &pb.Metric{Id: "c", Type: models.Counter, Delta: int64Ptr(3), Labels: map[string]string{"route": "/a"}},
+		},
+		{
+			name:         "invalid label name",
+			metric:       &pb.Metric{Id: "c", Type: models.Counter, Delta: int64Ptr(1), Labels: map[string]string{"__name__": "c"}},
+			setup:        func() {},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:         "missing metric",
+			setup:        func() {},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:         "gauge without value",
+			metric:       &pb.Metric{Id: "g", Type: models.Gauge},
+			setup:        func() {},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:         "unknown type",
+			metric:       &pb.Metric{Id: "x", Type: "timer", Value: float64Ptr(1)},
+			setup:        func() {},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:   "histogram observation",
+			metric: &pb.Metric{Id: "h", Type: models.Histogram, Value: float64Ptr(0.5)},
+			setup: func() {
+				mockUpdater.EXPECT().
+					Update(gomock.Any(), []*models.Metrics{{ID: "h", MType: models.Histogram, Value: float64Ptr(0.5)}}).
+					Return([]*models.Metrics{{
+						ID: "h", MType: models.Histogram,
+						Histogram: &models.HistogramValue{Bounds: []float64{1}, Counts: []uint64{1, 0}, Sum: 0.5, Count: 1},
+					}}, nil)
+			},
+			expectedCode: codes.OK,
+			expected: &pb.Metric{
+				Id: "h", Type: models.Histogram,
+				Histogram: &pb.Histogram{Bounds: []float64{1}, Counts: []uint64{1, 0}, Sum: 0.5, Count: 1},
+			},
+		},
+		{
+			name: "histogram with inconsistent buckets",
+			metric: &pb.Metric{
+				Id: "h", Type: models.Histogram,
+				Histogram: &pb.Histogram{Bounds: []float64{1}, Counts: []uint64{1}, Count: 1},
+			},
+			setup:        func() {},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:         "histogram with both value and buckets",
+			metric:       &pb.Metric{Id: "h", Type: models.Histogram, Value: float64Ptr(1), Histogram: &pb.Histogram{Counts: []uint64{0}}},
+			setup:        func() {},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name: "histogram bounds differ from the stored ones",
+			metric: &pb.Metric{
+				Id: "h", Type: models.Histogram,
+				Histogram: &pb.Histogram{Bounds: []float64{2}, Counts: []uint64{1, 0}, Sum: 1, Count: 1},
+			},
+			setup: func() {
+				mockUpdater.EXPECT().Update(gomock.Any(), gomock.Any()).Return(nil, models.ErrHistogramBuckets)
+			},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:   "summary observation",
+			metric: &pb.Metric{Id: "s", Type: models.Summary, Value: float64Ptr(2)},
+			setup: func() {
+				mockUpdater.EXPECT().
+					Update(gomock.Any(), []*models.Metrics{{ID: "s", MType: models.Summary, Value: float64Ptr(2)}}).
+					Return([]*models.Metrics{{
+						ID: "s", MType: models.Summary,
+						Summary: &ddsketch.Sketch{RelativeAccuracy: 0.01, Positive: map[int32]uint64{35: 1}, Count: 1, Sum: 2, Min: 2, Max: 2},
+					}}, nil)
+			},
+			expectedCode: codes.OK,
+			expected: &pb.Metric{
+				Id: "s", Type: models.Summary,
+				Summary: &pb.Sketch{RelativeAccuracy: 0.01, Positive: map[int32]uint64{35: 1}, Count: 1, Sum: 2, Min: 2, Max: 2},
+			},
+		},
+		{
+			name: "summary with an inconsistent sketch",
+			metric: &pb.Metric{
+				Id: "s", Type: models.Summary,
+				Summary: &pb.Sketch{RelativeAccuracy: 0.01, Zero: 1, Count: 2},
+			},
+			setup:        func() {},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name: "summary accuracy differs from the stored one",
+			metric: &pb.Metric{
+				Id: "s", Type: models.Summary,
+				Summary: &pb.Sketch{RelativeAccuracy: 0.05, Zero: 1, Count: 1},
+			},
+			setup: func() {
+				mockUpdater.EXPECT().Update(gomock.Any(), gomock.Any()).Return(nil, ddsketch.ErrAccuracyMismatch)
+			},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:   "set members",
+			metric: &pb.Metric{Id: "u", Type: models.Set, Members: []string{"alice"}},
+			setup: func() {
+				mockUpdater.EXPECT().
+					Update(gomock.Any(), []*models.Metrics{{ID: "u", MType: models.Set, Members: []string{"alice"}}}).
+					Return([]*models.Metrics{{ID: "u", MType: models.Set, Set: set}}, nil)
+			},
+			expectedCode: codes.OK,
+			expected:     &pb.Metric{Id: "u", Type: models.Set, Set: setBytes, Cardinality: 1},
+		},
+		{
+			name:         "set without members",
+			metric:       &pb.Metric{Id: "u", Type: models.Set},
+			setup:        func() {},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:         "set with a malformed sketch",
+			metric:       &pb.Metric{Id: "u", Type: models.Set, Set: []byte{4}},
+			setup:        func() {},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:   "set precision differs from the stored one",
+			metric: &pb.Metric{Id: "u", Type: models.Set, Members: []string{"alice"}},
+			setup: func() {
+				mockUpdater.EXPECT().Update(gomock.Any(), gomock.Any()).Return(nil, hyperloglog.ErrPrecisionMismatch)
+			},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:   "service error",
+			metric: &pb.Metric{Id: "g", Type: models.Gauge, Value: float64Ptr(1)},
+			setup: func() {
+				mockUpdater.EXPECT().Update(gomock.Any(), gomock.Any()).Return(nil, errors.New("db down"))
+			},
+			expectedCode: codes.Internal,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setup()
+
+			resp, err := h.Update(context.Background(), &pb.UpdateRequest{Metric: tt.metric})
+
+			assert.Equal(t, tt.expectedCode, status.Code(err))
+			if tt.expected != nil {
+				require.NotNil(t, resp)
+				assert.True(t, proto.Equal(tt.expected, resp.GetMetric()), "got %v", resp.GetMetric())
+			}
+		})
+	}
+}
+
+func TestMetricGRPCHandler_UpdateBatch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUpdater := NewMockMetricUpdater(ctrl)
+	h := NewMetricGRPCHandler(WithMetricGRPCUpdater(mockUpdater))
+
+	t.Run("valid batch", func(t *testing.T) {
+		mockUpdater.EXPECT().
+			Update(gomock.Any(), gomock.Len(2)).
+			Return([]*models.Metrics{
+				{ID: "a", MType: models.Gauge, Value: float64Ptr(1)},
+				{ID: "b", MType: models.Counter, Delta: int64Ptr(2)},
+			}, nil)
+
+		resp, err := h.UpdateBatch(context.Background(), &pb.UpdateBatchRequest{Metrics: []*pb.Metric{
+			{Id: "a", Type: models.Gauge, Value: float64Ptr(1)},
+			{Id: "b", Type: models.Counter, Delta: int64Ptr(2)},
+		}})
+		require.NoError(t, err)
+		assert.Len(t, resp.GetMetrics(), 2)
+	})
+
+	t.Run("one invalid metric rejects the batch", func(t *testing.T) {
+		_, err := h.UpdateBatch(context.Background(), &pb.UpdateBatchRequest{Metrics: []*pb.Metric{
+			{Id: "a", Type: models.Gauge, Value: float64Ptr(1)},
+			nil,
+		}})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("service error", func(t *testing.T) {
+		mockUpdater.EXPECT().Update(gomock.Any(), gomock.Any()).Return(nil, errors.New("db down"))
+
+		_, err := h.UpdateBatch(context.Background(), &pb.UpdateBatchRequest{Metrics: []*pb.Metric{
+			{Id: "a", Type: models.Gauge, Value: float64Ptr(1)},
+		}})
+		assert.Equal(t, codes.Internal, status.Code(err))
+	})
+}
+
+// fakeUpdatesStream replays requests and records the summaries sent back.
+// With hold set, the end of the stream waits until hold is closed.
+type fakeUpdatesStream struct {
+	grpc.ServerStream
+
+	requests []*pb.StreamUpdatesRequest
+	hold     chan struct{}
+
+	mu        sync.Mutex
+	summaries []*pb.StreamUpdatesSummary
+}
+
+func (s *fakeUpdatesStream) Context() context.Context {
+	return context.Background()
+}
+
+func (s *fakeUpdatesStream) Recv() (*pb.StreamUpdatesRequest, error) {
+	if len(s.requests) == 0 {
+		if s.hold != nil {
+			<-s.hold
+		}
+		return nil, io.EOF
+	}
+	req := s.requests[0]
+	s.requests = s.requests[1:]
+	return req, nil
+}
+
+func (s *fakeUpdatesStream) Send(summary *pb.StreamUpdatesSummary) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The real stream serialises on Send, keep a copy of the running totals.
+	s.summaries = append(s.summaries, &pb.StreamUpdatesSummary{
+		Received: summary.GetReceived(),
+		Applied:  summary.GetApplied(),
+		Rejected: summary.GetRejected(),
+	})
+	return nil
+}
+
+func (s *fakeUpdatesStream) sent() []*pb.StreamUpdatesSummary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*pb.StreamUpdatesSummary(nil), s.summaries...)
+}
+
+func TestMetricGRPCHandler_StreamUpdates(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUpdater := NewMockMetricUpdater(ctrl)
+	h := NewMetricGRPCHandler(
+		WithMetricGRPCUpdater(mockUpdater),
+		WithMetricGRPCStreamAckEvery(2),
+		WithMetricGRPCStreamAckInterval(0),
+	)
+
+	valid := &pb.StreamUpdatesRequest{Metric: &pb.Metric{Id: "c", Type: models.Counter, Delta: int64Ptr(1)}}
+	invalid := &pb.StreamUpdatesRequest{Metric: &pb.Metric{Id: "g", Type: models.Gauge}}
+
+	t.Run("metrics are applied one by one with periodic summaries", func(t *testing.T) {
+		gomock.InOrder(
+			mockUpdater.EXPECT().Update(gomock.Any(), gomock.Len(1)).Return(nil, nil),
+			mockUpdater.EXPECT().Update(gomock.Any(), gomock.Len(1)).Return(nil, nil),
+		)
+
+		stream := &fakeUpdatesStream{requests: []*pb.StreamUpdatesRequest{valid, invalid, valid}}
+		require.NoError(t, h.StreamUpdates(stream))
+
+		assert.Equal(t, []*pb.StreamUpdatesSummary{
+			{Received: 2, Applied: 1, Rejected: 1},
+			{Received: 3, Applied: 2, Rejected: 1},
+		}, stream.summaries)
+	})
+
+	t.Run("empty stream gets a final summary", func(t *testing.T) {
+		stream := &fakeUpdatesStream{}
+		require.NoError(t, h.StreamUpdates(stream))
+
+		assert.Equal(t, []*pb.StreamUpdatesSummary{{}}, stream.summaries)
+	})
+
+	t.Run("service error acknowledges the stored metrics and ends the stream", func(t *testing.T) {
+		gomock.InOrder(
+			mockUpdater.EXPECT().Update(gomock.Any(), gomock.Any()).Return(nil, nil),
+			mockUpdater.EXPECT().Update(gomock.Any(), gomock.Any()).Return(nil, errors.New("db down")),
+		)
+
+		stream := &fakeUpdatesStream{requests: []*pb.StreamUpdatesRequest{valid, valid, valid}}
+		err := h.StreamUpdates(stream)
+
+		assert.Equal(t, codes.Internal, status.Code(err))
+		assert.Equal(t, []*pb.StreamUpdatesSummary{
+			{Received: 1, Applied: 1},
+		}, stream.summaries)
+	})
+}
+
+func TestMetricGRPCHandler_StreamUpdates_AckInterval(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUpdater := NewMockMetricUpdater(ctrl)
+	mockUpdater.EXPECT().Update(gomock.Any(), gomock.Len(1)).Return(nil, nil)
+
+	h := NewMetricGRPCHandler(
+		WithMetricGRPCUpdater(mockUpdater),
+		WithMetricGRPCStreamAckInterval(10*time.Millisecond),
+	)
+
+	valid := &pb.StreamUpdatesRequest{Metric: &pb.Metric{Id: "c", Type: models.Counter, Delta: int64Ptr(1)}}
+	stream := &fakeUpdatesStream{
+		requests: []*pb.StreamUpdatesRequest{valid},
+		hold:     make(chan struct{}),
+	}
+
+	result := make(chan error, 1)
+	go func() {
+		result <- h.StreamUpdates(stream)
+	}()
+
+	// Far fewer metrics than ackEvery are acknowledged while the client is idle.
+	require.Eventually(t, func() bool {
+		return len(stream.sent()) > 0
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, &pb.StreamUpdatesSummary{Received: 1, Applied: 1}, stream.sent()[0])
+
+	// Nothing new arrived, the timer does not repeat the summary.
+	time.Sleep(50 * time.Millisecond)
+	assert.Len(t, stream.sent(), 1)
+
+	close(stream.hold)
+	require.NoError(t, <-result)
+	assert.Len(t, stream.sent(), 2, "final summary")
+}
+
+func TestMetricGRPCHandler_GetValue(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockGetter := NewMockMetricGetter(ctrl)
+	h := NewMetricGRPCHandler(WithMetricGRPCGetter(mockGetter))
+
+	tests := []struct {
+		name         string
+		req          *pb.GetValueRequest
+		setup        func()
+		expectedCode codes.Code
+	}{
+		{
+			name: "found",
+			req:  &pb.GetValueRequest{Id: "a", Type: models.Gauge},
+			setup: func() {
+				mockGetter.EXPECT().
+					Get(gomock.Any(), models.MetricID{ID: "a", MType: models.Gauge}).
+					Return(&models.Metrics{ID: "a", MType: models.Gauge, Value: float64Ptr(1)}, nil)
+			},
+			expectedCode: codes.OK,
+		},
+		{
+			name: "found with labels",
+			req:  &pb.GetValueRequest{Id: "a", Type: models.Gauge, Labels: map[string]string{"host": "x"}},
+			setup: func() {
+				mockGetter.EXPECT().
+					Get(gomock.Any(), models.MetricID{ID: "a", MType: models.Gauge, Labels: `host="x"`}).
+					Return(&models.Metrics{ID: "a", MType: models.Gauge, Labels: `host="x"`, Value: float64Ptr(1)}, nil)
+			},
+			expectedCode: codes.OK,
+		},
+		{
+			name:         "invalid label name",
+			req:          &pb.GetValueRequest{Id: "a", Type: models.Gauge, Labels: map[string]string{"a-b": "x"}},
+			setup:        func() {},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name: "not found",
+			req:  &pb.GetValueRequest{Id: "missing", Type: models.Counter},
+			setup: func() {
+				mockGetter.EXPECT().Get(gomock.Any(), gomock.Any()).Return(nil, nil)
+			},
+			expectedCode: codes.NotFound,
+		},
+		{
+			name:         "unknown type",
+			req:          &pb.GetValueRequest{Id: "a", Type: "unknown"},
+			setup:        func() {},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name: "getter error",
+			req:  &pb.GetValueRequest{Id: "a", Type: models.Gauge},
+			setup: func() {
+				mockGetter.EXPECT().Get(gomock.Any(), gomock.Any()).Return(nil, errors.New("db down"))
+			},
+			expectedCode: codes.Internal,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setup()
+
+			resp, err := h.GetValue(context.Background(), tt.req)
+
+			assert.Equal(t, tt.expectedCode, status.Code(err))
+			if tt.expectedCode == codes.OK {
+				assert.Equal(t, 1.0, resp.GetMetric().GetValue())
+			}
+		})
+	}
+}
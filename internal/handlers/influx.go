@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/lineprotocol"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/models"
+)
+
+// Functional options for MetricInfluxWriteHandler
+type MetricInfluxWriteHandlerOption func(*MetricInfluxWriteHandler)
+
+func WithMetricUpdaterInflux(svc MetricUpdater) MetricInfluxWriteHandlerOption {
+	return func(h *MetricInfluxWriteHandler) {
+		h.svc = svc
+	}
+}
+
+// MetricInfluxWriteHandler accepts metrics in the InfluxDB line protocol.
+// Integer fields become counters and float fields gauges, named
+// measurement_field, or just measurement for a field called "value". Tags
+// become labels, timestamps are parsed but not stored.
+type MetricInfluxWriteHandler struct {
+	svc MetricUpdater
+}
+
+func NewMetricInfluxWriteHandler(opts ...MetricInfluxWriteHandlerOption) *MetricInfluxWriteHandler {
+	h := &MetricInfluxWriteHandler{}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// influxLineError is a rejected line in the response body.
+type influxLineError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+type influxWriteResponse struct {
+	Errors []influxLineError `json:"errors"`
+}
+
+// Write applies every valid line as a single batch. As in InfluxDB, a partial
+// write still stores the valid lines and fields and is answered with 400
+// listing the rejected ones; a fully valid body gets 204.
+func (h *MetricInfluxWriteHandler) Write(w http.ResponseWriter, r *http.Request) {
+	points, parseErrs, err := lineprotocol.Parse(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var lineErrs []influxLineError
+	for _, e := range parseErrs {
+		lineErrs = append(lineErrs, influxLineError{Line: e.Line, Error: e.Err.Error()})
+	}
+
+	var metrics []*models.Metrics
+	for _, point := range points {
+		converted, skipped, err := influxPointMetrics(point)
+		if err != nil {
+			lineErrs = append(lineErrs, influxLineError{Line: point.Line, Error: err.Error()})
+			continue
+		}
+		for _, e := range skipped {
+			lineErrs = append(lineErrs, influxLineError{Line: point.Line, Error: e.Error()})
+		}
+		metrics = append(metrics, converted...)
+	}
+
+	if len(metrics) > 0 {
+		if _, err := h.svc.Update(r.Context(), metrics); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if len(lineErrs) > 0 {
+		sort.SliceStable(lineErrs, func(i, j int) bool {
+			return lineErrs[i].Line < lineErrs[j].Line
+		})
+		writeJSON(w, http.StatusBadRequest, influxWriteResponse{Errors: lineErrs})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *MetricInfluxWriteHandler) RegisterRoute(r chi.Router) {
+	r.Post("/write", h.Write)
+}
+
+// influxPointMetrics converts the fields of a point. Unsupported fields are
+// skipped and returned as errors, telegraf mixes them with numeric fields on
+// one line; a tag that is not a valid label rejects the whole line.
+func influxPointMetrics(point lineprotocol.Point) ([]*models.Metrics, []error, error) {
+	labels, err := models.NewLabels(point.Tags)
+	if err != nil {
+		return nil, nil, fmt.Errorf("tags: %w", err)
+	}
+
+	var (
+		metrics = make([]*models.Metrics, 0, len(point.Fields))
+		skipped []error
+	)
+
+	for _, field := range point.Fields {
+		id := point.Measurement
+		if field.Key != "value" {
+			id += "_" + field.Key
+		}
+
+		switch v := field.Value.(type) {
+		case int64:
+			metrics = append(metrics, &models.Metrics{ID: id, MType: models.Counter, Labels: labels, Delta: &v})
+		case uint64:
+			if v > math.MaxInt64 {
+				skipped = append(skipped, fmt.Errorf("field %q: %d overflows a counter", field.Key, v))
+				continue
+			}
+			delta := int64(v)
+			metrics = append(metrics, &models.Metrics{ID: id, MType: models.Counter, Labels: labels, Delta: &delta})
+		case float64:
+			metrics = append(metrics, &models.Metrics{ID: id, MType: models.Gauge, Labels: labels, Value: &v})
+		default:
+			skipped = append(skipped, fmt.Errorf("field %q: only integer and float fields are supported", field.Key))
+		}
+	}
+
+	return metrics, skipped, nil
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/models"
+)
+
+func TestMetricInfluxWriteHandler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUpdater := NewMockMetricUpdater(ctrl)
+	handler := NewMetricInfluxWriteHandler(WithMetricUpdaterInflux(mockUpdater))
+
+	r := chi.NewRouter()
+	handler.RegisterRoute(r)
+
+	delta := func(v int64) *int64 { return &v }
+	value := func(v float64) *float64 { return &v }
+
+	tests := []struct {
+		name           string
+		body           string
+		setup          func()
+		expectedStatus int
+		expectedErrors []influxLineError
+	}{
+		{
+			name: "All lines are applied in one batch",
+			body: "cpu,host=a value=0.5 1700000000000000000\nhttp requests=3i,bytes=10u,latency=1.5\n",
+			setup: func() {
+				mockUpdater.EXPECT().
+					Update(gomock.Any(), []*models.Metrics{
+						{ID: "cpu", MType: models.Gauge, Labels: `host="a"`, Value: value(0.5)},
+						{ID: "http_requests", MType: models.Counter, Delta: delta(3)},
+						{ID: "http_bytes", MType: models.Counter, Delta: delta(10)},
+						{ID: "http_latency", MType: models.Gauge, Value: value(1.5)},
+					}).
+					Return(nil, nil)
+			},
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name: "Partial write applies the valid lines",
+			body: "cpu value=1\ncpu\nlog msg=\"hi\"\nmem free=2i\ndisk,mount-point=/ used=1i\n",
+			setup: func() {
+				mockUpdater.EXPECT().
+					Update(gomock.Any(), []*models.Metrics{
+						{ID: "cpu", MType: models.Gauge, Value: value(1)},
+						{ID: "mem_free", MType: models.Counter, Delta: delta(2)},
+					}).
+					Return(nil, nil)
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedErrors: []influxLineError{
+				{Line: 2, Error: "missing fields"},
+				{Line: 3, Error: `field "msg": only integer and float fields are supported`},
+				{Line: 5, Error: `tags: invalid label name "mount-point"`},
+			},
+		},
+		{
+			name: "Unsupported fields are skipped and the numeric ones applied",
+			body: "procstat,host=a pid=42i,cmdline=\"nginx\",running=true,cpu_usage=0.5,big=18446744073709551615u\n",
+			setup: func() {
+				mockUpdater.EXPECT().
+					Update(gomock.Any(), []*models.Metrics{
+						{ID: "procstat_pid", MType: models.Counter, Labels: `host="a"`, Delta: delta(42)},
+						{ID: "procstat_cpu_usage", MType: models.Gauge, Labels: `host="a"`, Value: value(0.5)},
+					}).
+					Return(nil, nil)
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedErrors: []influxLineError{
+				{Line: 1, Error: `field "cmdline": only integer and float fields are supported`},
+				{Line: 1, Error: `field "running": only integer and float fields are supported`},
+				{Line: 1, Error: `field "big": 18446744073709551615 overflows a counter`},
+			},
+		},
+		{
+			name: "Too long line is reported and the other lines applied",
+			body: "cpu value=1\nlog msg=\"" + strings.Repeat("x", 1<<20) + "\"\nmem free=2i\n",
+			setup: func() {
+				mockUpdater.EXPECT().
+					Update(gomock.Any(), []*models.Metrics{
+						{ID: "cpu", MType: models.Gauge, Value: value(1)},
+						{ID: "mem_free", MType: models.Counter, Delta: delta(2)},
+					}).
+					Return(nil, nil)
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedErrors: []influxLineError{
+				{Line: 2, Error: "line is longer than 1048576 bytes"},
+			},
+		},
+		{
+			name:           "No valid lines",
+			body:           "cpu value=18446744073709551615u\n",
+			setup:          func() {},
+			expectedStatus: http.StatusBadRequest,
+			expectedErrors: []influxLineError{
+				{Line: 1, Error: `field "value": 18446744073709551615 overflows a counter`},
+			},
+		},
+		{
+			name:           "Empty body",
+			setup:          func() {},
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name: "Service error",
+			body: "cpu value=1\n",
+			setup: func() {
+				mockUpdater.EXPECT().Update(gomock.Any(), gomock.Any()).Return(nil, errors.New("db down"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setup()
+
+			req := httptest.NewRequest(http.MethodPost, "/write", strings.NewReader(tt.body))
+			rr := httptest.NewRecorder()
+
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedErrors != nil {
+				var resp influxWriteResponse
+				require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+				assert.Equal(t, tt.expectedErrors, resp.Errors)
+			}
+		})
+	}
+}
@@ -2,13 +2,28 @@ package handlers
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"math"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/ddsketch"
 	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/models"
 )
 
+// quantileParam selects the quantiles of a summary on the path API, so it
+// cannot be used as a summary label there.
+const quantileParam = "q"
+
+// summaryQuantiles are the quantiles shown for a summary when none are asked for.
+var summaryQuantiles = []float64{0.5, 0.9, 0.95, 0.99}
+
 // MetricUpdater defines an interface for updating multiple metrics.
 type MetricUpdater interface {
 	Update(ctx context.Context, metrics []*models.Metrics) ([]*models.Metrics, error)
@@ -56,6 +71,19 @@ func (h *MetricUpdatePathHandler) Update(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	query := r.URL.Query()
+	if metricType == models.Summary && query.Has(quantileParam) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	labels, err := queryLabels(query)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	metric.Labels = labels
+
 	switch metricType {
 	case models.Counter:
 		delta, err := strconv.ParseInt(value, 10, 64)
@@ -66,22 +94,31 @@ func (h *MetricUpdatePathHandler) Update(w http.ResponseWriter, r *http.Request)
 		metric.Delta = &delta
 		metric.MType = models.Counter
 
-	case models.Gauge:
+	case models.Gauge, models.Histogram, models.Summary:
 		val, err := strconv.ParseFloat(value, 64)
-		if err != nil {
+		if err != nil || math.IsNaN(val) || math.IsInf(val, 0) {
 			w.WriteHeader(http.StatusBadRequest)
 			return
 		}
 		metric.Value = &val
-		metric.MType = models.Gauge
+		metric.MType = metricType
+
+	case models.Set:
+		metric.Members = []string{value}
+		metric.MType = models.Set
 
 	default:
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
+	if status := validateMetric(&metric); status != http.StatusOK {
+		w.WriteHeader(status)
+		return
+	}
+
 	if _, err := h.svc.Update(r.Context(), []*models.Metrics{&metric}); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		w.WriteHeader(updateErrorStatus(err))
 		return
 	}
 
@@ -92,3 +129,460 @@ func (h *MetricUpdatePathHandler) RegisterRoute(r chi.Router) {
 	r.Post("/update/{type}/{name}/{value}", h.Update)
 	r.Post("/update/{type}/{name}", h.Update)
 }
+
+// MetricGetter defines an interface for reading a single metric by its identifier.
+type MetricGetter interface {
+	Get(ctx context.Context, metricID models.MetricID) (*models.Metrics, error)
+}
+
+// Functional options for MetricGetPathHandler
+type MetricGetPathHandlerOption func(*MetricGetPathHandler)
+
+func WithMetricGetterPath(getter MetricGetter) MetricGetPathHandlerOption {
+	return func(h *MetricGetPathHandler) {
+		h.getter = getter
+	}
+}
+
+// MetricGetPathHandler returns a metric value addressed by URL path parameters.
+type MetricGetPathHandler struct {
+	getter MetricGetter
+}
+
+func NewMetricGetPathHandler(opts ...MetricGetPathHandlerOption) *MetricGetPathHandler {
+	h := &MetricGetPathHandler{}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+func (h *MetricGetPathHandler) Get(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+
+	metricType := chi.URLParam(r, "type")
+	name := chi.URLParam(r, "name")
+
+	if name == "" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	switch metricType {
+	case models.Counter, models.Gauge, models.Histogram, models.Summary, models.Set:
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	var quantiles []float64
+	if metricType == models.Summary {
+		var err error
+		if quantiles, err = parseQuantiles(query[quantileParam]); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		query.Del(quantileParam)
+	}
+
+	labels, err := queryLabels(query)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	metric, err := h.getter.Get(r.Context(), models.MetricID{ID: name, MType: metricType, Labels: labels})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if metric == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if len(quantiles) > 0 && metric.Summary != nil {
+		w.Write([]byte(formatQuantiles(metric.Summary, quantiles)))
+		return
+	}
+	w.Write([]byte(formatMetricValue(metric)))
+}
+
+// parseQuantiles parses the requested quantiles, each in [0, 1].
+func parseQuantiles(values []string) ([]float64, error) {
+	quantiles := make([]float64, 0, len(values))
+	for _, v := range values {
+		q, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, err
+		}
+		if !(q >= 0 && q <= 1) {
+			return nil, fmt.Errorf("quantile %v is not in [0, 1]", q)
+		}
+		quantiles = append(quantiles, q)
+	}
+	return quantiles, nil
+}
+
+// formatQuantiles writes the estimate of every quantile on its own line.
+func formatQuantiles(summary *ddsketch.Sketch, quantiles []float64) string {
+	lines := make([]string, len(quantiles))
+	for i, q := range quantiles {
+		lines[i] = strconv.FormatFloat(summary.Quantile(q), 'f', -1, 64)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// formatSummary formats a summary as count, sum and the default quantiles,
+// e.g. "count=3 sum=0.75 p50=0.25 p90=0.3 p95=0.3 p99=0.3".
+func formatSummary(summary *ddsketch.Sketch) string {
+	var b strings.Builder
+	b.WriteString("count=" + strconv.FormatUint(summary.Count, 10))
+	b.WriteString(" sum=" + strconv.FormatFloat(summary.Sum, 'f', -1, 64))
+	for _, q := range summaryQuantiles {
+		b.WriteString(" p" + strconv.FormatFloat(q*100, 'f', -1, 64) + "=")
+		b.WriteString(strconv.FormatFloat(summary.Quantile(q), 'f', -1, 64))
+	}
+	return b.String()
+}
+
+func (h *MetricGetPathHandler) RegisterRoute(r chi.Router) {
+	r.Get("/value/{type}/{name}", h.Get)
+}
+
+// MetricLister defines an interface for reading every stored metric.
+type MetricLister interface {
+	List(ctx context.Context) ([]*models.Metrics, error)
+}
+
+// Functional options for MetricListHTMLHandler
+type MetricListHTMLHandlerOption func(*MetricListHTMLHandler)
+
+func WithMetricListerHTML(lister MetricLister) MetricListHTMLHandlerOption {
+	return func(h *MetricListHTMLHandler) {
+		h.lister = lister
+	}
+}
+
+// MetricListHTMLHandler renders every stored metric as an HTML page.
+type MetricListHTMLHandler struct {
+	lister MetricLister
+}
+
+func NewMetricListHTMLHandler(opts ...MetricListHTMLHandlerOption) *MetricListHTMLHandler {
+	h := &MetricListHTMLHandler{}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+var metricListTemplate = template.Must(template.New("metrics").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Metrics</title></head>
+<body>
+<table>
+<tr><th>Type</th><th>Name</th><th>Labels</th><th>Value</th></tr>
+{{- range .}}
+<tr><td>{{.MType}}</td><td>{{.ID}}</td><td>{{.Labels}}</td><td>{{.Value}}</td></tr>
+{{- end}}
+</table>
+</body>
+</html>
+`))
+
+type metricListRow struct {
+	MType  string
+	ID     string
+	Labels string
+	Value  string
+}
+
+func (h *MetricListHTMLHandler) List(w http.ResponseWriter, r *http.Request) {
+	matchers, err := queryMatchers(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	metrics, err := h.lister.List(r.Context())
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	metrics = filterMetrics(metrics, matchers)
+
+	rows := make([]metricListRow, 0, len(metrics))
+	for _, metric := range metrics {
+		rows = append(rows, metricListRow{
+			MType:  metric.MType,
+			ID:     metric.ID,
+			Labels: string(metric.Labels),
+			Value:  formatMetricValue(metric),
+		})
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	metricListTemplate.Execute(w, rows)
+}
+
+func (h *MetricListHTMLHandler) RegisterRoute(r chi.Router) {
+	r.Get("/", h.List)
+}
+
+// queryLabels reads the labels of the path API, each query parameter is a
+// label. A label given twice is rejected.
+func queryLabels(query url.Values) (models.Labels, error) {
+	m := make(map[string]string, len(query))
+	for name, values := range query {
+		if len(values) != 1 {
+			return "", fmt.Errorf("label %q given %d times", name, len(values))
+		}
+		m[name] = values[0]
+	}
+	return models.NewLabels(m)
+}
+
+// queryMatchers parses every match query parameter of a list endpoint. All
+// selectors have to match.
+func queryMatchers(r *http.Request) ([]models.Matcher, error) {
+	var matchers []models.Matcher
+	for _, selector := range r.URL.Query()["match"] {
+		parsed, err := models.ParseMatchers(selector)
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, parsed...)
+	}
+	return matchers, nil
+}
+
+func filterMetrics(metrics []*models.Metrics, matchers []models.Matcher) []*models.Metrics {
+	if len(matchers) == 0 {
+		return metrics
+	}
+	filtered := make([]*models.Metrics, 0, len(metrics))
+	for _, metric := range metrics {
+		if metric != nil && models.MatchAll(metric.Labels, matchers) {
+			filtered = append(filtered, metric)
+		}
+	}
+	return filtered
+}
+
+func formatMetricValue(metric *models.Metrics) string {
+	switch {
+	case metric.MType == models.Counter && metric.Delta != nil:
+		return strconv.FormatInt(*metric.Delta, 10)
+	case metric.MType == models.Gauge && metric.Value != nil:
+		return strconv.FormatFloat(*metric.Value, 'f', -1, 64)
+	case metric.MType == models.Histogram && metric.Histogram != nil:
+		return metric.Histogram.String()
+	case metric.MType == models.Summary && metric.Summary != nil:
+		return formatSummary(metric.Summary)
+	case metric.MType == models.Set && metric.Set != nil:
+		return strconv.FormatUint(metric.Set.Count(), 10)
+	default:
+		return ""
+	}
+}
+
+// Functional options for MetricUpdateBodyHandler
+type MetricUpdateBodyHandlerOption func(*MetricUpdateBodyHandler)
+
+func WithMetricUpdaterBody(svc MetricUpdater) MetricUpdateBodyHandlerOption {
+	return func(h *MetricUpdateBodyHandler) {
+		h.svc = svc
+	}
+}
+
+// MetricUpdateBodyHandler handles metric updates sent as a JSON body.
+type MetricUpdateBodyHandler struct {
+	svc MetricUpdater
+}
+
+func NewMetricUpdateBodyHandler(opts ...MetricUpdateBodyHandlerOption) *MetricUpdateBodyHandler {
+	h := &MetricUpdateBodyHandler{}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+func (h *MetricUpdateBodyHandler) Update(w http.ResponseWriter, r *http.Request) {
+	var metric models.Metrics
+	if err := json.NewDecoder(r.Body).Decode(&metric); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if status := validateMetric(&metric); status != http.StatusOK {
+		w.WriteHeader(status)
+		return
+	}
+
+	updated, err := h.svc.Update(r.Context(), []*models.Metrics{&metric})
+	if err != nil {
+		w.WriteHeader(updateErrorStatus(err))
+		return
+	}
+
+	result := &metric
+	for _, m := range updated {
+		if m != nil && m.Key() == metric.Key() {
+			result = m
+			break
+		}
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (h *MetricUpdateBodyHandler) RegisterRoute(r chi.Router) {
+	r.Post("/update/", h.Update)
+}
+
+// Functional options for MetricGetBodyHandler
+type MetricGetBodyHandlerOption func(*MetricGetBodyHandler)
+
+func WithMetricGetterBody(getter MetricGetter) MetricGetBodyHandlerOption {
+	return func(h *MetricGetBodyHandler) {
+		h.getter = getter
+	}
+}
+
+// MetricGetBodyHandler returns a metric addressed by a JSON body.
+type MetricGetBodyHandler struct {
+	getter MetricGetter
+}
+
+func NewMetricGetBodyHandler(opts ...MetricGetBodyHandlerOption) *MetricGetBodyHandler {
+	h := &MetricGetBodyHandler{}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+func (h *MetricGetBodyHandler) Get(w http.ResponseWriter, r *http.Request) {
+	var metricID models.MetricID
+	if err := json.NewDecoder(r.Body).Decode(&metricID); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if metricID.ID == "" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	switch metricID.MType {
+	case models.Counter, models.Gauge, models.Histogram, models.Summary, models.Set:
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	metric, err := h.getter.Get(r.Context(), metricID)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if metric == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, metric)
+}
+
+func (h *MetricGetBodyHandler) RegisterRoute(r chi.Router) {
+	r.Post("/value/", h.Get)
+}
+
+// validateMetric checks a decoded metric and returns the HTTP status to reply with
+// on failure, or http.StatusOK.
+func validateMetric(metric *models.Metrics) int {
+	err := metric.Validate()
+	switch {
+	case err == nil:
+		return http.StatusOK
+	case errors.Is(err, models.ErrMetricIDRequired):
+		return http.StatusNotFound
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+// updateErrorStatus maps an updater error to the HTTP status to reply with:
+// merge conflicts are the client's fault, anything else is a storage failure.
+func updateErrorStatus(err error) int {
+	if models.IsMergeConflict(err) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// Functional options for MetricUpdatesBodyHandler
+type MetricUpdatesBodyHandlerOption func(*MetricUpdatesBodyHandler)
+
+func WithMetricUpdaterBatch(svc MetricUpdater) MetricUpdatesBodyHandlerOption {
+	return func(h *MetricUpdatesBodyHandler) {
+		h.svc = svc
+	}
+}
+
+// MetricUpdatesBodyHandler handles a batch of metric updates sent as a JSON array.
+// The batch is rejected as a whole if any metric in it is invalid.
+type MetricUpdatesBodyHandler struct {
+	svc MetricUpdater
+}
+
+func NewMetricUpdatesBodyHandler(opts ...MetricUpdatesBodyHandlerOption) *MetricUpdatesBodyHandler {
+	h := &MetricUpdatesBodyHandler{}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+func (h *MetricUpdatesBodyHandler) Updates(w http.ResponseWriter, r *http.Request) {
+	var metrics []*models.Metrics
+	if err := json.NewDecoder(r.Body).Decode(&metrics); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	for _, metric := range metrics {
+		if metric == nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if status := validateMetric(metric); status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+	}
+
+	updated, err := h.svc.Update(r.Context(), metrics)
+	if err != nil {
+		w.WriteHeader(updateErrorStatus(err))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, updated)
+}
+
+func (h *MetricUpdatesBodyHandler) RegisterRoute(r chi.Router) {
+	r.Post("/updates/", h.Updates)
+}
@@ -49,3 +49,79 @@ func (mr *MockMetricUpdaterMockRecorder) Update(ctx, metrics interface{}) *gomoc
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockMetricUpdater)(nil).Update), ctx, metrics)
 }
+
+// MockMetricGetter is a mock of MetricGetter interface.
+type MockMetricGetter struct {
+	ctrl     *gomock.Controller
+	recorder *MockMetricGetterMockRecorder
+}
+
+// MockMetricGetterMockRecorder is the mock recorder for MockMetricGetter.
+type MockMetricGetterMockRecorder struct {
+	mock *MockMetricGetter
+}
+
+// NewMockMetricGetter creates a new mock instance.
+func NewMockMetricGetter(ctrl *gomock.Controller) *MockMetricGetter {
+	mock := &MockMetricGetter{ctrl: ctrl}
+	mock.recorder = &MockMetricGetterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockMetricGetter) EXPECT() *MockMetricGetterMockRecorder {
+	return m.recorder
+}
+
+// Get mocks base method.
+func (m *MockMetricGetter) Get(ctx context.Context, metricID models.MetricID) (*models.Metrics, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", ctx, metricID)
+	ret0, _ := ret[0].(*models.Metrics)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Get indicates an expected call of Get.
+func (mr *MockMetricGetterMockRecorder) Get(ctx, metricID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockMetricGetter)(nil).Get), ctx, metricID)
+}
+
+// MockMetricLister is a mock of MetricLister interface.
+type MockMetricLister struct {
+	ctrl     *gomock.Controller
+	recorder *MockMetricListerMockRecorder
+}
+
+// MockMetricListerMockRecorder is the mock recorder for MockMetricLister.
+type MockMetricListerMockRecorder struct {
+	mock *MockMetricLister
+}
+
+// NewMockMetricLister creates a new mock instance.
+func NewMockMetricLister(ctrl *gomock.Controller) *MockMetricLister {
+	mock := &MockMetricLister{ctrl: ctrl}
+	mock.recorder = &MockMetricListerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockMetricLister) EXPECT() *MockMetricListerMockRecorder {
+	return m.recorder
+}
+
+// List mocks base method.
+func (m *MockMetricLister) List(ctx context.Context) ([]*models.Metrics, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", ctx)
+	ret0, _ := ret[0].([]*models.Metrics)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List.
+func (mr *MockMetricListerMockRecorder) List(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockMetricLister)(nil).List), ctx)
+}
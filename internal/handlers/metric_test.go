@@ -2,17 +2,33 @@ package handlers
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/golang/mock/gomock"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/ddsketch"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/hyperloglog"
 	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/models"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
+// testSetJSON is the encoding of the sketch returned by testSet.
+const testSetJSON = `{"precision":4,"registers":"AQAAAAAAAAAAAAAAAAAAAA==","count":1}`
+
+// testSet returns a set sketch of precision 4 holding a single member.
+func testSet(t *testing.T) *hyperloglog.Sketch {
+	var s hyperloglog.Sketch
+	require.NoError(t, s.UnmarshalBinary(append([]byte{4, 1}, make([]byte, 15)...)))
+	return &s
+}
+
 func TestMetricUpdatePathHandler(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -52,6 +68,37 @@ func TestMetricUpdatePathHandler(t *testing.T) {
 			},
 			expectedCode: http.StatusOK,
 		},
+		{
+			name:   "Counter with labels",
+			method: http.MethodPost,
+			url:    "/update/counter/requests/1?route=/a&method=GET",
+			mockExpect: func() {
+				delta := int64(1)
+				mockUpdater.EXPECT().
+					Update(gomock.Any(), []*models.Metrics{{
+						ID:     "requests",
+						MType:  models.Counter,
+						Labels: `method="GET",route="/a"`,
+						Delta:  &delta,
+					}}).
+					Return(nil, nil)
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "Repeated label",
+			method:       http.MethodPost,
+			url:          "/update/counter/requests/1?route=/a&route=/b",
+			mockExpect:   func() {},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Invalid label name",
+			method:       http.MethodPost,
+			url:          "/update/counter/requests/1?1route=/a",
+			mockExpect:   func() {},
+			expectedCode: http.StatusBadRequest,
+		},
 		{
 			name:         "Missing metric name",
 			method:       http.MethodPost,
@@ -87,6 +134,83 @@ func TestMetricUpdatePathHandler(t *testing.T) {
 			mockExpect:   func() {},
 			expectedCode: http.StatusBadRequest,
 		},
+		{
+			name:   "Histogram observation",
+			method: http.MethodPost,
+			url:    "/update/histogram/latency/0.25",
+			mockExpect: func() {
+				value := 0.25
+				mockUpdater.EXPECT().
+					Update(gomock.Any(), []*models.Metrics{{ID: "latency", MType: models.Histogram, Value: &value}}).
+					Return(nil, nil)
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "Gauge is not a number",
+			method:       http.MethodPost,
+			url:          "/update/gauge/load/NaN",
+			mockExpect:   func() {},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Gauge is infinite",
+			method:       http.MethodPost,
+			url:          "/update/gauge/load/-Inf",
+			mockExpect:   func() {},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Histogram with the reserved le label",
+			method:       http.MethodPost,
+			url:          "/update/histogram/latency/0.25?le=x",
+			mockExpect:   func() {},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Summary with the reserved quantile label",
+			method:       http.MethodPost,
+			url:          "/update/summary/latency/0.25?quantile=x",
+			mockExpect:   func() {},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Histogram observation is not a number",
+			method:       http.MethodPost,
+			url:          "/update/histogram/latency/NaN",
+			mockExpect:   func() {},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:   "Summary observation",
+			method: http.MethodPost,
+			url:    "/update/summary/latency/0.25?route=/a",
+			mockExpect: func() {
+				value := 0.25
+				mockUpdater.EXPECT().
+					Update(gomock.Any(), []*models.Metrics{{ID: "latency", MType: models.Summary, Labels: `route="/a"`, Value: &value}}).
+					Return(nil, nil)
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:   "Set member",
+			method: http.MethodPost,
+			url:    "/update/set/users/alice?app=web",
+			mockExpect: func() {
+				mockUpdater.EXPECT().
+					Update(gomock.Any(), []*models.Metrics{{ID: "users", MType: models.Set, Labels: `app="web"`, Members: []string{"alice"}}}).
+					Return(nil, nil)
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "Summary observation with the reserved q label",
+			method:       http.MethodPost,
+			url:          "/update/summary/latency/0.25?q=1",
+			mockExpect:   func() {},
+			expectedCode: http.StatusBadRequest,
+		},
 		{
 			name:   "Updater returns error",
 			method: http.MethodPost,
@@ -113,3 +237,669 @@ func TestMetricUpdatePathHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestMetricGetPathHandler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockGetter := NewMockMetricGetter(ctrl)
+	handler := NewMetricGetPathHandler(WithMetricGetterPath(mockGetter))
+
+	r := chi.NewRouter()
+	handler.RegisterRoute(r)
+
+	delta := int64(42)
+	value := 3.14
+
+	summary, _ := ddsketch.New(ddsketch.DefaultRelativeAccuracy)
+	for _, v := range []float64{1, 2, 3, 4} {
+		summary.Add(v)
+	}
+	single, _ := ddsketch.New(ddsketch.DefaultRelativeAccuracy)
+	single.Add(2)
+
+	tests := []struct {
+		name         string
+		url          string
+		mockExpect   func()
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name: "Counter found",
+			url:  "/value/counter/myCounter",
+			mockExpect: func() {
+				mockGetter.EXPECT().
+					Get(gomock.Any(), models.MetricID{ID: "myCounter", MType: models.Counter}).
+					Return(&models.Metrics{ID: "myCounter", MType: models.Counter, Delta: &delta}, nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: "42",
+		},
+		{
+			name: "Gauge found",
+			url:  "/value/gauge/myGauge",
+			mockExpect: func() {
+				mockGetter.EXPECT().
+					Get(gomock.Any(), models.MetricID{ID: "myGauge", MType: models.Gauge}).
+					Return(&models.Metrics{ID: "myGauge", MType: models.Gauge, Value: &value}, nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: "3.14",
+		},
+		{
+			name: "Counter with labels",
+			url:  "/value/counter/requests?route=/a",
+			mockExpect: func() {
+				mockGetter.EXPECT().
+					Get(gomock.Any(), models.MetricID{ID: "requests", MType: models.Counter, Labels: `route="/a"`}).
+					Return(&models.Metrics{ID: "requests", MType: models.Counter, Labels: `route="/a"`, Delta: &delta}, nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: "42",
+		},
+		{
+			name: "Histogram found",
+			url:  "/value/histogram/latency",
+			mockExpect: func() {
+				mockGetter.EXPECT().
+					Get(gomock.Any(), models.MetricID{ID: "latency", MType: models.Histogram}).
+					Return(&models.Metrics{
+						ID: "latency", MType: models.Histogram,
+						Histogram: &models.HistogramValue{Bounds: []float64{0.1, 1}, Counts: []uint64{1, 0, 2}, Sum: 7, Count: 3},
+					}, nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: "count=3 sum=7 buckets=[0.1:1 1:1 +Inf:3]",
+		},
+		{
+			name: "Summary quantiles",
+			url:  "/value/summary/latency?q=0&q=0.99&route=/a",
+			mockExpect: func() {
+				mockGetter.EXPECT().
+					Get(gomock.Any(), models.MetricID{ID: "latency", MType: models.Summary, Labels: `route="/a"`}).
+					Return(&models.Metrics{ID: "latency", MType: models.Summary, Labels: `route="/a"`, Summary: summary}, nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: "1\n" + strconv.FormatFloat(summary.Quantile(0.99), 'f', -1, 64),
+		},
+		{
+			name: "Summary without quantiles",
+			url:  "/value/summary/latency",
+			mockExpect: func() {
+				mockGetter.EXPECT().
+					Get(gomock.Any(), models.MetricID{ID: "latency", MType: models.Summary}).
+					Return(&models.Metrics{ID: "latency", MType: models.Summary, Summary: single}, nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: "count=1 sum=2 p50=2 p90=2 p95=2 p99=2",
+		},
+		{
+			name: "Set cardinality",
+			url:  "/value/set/users",
+			mockExpect: func() {
+				mockGetter.EXPECT().
+					Get(gomock.Any(), models.MetricID{ID: "users", MType: models.Set}).
+					Return(&models.Metrics{ID: "users", MType: models.Set, Set: testSet(t)}, nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: "1",
+		},
+		{
+			name:         "Summary quantile out of range",
+			url:          "/value/summary/latency?q=1.5",
+			mockExpect:   func() {},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name: "Unknown metric",
+			url:  "/value/gauge/unknown",
+			mockExpect: func() {
+				mockGetter.EXPECT().
+					Get(gomock.Any(), models.MetricID{ID: "unknown", MType: models.Gauge}).
+					Return(nil, nil)
+			},
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:         "Unsupported metric type",
+			url:          "/value/unknown/myMetric",
+			mockExpect:   func() {},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name: "Getter returns error",
+			url:  "/value/counter/myCounter",
+			mockExpect: func() {
+				mockGetter.EXPECT().
+					Get(gomock.Any(), gomock.Any()).
+					Return(nil, context.DeadlineExceeded)
+			},
+			expectedCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockExpect()
+
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			rr := httptest.NewRecorder()
+
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedCode, rr.Code)
+			assert.Equal(t, tt.expectedBody, rr.Body.String())
+		})
+	}
+}
+
+func TestMetricListHTMLHandler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockLister := NewMockMetricLister(ctrl)
+	handler := NewMetricListHTMLHandler(WithMetricListerHTML(mockLister))
+
+	r := chi.NewRouter()
+	handler.RegisterRoute(r)
+
+	delta := int64(7)
+	value := 1.5
+
+	t.Run("Lists metrics", func(t *testing.T) {
+		mockLister.EXPECT().
+			List(gomock.Any()).
+			Return([]*models.Metrics{
+				{ID: "PollCount", MType: models.Counter, Delta: &delta},
+				{ID: "Alloc", MType: models.Gauge, Value: &value},
+			}, nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rr := httptest.NewRecorder()
+
+		r.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "text/html; charset=utf-8", rr.Header().Get("Content-Type"))
+		assert.Contains(t, rr.Body.String(), "<td>counter</td><td>PollCount</td><td></td><td>7</td>")
+		assert.Contains(t, rr.Body.String(), "<td>gauge</td><td>Alloc</td><td></td><td>1.5</td>")
+	})
+
+	t.Run("Filters by label matchers", func(t *testing.T) {
+		mockLister.EXPECT().
+			List(gomock.Any()).
+			Return([]*models.Metrics{
+				{ID: "requests", MType: models.Counter, Labels: `method="GET",route="/a"`, Delta: &delta},
+				{ID: "requests", MType: models.Counter, Labels: `method="POST",route="/a"`, Delta: &delta},
+				{ID: "requests", MType: models.Counter, Labels: `method="GET",route="/b"`, Delta: &delta},
+			}, nil)
+
+		req := httptest.NewRequest(http.MethodGet, `/?match={route="/a"}&match={method!="POST"}`, nil)
+		rr := httptest.NewRecorder()
+
+		r.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Body.String(), "<td>method=&#34;GET&#34;,route=&#34;/a&#34;</td>")
+		assert.NotContains(t, rr.Body.String(), "POST")
+		assert.NotContains(t, rr.Body.String(), "route=&#34;/b")
+	})
+
+	t.Run("Invalid matcher", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, `/?match={route~"/a"}`, nil)
+		rr := httptest.NewRecorder()
+
+		r.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("Lister returns error", func(t *testing.T) {
+		mockLister.EXPECT().
+			List(gomock.Any()).
+			Return(nil, context.DeadlineExceeded)
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rr := httptest.NewRecorder()
+
+		r.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	})
+}
+
+func TestMetricUpdateBodyHandler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUpdater := NewMockMetricUpdater(ctrl)
+	handler := NewMetricUpdateBodyHandler(WithMetricUpdaterBody(mockUpdater))
+
+	r := chi.NewRouter()
+	handler.RegisterRoute(r)
+
+	accumulated := int64(15)
+
+	tests := []struct {
+		name         string
+		body         string
+		mockExpect   func()
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name: "Counter returns accumulated delta",
+			body: `{"id":"myCounter","type":"counter","delta":5}`,
+			mockExpect: func() {
+				mockUpdater.EXPECT().
+					Update(gomock.Any(), gomock.AssignableToTypeOf([]*models.Metrics{})).
+					Return([]*models.Metrics{
+						{ID: "myCounter", MType: models.Counter, Delta: &accumulated},
+					}, nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `{"id":"myCounter","type":"counter","delta":15}`,
+		},
+		{
+			name: "Gauge",
+			body: `{"id":"myGauge","type":"gauge","value":1.5}`,
+			mockExpect: func() {
+				mockUpdater.EXPECT().
+					Update(gomock.Any(), gomock.AssignableToTypeOf([]*models.Metrics{})).
+					DoAndReturn(func(_ context.Context, metrics []*models.Metrics) ([]*models.Metrics, error) {
+						return metrics, nil
+					})
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `{"id":"myGauge","type":"gauge","value":1.5}`,
+		},
+		{
+			name: "Counter with labels returns its own series",
+			body: `{"id":"requests","type":"counter","labels":{"route":"/a","method":"GET"},"delta":5}`,
+			mockExpect: func() {
+				mockUpdater.EXPECT().
+					Update(gomock.Any(), gomock.AssignableToTypeOf([]*models.Metrics{})).
+					Return([]*models.Metrics{
+						{ID: "requests", MType: models.Counter, Delta: &accumulated},
+						{ID: "requests", MType: models.Counter, Labels: `method="GET",route="/a"`, Delta: &accumulated},
+					}, nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `{"id":"requests","type":"counter","labels":{"method":"GET","route":"/a"},"delta":15}`,
+		},
+		{
+			name:         "Invalid label name",
+			body:         `{"id":"requests","type":"counter","labels":{"__name__":"x"},"delta":5}`,
+			mockExpect:   func() {},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Malformed JSON",
+			body:         `{"id":`,
+			mockExpect:   func() {},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Missing ID",
+			body:         `{"type":"gauge","value":1.5}`,
+			mockExpect:   func() {},
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:         "Counter without delta",
+			body:         `{"id":"myCounter","type":"counter","value":1.5}`,
+			mockExpect:   func() {},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Gauge without value",
+			body:         `{"id":"myGauge","type":"gauge","delta":1}`,
+			mockExpect:   func() {},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Unsupported metric type",
+			body:         `{"id":"myMetric","type":"unknown","value":1}`,
+			mockExpect:   func() {},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name: "Histogram buckets",
+			body: `{"id":"latency","type":"histogram","histogram":{"bounds":[0.1,1],"counts":[1,0,2],"sum":7,"count":3}}`,
+			mockExpect: func() {
+				mockUpdater.EXPECT().
+					Update(gomock.Any(), gomock.Any()).
+					Return([]*models.Metrics{{
+						ID: "latency", MType: models.Histogram,
+						Histogram: &models.HistogramValue{Bounds: []float64{0.1, 1}, Counts: []uint64{2, 0, 2}, Sum: 7.05, Count: 4},
+					}}, nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `{"id":"latency","type":"histogram","histogram":{"bounds":[0.1,1],"counts":[2,0,2],"sum":7.05,"count":4}}`,
+		},
+		{
+			name:         "Histogram with both an observation and buckets",
+			body:         `{"id":"latency","type":"histogram","value":1,"histogram":{"bounds":[],"counts":[1],"sum":1,"count":1}}`,
+			mockExpect:   func() {},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Histogram with inconsistent buckets",
+			body:         `{"id":"latency","type":"histogram","histogram":{"bounds":[1],"counts":[1,1],"sum":1,"count":1}}`,
+			mockExpect:   func() {},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name: "Summary sketch",
+			body: `{"id":"latency","type":"summary","summary":{"relative_accuracy":0.01,"positive":{"35":1},"count":1,"sum":2,"min":2,"max":2}}`,
+			mockExpect: func() {
+				mockUpdater.EXPECT().
+					Update(gomock.Any(), gomock.Any()).
+					Return([]*models.Metrics{{
+						ID: "latency", MType: models.Summary,
+						Summary: &ddsketch.Sketch{RelativeAccuracy: 0.01, Positive: map[int32]uint64{35: 2}, Count: 2, Sum: 4, Min: 2, Max: 2},
+					}}, nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `{"id":"latency","type":"summary","summary":{"relative_accuracy":0.01,"positive":{"35":2},"count":2,"sum":4,"min":2,"max":2}}`,
+		},
+		{
+			name:         "Summary with both an observation and a sketch",
+			body:         `{"id":"latency","type":"summary","value":1,"summary":{"relative_accuracy":0.01,"zero":1,"count":1}}`,
+			mockExpect:   func() {},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Summary with an inconsistent sketch",
+			body:         `{"id":"latency","type":"summary","summary":{"relative_accuracy":0.01,"zero":1,"count":2}}`,
+			mockExpect:   func() {},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name: "Summary accuracy differs from the stored one",
+			body: `{"id":"latency","type":"summary","summary":{"relative_accuracy":0.05,"zero":1,"count":1}}`,
+			mockExpect: func() {
+				mockUpdater.EXPECT().
+					Update(gomock.Any(), gomock.Any()).
+					Return(nil, fmt.Errorf("summary %q: %w", "latency", ddsketch.ErrAccuracyMismatch))
+			},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name: "Set members",
+			body: `{"id":"users","type":"set","members":["alice","alice"]}`,
+			mockExpect: func() {
+				mockUpdater.EXPECT().
+					Update(gomock.Any(), []*models.Metrics{{ID: "users", MType: models.Set, Members: []string{"alice", "alice"}}}).
+					Return([]*models.Metrics{{ID: "users", MType: models.Set, Set: testSet(t)}}, nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `{"id":"users","type":"set","set":` + testSetJSON + `}`,
+		},
+		{
+			name:         "Set with a value",
+			body:         `{"id":"users","type":"set","value":1,"members":["alice"]}`,
+			mockExpect:   func() {},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Set without members",
+			body:         `{"id":"users","type":"set","members":[]}`,
+			mockExpect:   func() {},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Set with a malformed sketch",
+			body:         `{"id":"users","type":"set","set":{"precision":4,"registers":"AA=="}}`,
+			mockExpect:   func() {},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Counter with set members",
+			body:         `{"id":"c","type":"counter","delta":1,"members":["alice"]}`,
+			mockExpect:   func() {},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name: "Set precision differs from the stored one",
+			body: `{"id":"users","type":"set","set":` + testSetJSON + `}`,
+			mockExpect: func() {
+				mockUpdater.EXPECT().
+					Update(gomock.Any(), gomock.Any()).
+					Return(nil, fmt.Errorf("set %q: %w", "users", hyperloglog.ErrPrecisionMismatch))
+			},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name: "Histogram bounds differ from the stored ones",
+			body: `{"id":"latency","type":"histogram","histogram":{"bounds":[2],"counts":[1,0],"sum":1,"count":1}}`,
+			mockExpect: func() {
+				mockUpdater.EXPECT().
+					Update(gomock.Any(), gomock.Any()).
+					Return(nil, fmt.Errorf("histogram %q: %w", "latency", models.ErrHistogramBuckets))
+			},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name: "Updater returns error",
+			body: `{"id":"myGauge","type":"gauge","value":1.5}`,
+			mockExpect: func() {
+				mockUpdater.EXPECT().
+					Update(gomock.Any(), gomock.Any()).
+					Return(nil, context.DeadlineExceeded)
+			},
+			expectedCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockExpect()
+
+			req := httptest.NewRequest(http.MethodPost, "/update/", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedCode, rr.Code)
+			if tt.expectedBody != "" {
+				assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+				assert.JSONEq(t, tt.expectedBody, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestMetricGetBodyHandler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockGetter := NewMockMetricGetter(ctrl)
+	handler := NewMetricGetBodyHandler(WithMetricGetterBody(mockGetter))
+
+	r := chi.NewRouter()
+	handler.RegisterRoute(r)
+
+	delta := int64(42)
+
+	tests := []struct {
+		name         string
+		body         string
+		mockExpect   func()
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name: "Counter found",
+			body: `{"id":"myCounter","type":"counter"}`,
+			mockExpect: func() {
+				mockGetter.EXPECT().
+					Get(gomock.Any(), models.MetricID{ID: "myCounter", MType: models.Counter}).
+					Return(&models.Metrics{ID: "myCounter", MType: models.Counter, Delta: &delta}, nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `{"id":"myCounter","type":"counter","delta":42}`,
+		},
+		{
+			name: "Counter with labels",
+			body: `{"id":"requests","type":"counter","labels":{"route":"/a"}}`,
+			mockExpect: func() {
+				mockGetter.EXPECT().
+					Get(gomock.Any(), models.MetricID{ID: "requests", MType: models.Counter, Labels: `route="/a"`}).
+					Return(&models.Metrics{ID: "requests", MType: models.Counter, Labels: `route="/a"`, Delta: &delta}, nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `{"id":"requests","type":"counter","labels":{"route":"/a"},"delta":42}`,
+		},
+		{
+			name: "Set found",
+			body: `{"id":"users","type":"set"}`,
+			mockExpect: func() {
+				mockGetter.EXPECT().
+					Get(gomock.Any(), models.MetricID{ID: "users", MType: models.Set}).
+					Return(&models.Metrics{ID: "users", MType: models.Set, Set: testSet(t)}, nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `{"id":"users","type":"set","set":` + testSetJSON + `}`,
+		},
+		{
+			name: "Unknown metric",
+			body: `{"id":"missing","type":"gauge"}`,
+			mockExpect: func() {
+				mockGetter.EXPECT().
+					Get(gomock.Any(), models.MetricID{ID: "missing", MType: models.Gauge}).
+					Return(nil, nil)
+			},
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:         "Malformed JSON",
+			body:         `not json`,
+			mockExpect:   func() {},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Missing ID",
+			body:         `{"type":"gauge"}`,
+			mockExpect:   func() {},
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:         "Unsupported metric type",
+			body:         `{"id":"myMetric","type":"unknown"}`,
+			mockExpect:   func() {},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name: "Getter returns error",
+			body: `{"id":"myCounter","type":"counter"}`,
+			mockExpect: func() {
+				mockGetter.EXPECT().
+					Get(gomock.Any(), gomock.Any()).
+					Return(nil, context.DeadlineExceeded)
+			},
+			expectedCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockExpect()
+
+			req := httptest.NewRequest(http.MethodPost, "/value/", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedCode, rr.Code)
+			if tt.expectedBody != "" {
+				assert.JSONEq(t, tt.expectedBody, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestMetricUpdatesBodyHandler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUpdater := NewMockMetricUpdater(ctrl)
+	handler := NewMetricUpdatesBodyHandler(WithMetricUpdaterBatch(mockUpdater))
+
+	r := chi.NewRouter()
+	handler.RegisterRoute(r)
+
+	delta := int64(8)
+	value := 1.5
+
+	tests := []struct {
+		name         string
+		body         string
+		mockExpect   func()
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name: "Valid batch",
+			body: `[{"id":"c","type":"counter","delta":3},{"id":"c","type":"counter","delta":5},{"id":"g","type":"gauge","value":1.5}]`,
+			mockExpect: func() {
+				mockUpdater.EXPECT().
+					Update(gomock.Any(), gomock.Len(3)).
+					Return([]*models.Metrics{
+						{ID: "c", MType: models.Counter, Delta: &delta},
+						{ID: "g", MType: models.Gauge, Value: &value},
+					}, nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `[{"id":"c","type":"counter","delta":8},{"id":"g","type":"gauge","value":1.5}]`,
+		},
+		{
+			name:         "Malformed JSON",
+			body:         `[{"id":"c"`,
+			mockExpect:   func() {},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "One invalid metric rejects the batch",
+			body:         `[{"id":"g","type":"gauge","value":1.5},{"id":"c","type":"counter"}]`,
+			mockExpect:   func() {},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Null element",
+			body:         `[null]`,
+			mockExpect:   func() {},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name: "Updater returns error",
+			body: `[{"id":"g","type":"gauge","value":1.5}]`,
+			mockExpect: func() {
+				mockUpdater.EXPECT().
+					Update(gomock.Any(), gomock.Any()).
+					Return(nil, context.DeadlineExceeded)
+			},
+			expectedCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockExpect()
+
+			req := httptest.NewRequest(http.MethodPost, "/updates/", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedCode, rr.Code)
+			if tt.expectedBody != "" {
+				assert.JSONEq(t, tt.expectedBody, rr.Body.String())
+			}
+		})
+	}
+}
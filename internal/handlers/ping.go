@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// DBPinger defines an interface for checking database connectivity.
+type DBPinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// Functional options for PingHandler
+type PingHandlerOption func(*PingHandler)
+
+func WithDBPinger(db DBPinger) PingHandlerOption {
+	return func(h *PingHandler) {
+		h.db = db
+	}
+}
+
+// PingHandler reports whether the database is reachable.
+type PingHandler struct {
+	db DBPinger
+}
+
+func NewPingHandler(opts ...PingHandlerOption) *PingHandler {
+	h := &PingHandler{}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+func (h *PingHandler) Ping(w http.ResponseWriter, r *http.Request) {
+	if h.db == nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.db.PingContext(r.Context()); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *PingHandler) RegisterRoute(r chi.Router) {
+	r.Get("/ping", h.Ping)
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPingHandler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockPinger := NewMockDBPinger(ctrl)
+
+	tests := []struct {
+		name         string
+		handler      *PingHandler
+		mockExpect   func()
+		expectedCode int
+	}{
+		{
+			name:    "Database reachable",
+			handler: NewPingHandler(WithDBPinger(mockPinger)),
+			mockExpect: func() {
+				mockPinger.EXPECT().PingContext(gomock.Any()).Return(nil)
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:    "Database unreachable",
+			handler: NewPingHandler(WithDBPinger(mockPinger)),
+			mockExpect: func() {
+				mockPinger.EXPECT().PingContext(gomock.Any()).Return(errors.New("connection refused"))
+			},
+			expectedCode: http.StatusInternalServerError,
+		},
+		{
+			name:         "Database not configured",
+			handler:      NewPingHandler(),
+			mockExpect:   func() {},
+			expectedCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockExpect()
+
+			r := chi.NewRouter()
+			tt.handler.RegisterRoute(r)
+
+			req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+			rr := httptest.NewRecorder()
+
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedCode, rr.Code)
+		})
+	}
+}
@@ -0,0 +1,281 @@
+package handlers
+
+import (
+	"bytes"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/models"
+)
+
+// Content types of the two exposition formats served by MetricPrometheusHandler.
+const (
+	PrometheusTextContentType = "text/plain; version=0.0.4; charset=utf-8"
+	OpenMetricsContentType    = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+)
+
+// Functional options for MetricPrometheusHandler
+type MetricPrometheusHandlerOption func(*MetricPrometheusHandler)
+
+func WithMetricListerPrometheus(lister MetricLister) MetricPrometheusHandlerOption {
+	return func(h *MetricPrometheusHandler) {
+		h.lister = lister
+	}
+}
+
+// MetricPrometheusHandler renders every stored metric in the Prometheus text
+// exposition format, or in OpenMetrics when the scraper asks for it.
+type MetricPrometheusHandler struct {
+	lister MetricLister
+}
+
+func NewMetricPrometheusHandler(opts ...MetricPrometheusHandlerOption) *MetricPrometheusHandler {
+	h := &MetricPrometheusHandler{}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+func (h *MetricPrometheusHandler) Metrics(w http.ResponseWriter, r *http.Request) {
+	matchers, err := queryMatchers(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	metrics, err := h.lister.List(r.Context())
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	metrics = filterMetrics(metrics, matchers)
+
+	openMetrics := acceptsOpenMetrics(r.Header.Get("Accept"))
+
+	var buf bytes.Buffer
+	writeExposition(&buf, metrics, openMetrics)
+
+	if openMetrics {
+		w.Header().Set("Content-Type", OpenMetricsContentType)
+	} else {
+		w.Header().Set("Content-Type", PrometheusTextContentType)
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
+
+func (h *MetricPrometheusHandler) RegisterRoute(r chi.Router) {
+	r.Get("/metrics", h.Metrics)
+}
+
+// writeExposition writes one family per metric id, sorted by name, with a
+// sample per label set. Metric ids whose family or sample names, such as the
+// _bucket, _sum and _count of a histogram or the _sum and _count of a
+// summary, are already taken are skipped, a name cannot be exposed twice.
+func writeExposition(buf *bytes.Buffer, metrics []*models.Metrics, openMetrics bool) {
+	sorted := make([]*models.Metrics, 0, len(metrics))
+	for _, metric := range metrics {
+		if metric != nil && formatMetricValue(metric) != "" {
+			sorted = append(sorted, metric)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].ID != sorted[j].ID {
+			return sorted[i].ID < sorted[j].ID
+		}
+		if sorted[i].MType != sorted[j].MType {
+			return sorted[i].MType < sorted[j].MType
+		}
+		return sorted[i].Labels < sorted[j].Labels
+	})
+
+	owners := make(map[string]models.MetricID)
+	for _, metric := range sorted {
+		name := prometheusName(metric.ID)
+
+		// OpenMetrics appends _total to counter samples, the family name
+		// itself must not carry the suffix.
+		family := name
+		sample := name
+		if metric.MType == models.Counter && openMetrics {
+			family = strings.TrimSuffix(name, "_total")
+			sample = family + "_total"
+		}
+
+		owner := models.MetricID{ID: metric.ID, MType: metric.MType}
+		if current, taken := owners[family]; !taken {
+			names := exposedNames(family, sample, metric.MType)
+			if namesTaken(owners, names) {
+				continue
+			}
+			for _, n := range names {
+				owners[n] = owner
+			}
+			buf.WriteString("# HELP " + family + " " + escapeHelp(metric.MType+" metric "+metric.ID) + "\n")
+			buf.WriteString("# TYPE " + family + " " + prometheusType(metric.MType) + "\n")
+		} else if current != owner {
+			continue
+		}
+
+		switch metric.MType {
+		case models.Histogram:
+			writeHistogramSamples(buf, name, metric)
+			continue
+		case models.Summary:
+			writeSummarySamples(buf, name, metric)
+			continue
+		}
+		buf.WriteString(sample + labelSet(metric.Labels, "") + " " + formatSampleValue(metric) + "\n")
+	}
+
+	if openMetrics {
+		buf.WriteString("# EOF\n")
+	}
+}
+
+// exposedNames returns the family name followed by every sample name the
+// family writes.
+func exposedNames(family, sample, mtype string) []string {
+	names := []string{family}
+	switch mtype {
+	case models.Histogram:
+		names = append(names, family+"_bucket", family+"_sum", family+"_count")
+	case models.Summary:
+		names = append(names, family+"_sum", family+"_count")
+	default:
+		if sample != family {
+			names = append(names, sample)
+		}
+	}
+	return names
+}
+
+func namesTaken(owners map[string]models.MetricID, names []string) bool {
+	for _, n := range names {
+		if _, taken := owners[n]; taken {
+			return true
+		}
+	}
+	return false
+}
+
+// writeHistogramSamples writes the cumulative _bucket samples, each with an
+// le label, followed by _sum and _count.
+func writeHistogramSamples(buf *bytes.Buffer, name string, metric *models.Metrics) {
+	h := metric.Histogram
+	for i, count := range h.Cumulative() {
+		le := models.HistogramBucketLabel + `="` + strconv.FormatFloat(h.Bounds[i], 'g', -1, 64) + `"`
+		buf.WriteString(name + "_bucket" + labelSet(metric.Labels, le) + " " + strconv.FormatUint(count, 10) + "\n")
+	}
+	buf.WriteString(name + "_bucket" + labelSet(metric.Labels, models.HistogramBucketLabel+`="+Inf"`) + " " + strconv.FormatUint(h.Count, 10) + "\n")
+	buf.WriteString(name + "_sum" + labelSet(metric.Labels, "") + " " + strconv.FormatFloat(h.Sum, 'g', -1, 64) + "\n")
+	buf.WriteString(name + "_count" + labelSet(metric.Labels, "") + " " + strconv.FormatUint(h.Count, 10) + "\n")
+}
+
+// writeSummarySamples writes a sample per default quantile, each with a
+// quantile label, followed by _sum and _count.
+func writeSummarySamples(buf *bytes.Buffer, name string, metric *models.Metrics) {
+	s := metric.Summary
+	for _, q := range summaryQuantiles {
+		quantile := models.SummaryQuantileLabel + `="` + strconv.FormatFloat(q, 'g', -1, 64) + `"`
+		buf.WriteString(name + labelSet(metric.Labels, quantile) + " " + strconv.FormatFloat(s.Quantile(q), 'g', -1, 64) + "\n")
+	}
+	buf.WriteString(name + "_sum" + labelSet(metric.Labels, "") + " " + strconv.FormatFloat(s.Sum, 'g', -1, 64) + "\n")
+	buf.WriteString(name + "_count" + labelSet(metric.Labels, "") + " " + strconv.FormatUint(s.Count, 10) + "\n")
+}
+
+// prometheusType returns the family type of a metric type. Prometheus has no
+// sets, their cardinality estimate is a gauge.
+func prometheusType(mtype string) string {
+	if mtype == models.Set {
+		return "gauge"
+	}
+	return mtype
+}
+
+// labelSet renders labels in braces with an extra name="value" pair
+// appended, or nothing when both are empty.
+func labelSet(labels models.Labels, extra string) string {
+	switch {
+	case labels == "" && extra == "":
+		return ""
+	case labels == "":
+		return "{" + extra + "}"
+	case extra == "":
+		return "{" + string(labels) + "}"
+	default:
+		return "{" + string(labels) + "," + extra + "}"
+	}
+}
+
+// prometheusName maps a metric id onto [a-zA-Z_:][a-zA-Z0-9_:]*, replacing
+// every other character with an underscore.
+func prometheusName(id string) string {
+	var b strings.Builder
+	for i, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c == '_', c == ':':
+			b.WriteRune(c)
+		case c >= '0' && c <= '9':
+			if i == 0 {
+				b.WriteByte('_')
+			}
+			b.WriteRune(c)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	if b.Len() == 0 {
+		return "_"
+	}
+	return b.String()
+}
+
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+// formatSampleValue formats the value the way the Prometheus client does,
+// including +Inf, -Inf and NaN.
+func formatSampleValue(metric *models.Metrics) string {
+	if metric.MType == models.Gauge {
+		return strconv.FormatFloat(*metric.Value, 'g', -1, 64)
+	}
+	return formatMetricValue(metric)
+}
+
+// acceptsOpenMetrics reports whether the Accept header prefers OpenMetrics
+// over the classic text format. Ties go to OpenMetrics, which is how
+// Prometheus announces support for it.
+func acceptsOpenMetrics(accept string) bool {
+	var openMetricsQ, textQ float64
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+
+		switch mediaType {
+		case "application/openmetrics-text":
+			openMetricsQ = max(openMetricsQ, q)
+		case "text/plain", "text/*", "*/*":
+			textQ = max(textQ, q)
+		}
+	}
+	return openMetricsQ > 0 && openMetricsQ >= textQ
+}
@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/ddsketch"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/models"
+)
+
+func TestMetricPrometheusHandler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockLister := NewMockMetricLister(ctrl)
+	handler := NewMetricPrometheusHandler(WithMetricListerPrometheus(mockLister))
+
+	r := chi.NewRouter()
+	handler.RegisterRoute(r)
+
+	delta := int64(7)
+	value := 1.5
+	inf := math.Inf(1)
+	summary, _ := ddsketch.New(ddsketch.DefaultRelativeAccuracy)
+	summary.Add(2)
+
+	metrics := []*models.Metrics{
+		{ID: "PollCount", MType: models.Counter, Delta: &delta},
+		{ID: "Alloc", MType: models.Gauge, Value: &value},
+		{ID: "cpu.usage-1", MType: models.Gauge, Value: &inf},
+		{ID: "cpu_usage_1", MType: models.Gauge, Value: &value},
+		{ID: "9lives", MType: models.Counter, Delta: &delta},
+		{ID: "empty", MType: models.Gauge},
+		{ID: "requests", MType: models.Counter, Labels: `route="/b"`, Delta: &delta},
+		{ID: "requests", MType: models.Counter, Labels: `method="GET",route="/a"`, Delta: &delta},
+		{
+			ID: "latency", MType: models.Histogram, Labels: `route="/a"`,
+			Histogram: &models.HistogramValue{Bounds: []float64{0.1, 1}, Counts: []uint64{1, 0, 2}, Sum: 7, Count: 3},
+		},
+		{ID: "rpc", MType: models.Summary, Labels: `method="Get"`, Summary: summary},
+		{ID: "users", MType: models.Set, Set: testSet(t)},
+	}
+
+	tests := []struct {
+		name                string
+		accept              string
+		expectedContentType string
+		expectedBody        string
+	}{
+		{
+			name:                "Prometheus text format by default",
+			expectedContentType: PrometheusTextContentType,
+			expectedBody: `# HELP _9lives counter metric 9lives
+# TYPE _9lives counter
+_9lives 7
+# HELP Alloc gauge metric Alloc
+# TYPE Alloc gauge
+Alloc 1.5
+# HELP PollCount counter metric PollCount
+# TYPE PollCount counter
+PollCount 7
+# HELP cpu_usage_1 gauge metric cpu.usage-1
+# TYPE cpu_usage_1 gauge
+cpu_usage_1 +Inf
+# HELP latency histogram metric latency
+# TYPE latency histogram
+latency_bucket{route="/a",le="0.1"} 1
+latency_bucket{route="/a",le="1"} 1
+latency_bucket{route="/a",le="+Inf"} 3
+latency_sum{route="/a"} 7
+latency_count{route="/a"} 3
+# HELP requests counter metric requests
+# TYPE requests counter
+requests{method="GET",route="/a"} 7
+requests{route="/b"} 7
+# HELP rpc summary metric rpc
+# TYPE rpc summary
+rpc{method="Get",quantile="0.5"} 2
+rpc{method="Get",quantile="0.9"} 2
+rpc{method="Get",quantile="0.95"} 2
+rpc{method="Get",quantile="0.99"} 2
+rpc_sum{method="Get"} 2
+rpc_count{method="Get"} 1
+# HELP users set metric users
+# TYPE users gauge
+users 1
+`,
+		},
+		{
+			name:                "OpenMetrics as sent by Prometheus",
+			accept:              "application/openmetrics-text;version=1.0.0,application/openmetrics-text;version=0.0.1;q=0.75,text/plain;version=0.0.4;q=0.5,*/*;q=0.1",
+			expectedContentType: OpenMetricsContentType,
+			expectedBody: `# HELP _9lives counter metric 9lives
+# TYPE _9lives counter
+_9lives_total 7
+# HELP Alloc gauge metric Alloc
+# TYPE Alloc gauge
+Alloc 1.5
+# HELP PollCount counter metric PollCount
+# TYPE PollCount counter
+PollCount_total 7
+# HELP cpu_usage_1 gauge metric cpu.usage-1
+# TYPE cpu_usage_1 gauge
+cpu_usage_1 +Inf
+# HELP latency histogram metric latency
+# TYPE latency histogram
+latency_bucket{route="/a",le="0.1"} 1
+latency_bucket{route="/a",le="1"} 1
+latency_bucket{route="/a",le="+Inf"} 3
+latency_sum{route="/a"} 7
+latency_count{route="/a"} 3
+# HELP requests counter metric requests
+# TYPE requests counter
+requests_total{method="GET",route="/a"} 7
+requests_total{route="/b"} 7
+# HELP rpc summary metric rpc
+# TYPE rpc summary
+rpc{method="Get",quantile="0.5"} 2
+rpc{method="Get",quantile="0.9"} 2
+rpc{method="Get",quantile="0.95"} 2
+rpc{method="Get",quantile="0.99"} 2
+rpc_sum{method="Get"} 2
+rpc_count{method="Get"} 1
+# HELP users set metric users
+# TYPE users gauge
+users 1
+# EOF
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockLister.EXPECT().List(gomock.Any()).Return(metrics, nil)
+
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rr := httptest.NewRecorder()
+
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, http.StatusOK, rr.Code)
+			assert.Equal(t, tt.expectedContentType, rr.Header().Get("Content-Type"))
+			assert.Equal(t, tt.expectedBody, rr.Body.String())
+		})
+	}
+
+	t.Run("Filters by label matchers", func(t *testing.T) {
+		mockLister.EXPECT().List(gomock.Any()).Return(metrics, nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/metrics?match="+url.QueryEscape(`{route=~"/a|/c",method="GET"}`), nil)
+		rr := httptest.NewRecorder()
+
+		r.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, `# HELP requests counter metric requests
+# TYPE requests counter
+requests{method="GET",route="/a"} 7
+`, rr.Body.String())
+	})
+
+	t.Run("Invalid matcher", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/metrics?match="+url.QueryEscape(`{route=~"("}`), nil)
+		rr := httptest.NewRecorder()
+
+		r.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("Lister returns error", func(t *testing.T) {
+		mockLister.EXPECT().List(gomock.Any()).Return(nil, context.DeadlineExceeded)
+
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		rr := httptest.NewRecorder()
+
+		r.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	})
+}
+
+func TestWriteExposition_NameCollisions(t *testing.T) {
+	value := 1.0
+	histogram := &models.HistogramValue{Bounds: []float64{1}, Counts: []uint64{1, 0}, Sum: 0.5, Count: 1}
+	summary, _ := ddsketch.New(ddsketch.DefaultRelativeAccuracy)
+	summary.Add(2)
+
+	var buf bytes.Buffer
+	writeExposition(&buf, []*models.Metrics{
+		{ID: "db.latency", MType: models.Histogram, Histogram: histogram},
+		// Sorts before db.latency and takes its _sum sample name.
+		{ID: "db-latency_sum", MType: models.Gauge, Value: &value},
+		{ID: "http", MType: models.Histogram, Histogram: histogram},
+		{ID: "http_count", MType: models.Gauge, Value: &value},
+		{ID: "rpc", MType: models.Summary, Summary: summary},
+		{ID: "rpc_sum", MType: models.Gauge, Value: &value},
+	}, false)
+
+	assert.Equal(t, `# HELP db_latency_sum gauge metric db-latency_sum
+# TYPE db_latency_sum gauge
+db_latency_sum 1
+# HELP http histogram metric http
+# TYPE http histogram
+http_bucket{le="1"} 1
+http_bucket{le="+Inf"} 1
+http_sum 0.5
+http_count 1
+# HELP rpc summary metric rpc
+# TYPE rpc summary
+rpc{quantile="0.5"} 2
+rpc{quantile="0.9"} 2
+rpc{quantile="0.95"} 2
+rpc{quantile="0.99"} 2
+rpc_sum 2
+rpc_count 1
+`, buf.String())
+}
+
+func TestAcceptsOpenMetrics(t *testing.T) {
+	tests := []struct {
+		accept   string
+		expected bool
+	}{
+		{accept: "", expected: false},
+		{accept: "*/*", expected: false},
+		{accept: "text/plain;version=0.0.4", expected: false},
+		{accept: "application/openmetrics-text", expected: true},
+		{accept: "text/plain, application/openmetrics-text;q=0.5", expected: false},
+		{accept: "application/openmetrics-text;q=0", expected: false},
+		{accept: "application/openmetrics-text;version=1.0.0;q=0.9,*/*;q=0.1", expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			assert.Equal(t, tt.expected, acceptsOpenMetrics(tt.accept))
+		})
+	}
+}
+
+func TestPrometheusName(t *testing.T) {
+	tests := []struct {
+		id       string
+		expected string
+	}{
+		{id: "Alloc", expected: "Alloc"},
+		{id: "http:requests_total", expected: "http:requests_total"},
+		{id: "cpu.usage-1", expected: "cpu_usage_1"},
+		{id: "1m", expected: "_1m"},
+		{id: "загрузка", expected: "________"},
+		{id: "", expected: "_"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			assert.Equal(t, tt.expected, prometheusName(tt.id))
+		})
+	}
+}
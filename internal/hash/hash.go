@@ -0,0 +1,30 @@
+package hash
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// Header is the HTTP header carrying the hex-encoded HMAC-SHA256 of the body.
+const Header = "HashSHA256"
+
+// Sign returns the hex-encoded HMAC-SHA256 of data keyed with key.
+func Sign(data []byte, key string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether sum is the valid signature of data for key.
+func Verify(data []byte, key string, sum string) bool {
+	expected, err := hex.DecodeString(sum)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write(data)
+
+	return hmac.Equal(mac.Sum(nil), expected)
+}
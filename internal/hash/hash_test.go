@@ -0,0 +1,26 @@
+package hash
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSign(t *testing.T) {
+	// HMAC-SHA256("key", "The quick brown fox jumps over the lazy dog")
+	assert.Equal(t,
+		"f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8",
+		Sign([]byte("The quick brown fox jumps over the lazy dog"), "key"),
+	)
+}
+
+func TestVerify(t *testing.T) {
+	data := []byte(`[{"id":"a","type":"gauge","value":1}]`)
+	sum := Sign(data, "secret")
+
+	assert.True(t, Verify(data, "secret", sum))
+	assert.False(t, Verify(data, "other", sum))
+	assert.False(t, Verify([]byte("tampered"), "secret", sum))
+	assert.False(t, Verify(data, "secret", "not-hex"))
+	assert.False(t, Verify(data, "secret", ""))
+}
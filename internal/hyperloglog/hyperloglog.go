@@ -0,0 +1,191 @@
+// Package hyperloglog implements HyperLogLog, a mergeable sketch estimating
+// the number of distinct members added to it in a fixed amount of memory.
+package hyperloglog
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/bits"
+)
+
+// DefaultPrecision is the precision of sets created from members, 2^14
+// registers with a standard error of about 0.8%.
+const DefaultPrecision = 14
+
+const (
+	minPrecision = 4
+	maxPrecision = 18
+)
+
+// ErrPrecisionMismatch is returned when sketches with a different precision
+// are merged.
+var ErrPrecisionMismatch = errors.New("sketch precision differs")
+
+// Sketch keeps 2^precision registers, each holding the longest run of leading
+// zeros seen in the hashes of the members routed to it. Create it with New or
+// by unmarshalling, the zero value cannot be added to.
+type Sketch struct {
+	precision uint8
+	registers []uint8
+}
+
+// New creates an empty sketch with precision in [4, 18].
+func New(precision uint8) (*Sketch, error) {
+	if err := validatePrecision(precision); err != nil {
+		return nil, err
+	}
+	return &Sketch{precision: precision, registers: make([]uint8, 1<<precision)}, nil
+}
+
+func validatePrecision(precision uint8) error {
+	if precision < minPrecision || precision > maxPrecision {
+		return fmt.Errorf("precision %d is not in [%d, %d]", precision, minPrecision, maxPrecision)
+	}
+	return nil
+}
+
+// Precision returns the number of hash bits selecting a register.
+func (s *Sketch) Precision() uint8 {
+	return s.precision
+}
+
+// Add adds a member.
+func (s *Sketch) Add(member string) {
+	h := hash(member)
+	i := h >> (64 - s.precision)
+	// The marker bit caps the run at the bits left after the register index.
+	rank := uint8(bits.LeadingZeros64(h<<s.precision|1<<(s.precision-1))) + 1
+	if rank > s.registers[i] {
+		s.registers[i] = rank
+	}
+}
+
+// hash is FNV-1a finished with the MurmurHash3 mixer, FNV alone does not
+// spread short strings over the high bits.
+func hash(member string) uint64 {
+	f := fnv.New64a()
+	f.Write([]byte(member))
+	h := f.Sum64()
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
+
+// Merge adds the members of other, which must have the same precision.
+func (s *Sketch) Merge(other *Sketch) error {
+	if s.precision != other.precision {
+		return ErrPrecisionMismatch
+	}
+	for i, r := range other.registers {
+		if r > s.registers[i] {
+			s.registers[i] = r
+		}
+	}
+	return nil
+}
+
+// Count estimates the number of distinct members, using linear counting
+// while many registers are still empty.
+func (s *Sketch) Count() uint64 {
+	m := float64(len(s.registers))
+	if m == 0 {
+		return 0
+	}
+
+	var (
+		sum   float64
+		zeros float64
+	)
+	for _, r := range s.registers {
+		sum += math.Ldexp(1, -int(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+
+	estimate := alpha(m) * m * m / sum
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/zeros)
+	}
+	return uint64(math.Round(estimate))
+}
+
+func alpha(m float64) float64 {
+	switch m {
+	case 16:
+		return 0.673
+	case 32:
+		return 0.697
+	case 64:
+		return 0.709
+	default:
+		return 0.7213 / (1 + 1.079/m)
+	}
+}
+
+// Clone returns a deep copy, nil stays nil.
+func (s *Sketch) Clone() *Sketch {
+	if s == nil {
+		return nil
+	}
+	return &Sketch{precision: s.precision, registers: append([]uint8(nil), s.registers...)}
+}
+
+// MarshalBinary encodes the precision followed by one byte per register.
+func (s *Sketch) MarshalBinary() ([]byte, error) {
+	data := make([]byte, 0, 1+len(s.registers))
+	data = append(data, s.precision)
+	return append(data, s.registers...), nil
+}
+
+// UnmarshalBinary decodes the MarshalBinary form, rejecting registers that
+// no hash can produce.
+func (s *Sketch) UnmarshalBinary(data []byte) error {
+	if len(data) == 0 {
+		return errors.New("empty sketch")
+	}
+	return s.set(data[0], data[1:])
+}
+
+func (s *Sketch) set(precision uint8, registers []byte) error {
+	if err := validatePrecision(precision); err != nil {
+		return err
+	}
+	if len(registers) != 1<<precision {
+		return fmt.Errorf("sketch has %d registers, want %d", len(registers), 1<<precision)
+	}
+	for _, r := range registers {
+		if r > 64-precision+1 {
+			return fmt.Errorf("register value %d is out of range", r)
+		}
+	}
+	s.precision = precision
+	s.registers = append([]uint8(nil), registers...)
+	return nil
+}
+
+type sketchJSON struct {
+	Precision uint8  `json:"precision"`
+	Registers []byte `json:"registers"`
+	Count     uint64 `json:"count"`
+}
+
+// MarshalJSON encodes the registers in base64 together with the estimate,
+// which is informational and ignored when decoding.
+func (s *Sketch) MarshalJSON() ([]byte, error) {
+	return json.Marshal(sketchJSON{Precision: s.precision, Registers: s.registers, Count: s.Count()})
+}
+
+func (s *Sketch) UnmarshalJSON(data []byte) error {
+	var v sketchJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	return s.set(v.Precision, v.Registers)
+}
@@ -0,0 +1,119 @@
+package hyperloglog
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSketch_Count(t *testing.T) {
+	for _, n := range []int{0, 1, 10, 1000, 100000, 1000000} {
+		t.Run(strconv.Itoa(n), func(t *testing.T) {
+			s, err := New(DefaultPrecision)
+			require.NoError(t, err)
+
+			for i := 0; i < n; i++ {
+				member := "user-" + strconv.Itoa(i)
+				s.Add(member)
+				s.Add(member)
+			}
+
+			// Four standard errors, 1.04/sqrt(2^14) each.
+			assert.InDelta(t, float64(n), float64(s.Count()), float64(n)*4*1.04/128+0.5)
+		})
+	}
+}
+
+func TestSketch_Merge(t *testing.T) {
+	a, _ := New(10)
+	b, _ := New(10)
+	all, _ := New(10)
+
+	for i := 0; i < 5000; i++ {
+		member := strconv.Itoa(i)
+		if i%3 == 0 {
+			a.Add(member)
+		} else {
+			b.Add(member)
+		}
+		if i%5 == 0 {
+			a.Add(member)
+		}
+		all.Add(member)
+	}
+
+	clone := a.Clone()
+	require.NoError(t, a.Merge(b))
+	assert.Equal(t, all, a)
+	assert.NotEqual(t, all, clone, "a clone does not share registers")
+
+	other, _ := New(12)
+	assert.ErrorIs(t, a.Merge(other), ErrPrecisionMismatch)
+}
+
+func TestSketch_Binary(t *testing.T) {
+	s, _ := New(4)
+	s.Add("a")
+	s.Add("b")
+
+	data, err := s.MarshalBinary()
+	require.NoError(t, err)
+	assert.Len(t, data, 1+16)
+	assert.Equal(t, byte(4), data[0])
+
+	var decoded Sketch
+	require.NoError(t, decoded.UnmarshalBinary(data))
+	assert.Equal(t, s, &decoded)
+	assert.Equal(t, uint64(2), decoded.Count())
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "empty", data: nil},
+		{name: "precision too low", data: append([]byte{3}, make([]byte, 8)...)},
+		{name: "too few registers", data: append([]byte{4}, make([]byte, 15)...)},
+		{name: "register out of range", data: append([]byte{4}, append(make([]byte, 15), 62)...)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var s Sketch
+			assert.Error(t, s.UnmarshalBinary(tt.data))
+		})
+	}
+}
+
+func TestSketch_JSON(t *testing.T) {
+	s, _ := New(4)
+	s.Add("a")
+
+	data, err := json.Marshal(s)
+	require.NoError(t, err)
+
+	var fields map[string]any
+	require.NoError(t, json.Unmarshal(data, &fields))
+	assert.Equal(t, 4.0, fields["precision"])
+	assert.Equal(t, 1.0, fields["count"])
+
+	var decoded Sketch
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, s, &decoded)
+
+	assert.Error(t, json.Unmarshal([]byte(`{"precision":4,"registers":"AA=="}`), &decoded))
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(3)
+	assert.Error(t, err)
+	_, err = New(19)
+	assert.Error(t, err)
+
+	s, err := New(18)
+	require.NoError(t, err)
+	assert.Equal(t, uint8(18), s.Precision())
+	assert.Equal(t, uint64(0), s.Count())
+}
@@ -0,0 +1,169 @@
+package interceptors
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/hash"
+)
+
+// signedBytes returns the deterministic encoding of a message, the bytes a
+// signature is computed over on both sides of a call.
+func signedBytes(msg any) ([]byte, bool) {
+	m, ok := msg.(proto.Message)
+	if !ok {
+		return nil, false
+	}
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(m)
+	return data, err == nil
+}
+
+// HashInterceptor verifies the HashSHA256 metadata of every request against the
+// HMAC-SHA256 of the request message and signs the response message in the
+// response header. Requests with a missing or mismatching signature are
+// rejected with InvalidArgument. An empty key disables the check.
+func HashInterceptor(key string) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		if key == "" {
+			return handler(ctx, req)
+		}
+
+		data, ok := signedBytes(req)
+		if !ok || !hash.Verify(data, key, firstValue(ctx, hash.Header)) {
+			return nil, status.Error(codes.InvalidArgument, "invalid signature")
+		}
+
+		resp, err := handler(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+
+		if data, ok := signedBytes(resp); ok {
+			grpc.SetHeader(ctx, metadata.Pairs(hash.Header, hash.Sign(data, key)))
+		}
+
+		return resp, nil
+	}
+}
+
+// HashClientInterceptor signs every outgoing request message with key. An
+// empty key disables signing.
+func HashClientInterceptor(key string) grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply any,
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		if key != "" {
+			if data, ok := signedBytes(req); ok {
+				ctx = metadata.AppendToOutgoingContext(ctx, hash.Header, hash.Sign(data, key))
+			}
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// signedMessage is a streamed message carrying its own signature. Metadata is
+// sent once per stream, so stream messages cannot be signed in it.
+type signedMessage interface {
+	SignedPayload() proto.Message
+	GetHash() string
+	SetHash(hash string)
+}
+
+// HashStreamInterceptor verifies the signature embedded in every message
+// received on a stream. A message without a valid signature aborts the stream
+// with InvalidArgument. An empty key disables the check.
+func HashStreamInterceptor(key string) grpc.StreamServerInterceptor {
+	return func(
+		srv any,
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		if key == "" {
+			return handler(srv, ss)
+		}
+		return handler(srv, &verifyingServerStream{ServerStream: ss, key: key})
+	}
+}
+
+type verifyingServerStream struct {
+	grpc.ServerStream
+	key string
+}
+
+func (s *verifyingServerStream) RecvMsg(m any) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+
+	msg, ok := m.(signedMessage)
+	if !ok {
+		return status.Error(codes.InvalidArgument, "invalid signature")
+	}
+	data, ok := signedBytes(msg.SignedPayload())
+	if !ok || !hash.Verify(data, s.key, msg.GetHash()) {
+		return status.Error(codes.InvalidArgument, "invalid signature")
+	}
+	return nil
+}
+
+// HashStreamClientInterceptor signs every message sent on a stream that
+// supports embedded signatures. An empty key disables signing.
+func HashStreamClientInterceptor(key string) grpc.StreamClientInterceptor {
+	return func(
+		ctx context.Context,
+		desc *grpc.StreamDesc,
+		cc *grpc.ClientConn,
+		method string,
+		streamer grpc.Streamer,
+		opts ...grpc.CallOption,
+	) (grpc.ClientStream, error) {
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil || key == "" {
+			return cs, err
+		}
+		return &signingClientStream{ClientStream: cs, key: key}, nil
+	}
+}
+
+type signingClientStream struct {
+	grpc.ClientStream
+	key string
+}
+
+func (s *signingClientStream) SendMsg(m any) error {
+	if msg, ok := m.(signedMessage); ok {
+		if data, ok := signedBytes(msg.SignedPayload()); ok {
+			msg.SetHash(hash.Sign(data, s.key))
+		}
+	}
+	return s.ClientStream.SendMsg(m)
+}
+
+// firstValue returns the first incoming metadata value for key.
+func firstValue(ctx context.Context, key string) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	values := md.Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
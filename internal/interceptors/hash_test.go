@@ -0,0 +1,202 @@
+package interceptors
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/hash"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/pb"
+)
+
+type echoServer struct {
+	pb.UnimplementedMetricsServiceServer
+}
+
+func (echoServer) Update(ctx context.Context, req *pb.UpdateRequest) (*pb.UpdateResponse, error) {
+	return &pb.UpdateResponse{Metric: req.GetMetric()}, nil
+}
+
+func (echoServer) GetValue(ctx context.Context, req *pb.GetValueRequest) (*pb.GetValueResponse, error) {
+	return &pb.GetValueResponse{Metric: &pb.Metric{Id: req.GetId(), Type: req.GetType()}}, nil
+}
+
+// StreamUpdates acknowledges every received metric.
+func (echoServer) StreamUpdates(stream pb.MetricsService_StreamUpdatesServer) error {
+	summary := &pb.StreamUpdatesSummary{}
+	for {
+		_, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		summary.Received++
+		if err := stream.Send(summary); err != nil {
+			return err
+		}
+	}
+}
+
+// dialTestServer serves echoServer with the given interceptors over an
+// in-memory listener and returns a client using the client interceptors.
+func dialTestServer(
+	t *testing.T,
+	server []grpc.UnaryServerInterceptor,
+	client []grpc.UnaryClientInterceptor,
+) pb.MetricsServiceClient {
+	t.Helper()
+
+	return serveEcho(t,
+		[]grpc.ServerOption{grpc.ChainUnaryInterceptor(server...)},
+		[]grpc.DialOption{grpc.WithChainUnaryInterceptor(client...)},
+	)
+}
+
+// dialStreamTestServer is dialTestServer for stream interceptors.
+func dialStreamTestServer(
+	t *testing.T,
+	server []grpc.StreamServerInterceptor,
+	client []grpc.StreamClientInterceptor,
+) pb.MetricsServiceClient {
+	t.Helper()
+
+	return serveEcho(t,
+		[]grpc.ServerOption{grpc.ChainStreamInterceptor(server...)},
+		[]grpc.DialOption{grpc.WithChainStreamInterceptor(client...)},
+	)
+}
+
+func serveEcho(t *testing.T, serverOpts []grpc.ServerOption, dialOpts []grpc.DialOption) pb.MetricsServiceClient {
+	t.Helper()
+
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(serverOpts...)
+	pb.RegisterMetricsServiceServer(srv, echoServer{})
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet", append([]grpc.DialOption{
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	}, dialOpts...)...)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return pb.NewMetricsServiceClient(conn)
+}
+
+// streamOne sends a single metric on a new stream and returns the first
+// summary or the status the stream ended with.
+func streamOne(client pb.MetricsServiceClient, req *pb.StreamUpdatesRequest) (*pb.StreamUpdatesSummary, error) {
+	stream, err := client.StreamUpdates(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	if err := stream.Send(req); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return stream.Recv()
+}
+
+func TestHashInterceptor(t *testing.T) {
+	value := 1.0
+	req := &pb.UpdateRequest{Metric: &pb.Metric{Id: "a", Type: "gauge", Value: &value}}
+
+	tests := []struct {
+		name         string
+		clientKey    string
+		expectedCode codes.Code
+	}{
+		{name: "valid signature", clientKey: "secret", expectedCode: codes.OK},
+		{name: "signature made with another key", clientKey: "other", expectedCode: codes.InvalidArgument},
+		{name: "missing signature", expectedCode: codes.InvalidArgument},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := dialTestServer(t,
+				[]grpc.UnaryServerInterceptor{HashInterceptor("secret")},
+				[]grpc.UnaryClientInterceptor{HashClientInterceptor(tt.clientKey)},
+			)
+
+			var header metadata.MD
+			resp, err := client.Update(context.Background(), req, grpc.Header(&header))
+			require.Equal(t, tt.expectedCode, status.Code(err))
+			if tt.expectedCode != codes.OK {
+				return
+			}
+
+			data, ok := signedBytes(resp)
+			require.True(t, ok)
+			values := header.Get(hash.Header)
+			require.Len(t, values, 1)
+			assert.True(t, hash.Verify(data, "secret", values[0]))
+		})
+	}
+}
+
+func TestHashInterceptor_EmptyKeyDisablesCheck(t *testing.T) {
+	client := dialTestServer(t,
+		[]grpc.UnaryServerInterceptor{HashInterceptor("")},
+		[]grpc.UnaryClientInterceptor{HashClientInterceptor("")},
+	)
+
+	var header metadata.MD
+	_, err := client.GetValue(context.Background(), &pb.GetValueRequest{Id: "a", Type: "gauge"}, grpc.Header(&header))
+	require.NoError(t, err)
+	assert.Empty(t, header.Get(hash.Header))
+}
+
+func TestHashStreamInterceptor(t *testing.T) {
+	value := 1.0
+	metric := &pb.Metric{Id: "a", Type: "gauge", Value: &value}
+
+	data, ok := signedBytes(metric)
+	require.True(t, ok)
+
+	tests := []struct {
+		name         string
+		serverKey    string
+		clientKey    string
+		hash         string
+		expectedCode codes.Code
+	}{
+		{name: "signed by client interceptor", serverKey: "secret", clientKey: "secret", expectedCode: codes.OK},
+		{name: "signed with another key", serverKey: "secret", clientKey: "other", expectedCode: codes.InvalidArgument},
+		{name: "missing signature", serverKey: "secret", expectedCode: codes.InvalidArgument},
+		{name: "signature over another payload", serverKey: "secret", hash: hash.Sign([]byte("x"), "secret"), expectedCode: codes.InvalidArgument},
+		{name: "precomputed signature", serverKey: "secret", hash: hash.Sign(data, "secret"), expectedCode: codes.OK},
+		{name: "empty key disables the check", expectedCode: codes.OK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := dialStreamTestServer(t,
+				[]grpc.StreamServerInterceptor{HashStreamInterceptor(tt.serverKey)},
+				[]grpc.StreamClientInterceptor{HashStreamClientInterceptor(tt.clientKey)},
+			)
+
+			summary, err := streamOne(client, &pb.StreamUpdatesRequest{Metric: metric, Hash: tt.hash})
+
+			require.Equal(t, tt.expectedCode, status.Code(err))
+			if tt.expectedCode == codes.OK {
+				assert.Equal(t, int64(1), summary.GetReceived())
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package interceptors
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc"
+
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/logger"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/pb"
+)
+
+func TestLoggingInterceptor(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	original := logger.Log
+	logger.Log = zap.New(core).Sugar()
+	defer func() { logger.Log = original }()
+
+	client := dialTestServer(t, []grpc.UnaryServerInterceptor{LoggingInterceptor}, nil)
+
+	_, err := client.GetValue(context.Background(), &pb.GetValueRequest{Id: "a", Type: "gauge"})
+	require.NoError(t, err)
+	_, err = client.UpdateBatch(context.Background(), &pb.UpdateBatchRequest{})
+	require.Error(t, err)
+
+	entries := logs.All()
+	require.Len(t, entries, 2)
+	assert.Equal(t, "rpc handled", entries[0].Message)
+	assert.Equal(t, pb.MetricsService_GetValue_FullMethodName, entries[0].ContextMap()["method"])
+	assert.Equal(t, "OK", entries[0].ContextMap()["code"])
+	assert.Equal(t, "Unimplemented", entries[1].ContextMap()["code"])
+	assert.Contains(t, entries[1].ContextMap(), "duration")
+}
+
+func TestLoggingStreamInterceptor(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	original := logger.Log
+	logger.Log = zap.New(core).Sugar()
+	defer func() { logger.Log = original }()
+
+	client := dialStreamTestServer(t, []grpc.StreamServerInterceptor{LoggingStreamInterceptor}, nil)
+
+	stream, err := client.StreamUpdates(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, stream.CloseSend())
+	_, err = stream.Recv()
+	require.ErrorIs(t, err, io.EOF)
+
+	require.Eventually(t, func() bool { return logs.Len() == 1 }, time.Second, 10*time.Millisecond)
+	entry := logs.All()[0]
+	assert.Equal(t, "stream handled", entry.Message)
+	assert.Equal(t, pb.MetricsService_StreamUpdates_FullMethodName, entry.ContextMap()["method"])
+	assert.Equal(t, "OK", entry.ContextMap()["code"])
+}
@@ -0,0 +1,109 @@
+package interceptors
+
+import (
+	"context"
+	"net"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// RealIPKey is the metadata key carrying the address of the calling agent.
+const RealIPKey = "x-real-ip"
+
+// TrustedSubnetInterceptor rejects with PermissionDenied calls to the given
+// methods whose x-real-ip metadata is missing, malformed or outside subnet.
+// Other methods are not restricted. A nil subnet disables the check.
+func TrustedSubnetInterceptor(subnet *net.IPNet, methods ...string) grpc.UnaryServerInterceptor {
+	restricted := methodSet(methods)
+
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		if subnet != nil && restricted[info.FullMethod] {
+			if err := checkRealIP(ctx, subnet); err != nil {
+				return nil, err
+			}
+		}
+		return handler(ctx, req)
+	}
+}
+
+// TrustedSubnetStreamInterceptor is the stream counterpart of
+// TrustedSubnetInterceptor, the address is checked once when the stream opens.
+func TrustedSubnetStreamInterceptor(subnet *net.IPNet, methods ...string) grpc.StreamServerInterceptor {
+	restricted := methodSet(methods)
+
+	return func(
+		srv any,
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		if subnet != nil && restricted[info.FullMethod] {
+			if err := checkRealIP(ss.Context(), subnet); err != nil {
+				return err
+			}
+		}
+		return handler(srv, ss)
+	}
+}
+
+func methodSet(methods []string) map[string]bool {
+	set := make(map[string]bool, len(methods))
+	for _, m := range methods {
+		set[m] = true
+	}
+	return set
+}
+
+// checkRealIP returns PermissionDenied unless the x-real-ip metadata holds an
+// address inside subnet.
+func checkRealIP(ctx context.Context, subnet *net.IPNet) error {
+	ip := net.ParseIP(firstValue(ctx, RealIPKey))
+	if ip == nil || !subnet.Contains(ip) {
+		return status.Error(codes.PermissionDenied, "address is outside the trusted subnet")
+	}
+	return nil
+}
+
+// RealIPClientInterceptor sets x-real-ip on every outgoing call to the address
+// returned by ip. The header is left out when ip fails.
+func RealIPClientInterceptor(ip func() (net.IP, error)) grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply any,
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		if addr, err := ip(); err == nil {
+			ctx = metadata.AppendToOutgoingContext(ctx, RealIPKey, addr.String())
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// RealIPStreamClientInterceptor is the stream counterpart of
+// RealIPClientInterceptor.
+func RealIPStreamClientInterceptor(ip func() (net.IP, error)) grpc.StreamClientInterceptor {
+	return func(
+		ctx context.Context,
+		desc *grpc.StreamDesc,
+		cc *grpc.ClientConn,
+		method string,
+		streamer grpc.Streamer,
+		opts ...grpc.CallOption,
+	) (grpc.ClientStream, error) {
+		if addr, err := ip(); err == nil {
+			ctx = metadata.AppendToOutgoingContext(ctx, RealIPKey, addr.String())
+		}
+		return streamer(ctx, desc, cc, method, opts...)
+	}
+}
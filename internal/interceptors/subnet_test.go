@@ -0,0 +1,126 @@
+package interceptors
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/pb"
+)
+
+// fixedIP returns an address source for the client interceptors, an empty ip
+// simulates a failing lookup.
+func fixedIP(ip string) func() (net.IP, error) {
+	return func() (net.IP, error) {
+		if ip == "" {
+			return nil, errors.New("no route")
+		}
+		return net.ParseIP(ip), nil
+	}
+}
+
+func TestTrustedSubnetInterceptor(t *testing.T) {
+	_, subnet, err := net.ParseCIDR("192.168.1.0/24")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		realIP       string
+		call         func(client pb.MetricsServiceClient) error
+		expectedCode codes.Code
+	}{
+		{
+			name:   "update inside subnet",
+			realIP: "192.168.1.17",
+			call: func(client pb.MetricsServiceClient) error {
+				_, err := client.Update(context.Background(), &pb.UpdateRequest{})
+				return err
+			},
+			expectedCode: codes.OK,
+		},
+		{
+			name:   "update outside subnet",
+			realIP: "10.0.0.1",
+			call: func(client pb.MetricsServiceClient) error {
+				_, err := client.Update(context.Background(), &pb.UpdateRequest{})
+				return err
+			},
+			expectedCode: codes.PermissionDenied,
+		},
+		{
+			name: "update without address",
+			call: func(client pb.MetricsServiceClient) error {
+				_, err := client.Update(context.Background(), &pb.UpdateRequest{})
+				return err
+			},
+			expectedCode: codes.PermissionDenied,
+		},
+		{
+			name:   "unrestricted method",
+			realIP: "10.0.0.1",
+			call: func(client pb.MetricsServiceClient) error {
+				_, err := client.GetValue(context.Background(), &pb.GetValueRequest{})
+				return err
+			},
+			expectedCode: codes.OK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := dialTestServer(t,
+				[]grpc.UnaryServerInterceptor{TrustedSubnetInterceptor(subnet, pb.MetricsService_Update_FullMethodName)},
+				[]grpc.UnaryClientInterceptor{RealIPClientInterceptor(fixedIP(tt.realIP))},
+			)
+
+			require.Equal(t, tt.expectedCode, status.Code(tt.call(client)))
+		})
+	}
+}
+
+func TestTrustedSubnetInterceptor_NilSubnetDisablesCheck(t *testing.T) {
+	client := dialTestServer(t,
+		[]grpc.UnaryServerInterceptor{TrustedSubnetInterceptor(nil, pb.MetricsService_Update_FullMethodName)},
+		nil,
+	)
+
+	_, err := client.Update(context.Background(), &pb.UpdateRequest{})
+	require.NoError(t, err)
+}
+
+func TestTrustedSubnetStreamInterceptor(t *testing.T) {
+	_, subnet, err := net.ParseCIDR("192.168.1.0/24")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		subnet       *net.IPNet
+		methods      []string
+		realIP       string
+		expectedCode codes.Code
+	}{
+		{name: "inside subnet", subnet: subnet, methods: []string{pb.MetricsService_StreamUpdates_FullMethodName}, realIP: "192.168.1.17", expectedCode: codes.OK},
+		{name: "outside subnet", subnet: subnet, methods: []string{pb.MetricsService_StreamUpdates_FullMethodName}, realIP: "10.0.0.1", expectedCode: codes.PermissionDenied},
+		{name: "without address", subnet: subnet, methods: []string{pb.MetricsService_StreamUpdates_FullMethodName}, expectedCode: codes.PermissionDenied},
+		{name: "unrestricted method", subnet: subnet, realIP: "10.0.0.1", expectedCode: codes.OK},
+		{name: "nil subnet disables the check", methods: []string{pb.MetricsService_StreamUpdates_FullMethodName}, expectedCode: codes.OK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := dialStreamTestServer(t,
+				[]grpc.StreamServerInterceptor{TrustedSubnetStreamInterceptor(tt.subnet, tt.methods...)},
+				[]grpc.StreamClientInterceptor{RealIPStreamClientInterceptor(fixedIP(tt.realIP))},
+			)
+
+			_, err := streamOne(client, &pb.StreamUpdatesRequest{})
+			require.Equal(t, tt.expectedCode, status.Code(err))
+		})
+	}
+}
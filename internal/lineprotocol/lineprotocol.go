@@ -0,0 +1,280 @@
+// Package lineprotocol parses the InfluxDB line protocol:
+//
+//	measurement[,tag=value...] field=value[,field=value...] [timestamp]
+package lineprotocol
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// maxLineSize bounds a single line, longer lines are skipped and reported.
+const maxLineSize = 1 << 20
+
+// ErrLineTooLong is reported for a line longer than maxLineSize.
+var ErrLineTooLong = fmt.Errorf("line is longer than %d bytes", maxLineSize)
+
+// Point is a parsed line.
+type Point struct {
+	// Line is the 1-based line number in the parsed input.
+	Line        int
+	Measurement string
+	Tags        map[string]string
+	Fields      []Field
+	// Timestamp is nil when the line has none.
+	Timestamp *int64
+}
+
+// Field is a single field of a point. Value holds a float64, int64, uint64,
+// string or bool, following the type suffixes of the protocol.
+type Field struct {
+	Key   string
+	Value any
+}
+
+// LineError reports a line that could not be parsed.
+type LineError struct {
+	Line int
+	Err  error
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
+
+// Parse reads every line of r. Lines that fail to parse or are too long are
+// reported in the returned LineErrors and do not stop the remaining lines from
+// being parsed. Blank lines and comments starting with # are skipped. The
+// error is non-nil only when r itself fails.
+func Parse(r io.Reader) ([]Point, []*LineError, error) {
+	var (
+		points    []Point
+		lineErrs  []*LineError
+		lineCount int
+	)
+
+	reader := bufio.NewReader(r)
+
+	for {
+		data, tooLong, readErr := readLine(reader)
+
+		if tooLong || len(data) > 0 {
+			lineCount++
+		}
+
+		if tooLong {
+			lineErrs = append(lineErrs, &LineError{Line: lineCount, Err: ErrLineTooLong})
+		} else if line := strings.TrimSpace(string(data)); line != "" && !strings.HasPrefix(line, "#") {
+			point, err := ParseLine(line)
+			if err != nil {
+				lineErrs = append(lineErrs, &LineError{Line: lineCount, Err: err})
+			} else {
+				point.Line = lineCount
+				points = append(points, point)
+			}
+		}
+
+		if readErr != nil {
+			if errors.Is(readErr, io.EOF) {
+				break
+			}
+			return nil, nil, readErr
+		}
+	}
+
+	return points, lineErrs, nil
+}
+
+// readLine reads up to and including the next newline. A line over
+// maxLineSize is read to its end but not kept, tooLong reports it.
+func readLine(reader *bufio.Reader) (line []byte, tooLong bool, err error) {
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		if !tooLong {
+			if len(line)+len(bytes.TrimRight(chunk, "\r\n")) > maxLineSize {
+				line, tooLong = nil, true
+			} else {
+				line = append(line, chunk...)
+			}
+		}
+		if !errors.Is(err, bufio.ErrBufferFull) {
+			return line, tooLong, err
+		}
+	}
+}
+
+// ParseLine parses a single line without its trailing newline.
+func ParseLine(line string) (Point, error) {
+	p := &parser{s: line}
+
+	point := Point{Measurement: p.until(", ")}
+	if point.Measurement == "" {
+		return Point{}, errors.New("missing measurement")
+	}
+
+	for p.consume(',') {
+		key := p.until(",= ")
+		if !p.consume('=') {
+			return Point{}, fmt.Errorf("tag %q has no value", key)
+		}
+		value := p.until(",= ")
+		if key == "" || value == "" {
+			return Point{}, errors.New("empty tag key or value")
+		}
+		if point.Tags == nil {
+			point.Tags = make(map[string]string)
+		}
+		point.Tags[key] = value
+	}
+
+	if !p.consume(' ') {
+		return Point{}, errors.New("missing fields")
+	}
+	p.skipSpaces()
+
+	for {
+		key := p.until(",= ")
+		if key == "" {
+			return Point{}, errors.New("missing field key")
+		}
+		if !p.consume('=') {
+			return Point{}, fmt.Errorf("field %q has no value", key)
+		}
+		value, err := p.fieldValue()
+		if err != nil {
+			return Point{}, fmt.Errorf("field %q: %w", key, err)
+		}
+		point.Fields = append(point.Fields, Field{Key: key, Value: value})
+
+		if !p.consume(',') {
+			break
+		}
+	}
+
+	if p.consume(' ') {
+		p.skipSpaces()
+		if rest := strings.TrimSpace(p.s[p.pos:]); rest != "" {
+			ts, err := strconv.ParseInt(rest, 10, 64)
+			if err != nil {
+				return Point{}, fmt.Errorf("invalid timestamp %q", rest)
+			}
+			point.Timestamp = &ts
+			p.pos = len(p.s)
+		}
+	}
+
+	if p.pos < len(p.s) {
+		return Point{}, fmt.Errorf("unexpected %q after fields", p.s[p.pos:])
+	}
+
+	return point, nil
+}
+
+type parser struct {
+	s   string
+	pos int
+}
+
+func (p *parser) consume(c byte) bool {
+	if p.pos < len(p.s) && p.s[p.pos] == c {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) skipSpaces() {
+	for p.pos < len(p.s) && p.s[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+// until reads up to the first unescaped byte from stops. A backslash escapes
+// any stop byte and itself, other backslashes are kept as is.
+func (p *parser) until(stops string) string {
+	var b strings.Builder
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		if c == '\\' && p.pos+1 < len(p.s) {
+			next := p.s[p.pos+1]
+			if next == '\\' || strings.IndexByte(stops, next) >= 0 {
+				b.WriteByte(next)
+				p.pos += 2
+				continue
+			}
+		}
+		if strings.IndexByte(stops, c) >= 0 {
+			break
+		}
+		b.WriteByte(c)
+		p.pos++
+	}
+	return b.String()
+}
+
+func (p *parser) fieldValue() (any, error) {
+	if p.consume('"') {
+		return p.quoted()
+	}
+
+	raw := p.until(", ")
+	switch raw {
+	case "":
+		return nil, errors.New("missing value")
+	case "t", "T", "true", "True", "TRUE":
+		return true, nil
+	case "f", "F", "false", "False", "FALSE":
+		return false, nil
+	}
+
+	switch raw[len(raw)-1] {
+	case 'i':
+		v, err := strconv.ParseInt(raw[:len(raw)-1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer %q", raw)
+		}
+		return v, nil
+	case 'u':
+		v, err := strconv.ParseUint(raw[:len(raw)-1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid unsigned integer %q", raw)
+		}
+		return v, nil
+	}
+
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+		return nil, fmt.Errorf("invalid float %q", raw)
+	}
+	return v, nil
+}
+
+// quoted reads a string field after its opening quote.
+func (p *parser) quoted() (string, error) {
+	var b strings.Builder
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		if c == '\\' && p.pos+1 < len(p.s) && (p.s[p.pos+1] == '"' || p.s[p.pos+1] == '\\') {
+			b.WriteByte(p.s[p.pos+1])
+			p.pos += 2
+			continue
+		}
+		if c == '"' {
+			p.pos++
+			return b.String(), nil
+		}
+		b.WriteByte(c)
+		p.pos++
+	}
+	return "", errors.New("unterminated string")
+}
@@ -0,0 +1,150 @@
+package lineprotocol
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func int64Ptr(v int64) *int64 { return &v }
+
+func TestParseLine(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		expected Point
+	}{
+		{
+			name: "float field",
+			line: "cpu value=0.64",
+			expected: Point{
+				Measurement: "cpu",
+				Fields:      []Field{{Key: "value", Value: 0.64}},
+			},
+		},
+		{
+			name: "tags, typed fields and timestamp",
+			line: `http,host=a,region=eu requests=10i,bytes=5u,ok=t,path="/a \"b\"",load=1 1700000000000000000`,
+			expected: Point{
+				Measurement: "http",
+				Tags:        map[string]string{"host": "a", "region": "eu"},
+				Fields: []Field{
+					{Key: "requests", Value: int64(10)},
+					{Key: "bytes", Value: uint64(5)},
+					{Key: "ok", Value: true},
+					{Key: "path", Value: `/a "b"`},
+					{Key: "load", Value: 1.0},
+				},
+				Timestamp: int64Ptr(1700000000000000000),
+			},
+		},
+		{
+			name: "escaped separators",
+			line: `disk\ io,mount=/var\,log read\=s=-3i`,
+			expected: Point{
+				Measurement: "disk io",
+				Tags:        map[string]string{"mount": "/var,log"},
+				Fields:      []Field{{Key: "read=s", Value: int64(-3)}},
+			},
+		},
+		{
+			name: "string field with separators",
+			line: `log msg="a, b=c d"`,
+			expected: Point{
+				Measurement: "log",
+				Fields:      []Field{{Key: "msg", Value: "a, b=c d"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			point, err := ParseLine(tt.line)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, point)
+		})
+	}
+}
+
+func TestParseLine_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+	}{
+		{name: "missing measurement", line: ",host=a value=1"},
+		{name: "missing fields", line: "cpu"},
+		{name: "tag without value", line: "cpu,host value=1"},
+		{name: "empty tag value", line: "cpu,host= value=1"},
+		{name: "field without value", line: "cpu value"},
+		{name: "empty field value", line: "cpu value="},
+		{name: "invalid integer", line: "cpu value=1.5i"},
+		{name: "negative unsigned", line: "cpu value=-1u"},
+		{name: "invalid float", line: "cpu value=abc"},
+		{name: "NaN", line: "cpu value=NaN"},
+		{name: "unterminated string", line: `cpu value="abc`},
+		{name: "invalid timestamp", line: "cpu value=1 yesterday"},
+		{name: "trailing garbage", line: "cpu value=1 1 2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseLine(tt.line)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	input := strings.Join([]string{
+		"# comment",
+		"cpu value=1",
+		"",
+		"broken",
+		"mem free=2i 1700000000\r",
+		"cpu value=oops",
+	}, "\n")
+
+	points, lineErrs, err := Parse(strings.NewReader(input))
+	require.NoError(t, err)
+
+	require.Len(t, points, 2)
+	assert.Equal(t, 2, points[0].Line)
+	assert.Equal(t, "cpu", points[0].Measurement)
+	assert.Equal(t, 5, points[1].Line)
+	assert.Equal(t, int64Ptr(1700000000), points[1].Timestamp)
+
+	require.Len(t, lineErrs, 2)
+	assert.Equal(t, 4, lineErrs[0].Line)
+	assert.Equal(t, 6, lineErrs[1].Line)
+	assert.Contains(t, lineErrs[1].Error(), "line 6: ")
+}
+
+func TestParse_LineTooLong(t *testing.T) {
+	input := strings.Join([]string{
+		"cpu value=1",
+		"log msg=\"" + strings.Repeat("x", maxLineSize) + "\"",
+		"mem free=2i",
+		"log msg=\"" + strings.Repeat("y", maxLineSize-len(`log msg=""`)) + "\"",
+	}, "\n")
+
+	points, lineErrs, err := Parse(strings.NewReader(input))
+	require.NoError(t, err)
+
+	require.Len(t, points, 3)
+	assert.Equal(t, 1, points[0].Line)
+	assert.Equal(t, 3, points[1].Line)
+	assert.Equal(t, 4, points[2].Line, "a line of exactly maxLineSize is accepted")
+
+	require.Len(t, lineErrs, 1)
+	assert.Equal(t, 2, lineErrs[0].Line)
+	assert.ErrorIs(t, lineErrs[0], ErrLineTooLong)
+}
+
+func TestParse_ReadError(t *testing.T) {
+	_, _, err := Parse(iotest.ErrReader(errors.New("connection reset")))
+	assert.Error(t, err)
+}
@@ -0,0 +1,26 @@
+package logger
+
+import "go.uber.org/zap"
+
+// Log is the process-wide logger. It discards everything until Initialize is called.
+var Log *zap.SugaredLogger = zap.NewNop().Sugar()
+
+// Initialize replaces Log with a structured JSON logger writing at the given level.
+func Initialize(level string) error {
+	lvl, err := zap.ParseAtomicLevel(level)
+	if err != nil {
+		return err
+	}
+
+	cfg := zap.NewProductionConfig()
+	cfg.Level = lvl
+
+	zl, err := cfg.Build()
+	if err != nil {
+		return err
+	}
+
+	Log = zl.Sugar()
+
+	return nil
+}
@@ -0,0 +1,29 @@
+package logger
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestInitialize(t *testing.T) {
+	original := Log
+	defer func() { Log = original }()
+
+	require.NoError(t, Initialize("debug"))
+	assert.True(t, Log.Desugar().Core().Enabled(zapcore.DebugLevel))
+
+	require.NoError(t, Initialize("warn"))
+	assert.False(t, Log.Desugar().Core().Enabled(zapcore.InfoLevel))
+	assert.True(t, Log.Desugar().Core().Enabled(zapcore.WarnLevel))
+}
+
+func TestInitialize_InvalidLevel(t *testing.T) {
+	original := Log
+	defer func() { Log = original }()
+
+	assert.Error(t, Initialize("loud"))
+	assert.Equal(t, original, Log)
+}
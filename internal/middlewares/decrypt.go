@@ -0,0 +1,49 @@
+package middlewares
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"io"
+	"net/http"
+
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/encryption"
+)
+
+// DecryptMiddleware decrypts request bodies encrypted for the server's public
+// key. Once a key is configured every non-empty request body must be
+// encrypted, anything else is rejected with 400. A nil key disables it.
+func DecryptMiddleware(key *rsa.PrivateKey) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if key == nil {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			r.Body.Close()
+
+			if len(body) > 0 {
+				if r.Header.Get(encryption.Header) != encryption.Scheme {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+
+				body, err = encryption.Decrypt(key, body)
+				if err != nil {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+			}
+
+			r.Header.Del(encryption.Header)
+			r.ContentLength = int64(len(body))
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package middlewares
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/encryption"
+)
+
+func TestDecryptMiddleware(t *testing.T) {
+	key, err := encryption.GenerateKey(2048)
+	require.NoError(t, err)
+
+	body := []byte(`[{"id":"a","type":"gauge","value":1}]`)
+	encrypted, err := encryption.Encrypt(&key.PublicKey, body)
+	require.NoError(t, err)
+
+	echo := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		assert.Empty(t, r.Header.Get(encryption.Header))
+		w.WriteHeader(http.StatusOK)
+		w.Write(data)
+	})
+
+	tests := []struct {
+		name         string
+		body         []byte
+		scheme       string
+		expectedCode int
+		expectedBody []byte
+	}{
+		{
+			name:         "encrypted body",
+			body:         encrypted,
+			scheme:       encryption.Scheme,
+			expectedCode: http.StatusOK,
+			expectedBody: body,
+		},
+		{
+			name:         "plain body",
+			body:         body,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "unknown scheme",
+			body:         encrypted,
+			scheme:       "rot13",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "corrupted body",
+			body:         encrypted[:len(encrypted)-1],
+			scheme:       encryption.Scheme,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "empty body",
+			expectedCode: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewReader(tt.body))
+			if tt.scheme != "" {
+				req.Header.Set(encryption.Header, tt.scheme)
+			}
+			rr := httptest.NewRecorder()
+
+			DecryptMiddleware(key)(echo).ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedCode, rr.Code)
+			if tt.expectedBody != nil {
+				assert.Equal(t, tt.expectedBody, rr.Body.Bytes())
+			}
+		})
+	}
+}
+
+func TestDecryptMiddleware_NilKeyDisablesDecryption(t *testing.T) {
+	handler := DecryptMiddleware(nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		w.Write(data)
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewReader([]byte("{}")))
+	rr := httptest.NewRecorder()
+
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "{}", rr.Body.String())
+}
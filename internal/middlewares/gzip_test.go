@@ -0,0 +1,125 @@
+package middlewares
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func gzipBytes(t *testing.T, data string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	_, err := gz.Write([]byte(data))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+
+	return buf.Bytes()
+}
+
+func gunzipString(t *testing.T, data []byte) string {
+	t.Helper()
+
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	out, err := io.ReadAll(gz)
+	require.NoError(t, err)
+
+	return string(out)
+}
+
+func TestGzipMiddleware_DecompressesRequest(t *testing.T) {
+	var received string
+	handler := GzipMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		received = string(body)
+		assert.Empty(t, r.Header.Get("Content-Encoding"))
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/update/", bytes.NewReader(gzipBytes(t, `{"id":"a"}`)))
+	req.Header.Set("Content-Encoding", "gzip")
+	rr := httptest.NewRecorder()
+
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, `{"id":"a"}`, received)
+}
+
+func TestGzipMiddleware_InvalidCompressedRequest(t *testing.T) {
+	handler := GzipMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("handler must not be called")
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/update/", strings.NewReader("not gzip"))
+	req.Header.Set("Content-Encoding", "gzip")
+	rr := httptest.NewRecorder()
+
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestGzipMiddleware_CompressesResponse(t *testing.T) {
+	tests := []struct {
+		name           string
+		contentType    string
+		acceptEncoding string
+		wantCompressed bool
+	}{
+		{name: "json", contentType: "application/json", acceptEncoding: "gzip", wantCompressed: true},
+		{name: "html", contentType: "text/html; charset=utf-8", acceptEncoding: "br, gzip;q=0.8", wantCompressed: true},
+		{name: "plain text is left alone", contentType: "text/plain", acceptEncoding: "gzip", wantCompressed: false},
+		{name: "client without gzip support", contentType: "application/json", acceptEncoding: "", wantCompressed: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := GzipMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte("payload"))
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			rr := httptest.NewRecorder()
+
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, http.StatusOK, rr.Code)
+			if tt.wantCompressed {
+				assert.Equal(t, "gzip", rr.Header().Get("Content-Encoding"))
+				assert.Equal(t, "payload", gunzipString(t, rr.Body.Bytes()))
+			} else {
+				assert.Empty(t, rr.Header().Get("Content-Encoding"))
+				assert.Equal(t, "payload", rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestGzipMiddleware_ImplicitStatus(t *testing.T) {
+	handler := GzipMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rr := httptest.NewRecorder()
+
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, `{}`, gunzipString(t, rr.Body.Bytes()))
+}
@@ -0,0 +1,82 @@
+package middlewares
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/hash"
+)
+
+// hashResponseWriter buffers the response body so that it can be signed
+// before anything is sent to the client.
+type hashResponseWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *hashResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *hashResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.body.Write(b)
+}
+
+// HashMiddleware verifies the HashSHA256 header of every request except GET
+// and HEAD and signs every response with the shared key. The signature covers
+// the body, or for a bodyless request such as the path API the method and the
+// request URI, see signedPayload. Requests with a missing or mismatching
+// signature are rejected with 400. An empty key disables the check.
+func HashMiddleware(key string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if key == "" {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				body, err := io.ReadAll(r.Body)
+				if err != nil {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				r.Body.Close()
+
+				if !hash.Verify(signedPayload(r.Method, r.URL.RequestURI(), body), key, r.Header.Get(hash.Header)) {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+
+				r.Body = io.NopCloser(bytes.NewReader(body))
+			}
+
+			hw := &hashResponseWriter{ResponseWriter: w}
+			next.ServeHTTP(hw, r)
+
+			if hw.status == 0 {
+				hw.status = http.StatusOK
+			}
+
+			w.Header().Set(hash.Header, hash.Sign(hw.body.Bytes(), key))
+			w.WriteHeader(hw.status)
+			w.Write(hw.body.Bytes())
+		})
+	}
+}
+
+// signedPayload returns what the signature of a request covers: the body, or
+// "METHOD /request/uri" when the body is empty, so that a bodyless update
+// cannot be replayed against another metric.
+func signedPayload(method, requestURI string, body []byte) []byte {
+	if len(body) > 0 {
+		return body
+	}
+	return []byte(method + " " + requestURI)
+}
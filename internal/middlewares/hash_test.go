@@ -0,0 +1,115 @@
+package middlewares
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/hash"
+)
+
+func TestHashMiddleware(t *testing.T) {
+	const key = "secret"
+	body := `[{"id":"a","type":"gauge","value":1}]`
+
+	echo := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(data)
+	})
+
+	tests := []struct {
+		name         string
+		method       string
+		body         string
+		signature    string
+		expectedCode int
+		expectSigned bool
+	}{
+		{
+			name:         "valid signature",
+			method:       http.MethodPost,
+			body:         body,
+			signature:    hash.Sign([]byte(body), key),
+			expectedCode: http.StatusOK,
+			expectSigned: true,
+		},
+		{
+			name:         "signature made with another key",
+			method:       http.MethodPost,
+			body:         body,
+			signature:    hash.Sign([]byte(body), "other"),
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "missing signature",
+			method:       http.MethodPost,
+			body:         body,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "bodyless POST signed over method and URI",
+			method:       http.MethodPost,
+			signature:    hash.Sign([]byte("POST /updates/"), key),
+			expectedCode: http.StatusOK,
+			expectSigned: true,
+		},
+		{
+			name:         "bodyless POST without signature",
+			method:       http.MethodPost,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "bodyless POST signed for another URI",
+			method:       http.MethodPost,
+			signature:    hash.Sign([]byte("POST /update/"), key),
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "GET requests are not verified",
+			method:       http.MethodGet,
+			expectedCode: http.StatusOK,
+			expectSigned: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/updates/", strings.NewReader(tt.body))
+			if tt.signature != "" {
+				req.Header.Set(hash.Header, tt.signature)
+			}
+			rr := httptest.NewRecorder()
+
+			HashMiddleware(key)(echo).ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedCode, rr.Code)
+			if tt.expectSigned {
+				assert.Equal(t, tt.body, rr.Body.String())
+				assert.True(t, hash.Verify(rr.Body.Bytes(), key, rr.Header().Get(hash.Header)))
+				assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestHashMiddleware_EmptyKeyDisablesCheck(t *testing.T) {
+	handler := HashMiddleware("")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/updates/", strings.NewReader("{}"))
+	rr := httptest.NewRecorder()
+
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Empty(t, rr.Header().Get(hash.Header))
+}
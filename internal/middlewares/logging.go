@@ -0,0 +1,51 @@
+package middlewares
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/logger"
+)
+
+// loggingResponseWriter records the status code and the number of bytes written.
+type loggingResponseWriter struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func (w *loggingResponseWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *loggingResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.size += n
+	return n, err
+}
+
+// LoggingMiddleware logs method, URI, status, response size and latency of every request.
+func LoggingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		lw := &loggingResponseWriter{ResponseWriter: w}
+		next.ServeHTTP(lw, r)
+
+		if lw.status == 0 {
+			lw.status = http.StatusOK
+		}
+
+		logger.Log.Infow("request handled",
+			"method", r.Method,
+			"uri", r.RequestURI,
+			"status", lw.status,
+			"size", lw.size,
+			"duration", time.Since(start),
+		)
+	})
+}
@@ -0,0 +1,80 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/logger"
+)
+
+func observeLogs(t *testing.T) *observer.ObservedLogs {
+	t.Helper()
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	original := logger.Log
+	logger.Log = zap.New(core).Sugar()
+	t.Cleanup(func() { logger.Log = original })
+
+	return logs
+}
+
+func TestLoggingMiddleware(t *testing.T) {
+	tests := []struct {
+		name           string
+		handler        http.HandlerFunc
+		expectedStatus int
+		expectedSize   int
+	}{
+		{
+			name: "explicit status and body",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusCreated)
+				w.Write([]byte("hello"))
+			},
+			expectedStatus: http.StatusCreated,
+			expectedSize:   5,
+		},
+		{
+			name: "implicit status with body",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("ok"))
+			},
+			expectedStatus: http.StatusOK,
+			expectedSize:   2,
+		},
+		{
+			name:           "no write at all",
+			handler:        func(w http.ResponseWriter, r *http.Request) {},
+			expectedStatus: http.StatusOK,
+			expectedSize:   0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := observeLogs(t)
+
+			req := httptest.NewRequest(http.MethodPost, "/update/gauge/a/1?x=1", nil)
+			rr := httptest.NewRecorder()
+
+			LoggingMiddleware(tt.handler).ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+
+			require.Equal(t, 1, logs.Len())
+			fields := logs.All()[0].ContextMap()
+			assert.Equal(t, http.MethodPost, fields["method"])
+			assert.Equal(t, "/update/gauge/a/1?x=1", fields["uri"])
+			assert.EqualValues(t, tt.expectedStatus, fields["status"])
+			assert.EqualValues(t, tt.expectedSize, fields["size"])
+			assert.Contains(t, fields, "duration")
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package middlewares
+
+import (
+	"net"
+	"net/http"
+)
+
+// RealIPHeader carries the address of the agent that sent the request.
+const RealIPHeader = "X-Real-IP"
+
+// TrustedSubnetMiddleware rejects with 403 requests whose X-Real-IP header is
+// missing, malformed or outside subnet. A nil subnet disables the check.
+func TrustedSubnetMiddleware(subnet *net.IPNet) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if subnet == nil {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := net.ParseIP(r.Header.Get(RealIPHeader))
+			if ip == nil || !subnet.Contains(ip) {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package middlewares
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrustedSubnetMiddleware(t *testing.T) {
+	_, subnet, err := net.ParseCIDR("192.168.1.0/24")
+	require.NoError(t, err)
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name         string
+		realIP       string
+		expectedCode int
+	}{
+		{name: "inside subnet", realIP: "192.168.1.17", expectedCode: http.StatusOK},
+		{name: "outside subnet", realIP: "10.0.0.1", expectedCode: http.StatusForbidden},
+		{name: "missing header", expectedCode: http.StatusForbidden},
+		{name: "malformed header", realIP: "192.168.1", expectedCode: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/updates/", nil)
+			if tt.realIP != "" {
+				req.Header.Set(RealIPHeader, tt.realIP)
+			}
+			rr := httptest.NewRecorder()
+
+			TrustedSubnetMiddleware(subnet)(ok).ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedCode, rr.Code)
+		})
+	}
+}
+
+func TestTrustedSubnetMiddleware_NilSubnetDisablesCheck(t *testing.T) {
+	handler := TrustedSubnetMiddleware(nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/updates/", nil))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
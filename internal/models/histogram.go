@@ -0,0 +1,141 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// DefaultHistogramBuckets are the bucket upper bounds of a histogram created
+// from a single observation, the same as the Prometheus client defaults.
+var DefaultHistogramBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// ErrHistogramBuckets is returned when histograms with different bucket
+// bounds are merged.
+var ErrHistogramBuckets = errors.New("histogram bucket bounds differ")
+
+// HistogramValue holds the buckets of a histogram. Bounds are the ascending
+// upper bounds of every bucket but the last one, which is unbounded. Counts
+// are per bucket, not cumulative: Counts[i] is the number of observations v
+// with Bounds[i-1] < v <= Bounds[i], so there is one count more than bounds.
+type HistogramValue struct {
+	Bounds []float64 `json:"bounds"`
+	Counts []uint64  `json:"counts"`
+	Sum    float64   `json:"sum"`
+	Count  uint64    `json:"count"`
+}
+
+// NewHistogramValue creates an empty histogram with the given bucket bounds.
+func NewHistogramValue(bounds []float64) (*HistogramValue, error) {
+	if err := ValidateHistogramBounds(bounds); err != nil {
+		return nil, err
+	}
+	return &HistogramValue{
+		Bounds: append([]float64(nil), bounds...),
+		Counts: make([]uint64, len(bounds)+1),
+	}, nil
+}
+
+// ValidateHistogramBounds checks that bounds are finite and strictly ascending.
+func ValidateHistogramBounds(bounds []float64) error {
+	for i, bound := range bounds {
+		if math.IsNaN(bound) || math.IsInf(bound, 0) {
+			return fmt.Errorf("histogram bound %v is not finite", bound)
+		}
+		if i > 0 && bound <= bounds[i-1] {
+			return fmt.Errorf("histogram bounds are not ascending at %v", bound)
+		}
+	}
+	return nil
+}
+
+// Validate checks a histogram received from a client.
+func (h *HistogramValue) Validate() error {
+	if err := ValidateHistogramBounds(h.Bounds); err != nil {
+		return err
+	}
+	if len(h.Counts) != len(h.Bounds)+1 {
+		return fmt.Errorf("histogram has %d counts for %d bounds, want %d", len(h.Counts), len(h.Bounds), len(h.Bounds)+1)
+	}
+	var total uint64
+	for _, c := range h.Counts {
+		total += c
+	}
+	if total != h.Count {
+		return fmt.Errorf("histogram count %d does not match the bucket total %d", h.Count, total)
+	}
+	if math.IsNaN(h.Sum) || math.IsInf(h.Sum, 0) {
+		return errors.New("histogram sum is not finite")
+	}
+	return nil
+}
+
+// Clone returns a deep copy, nil stays nil.
+func (h *HistogramValue) Clone() *HistogramValue {
+	if h == nil {
+		return nil
+	}
+	return &HistogramValue{
+		Bounds: append([]float64(nil), h.Bounds...),
+		Counts: append([]uint64(nil), h.Counts...),
+		Sum:    h.Sum,
+		Count:  h.Count,
+	}
+}
+
+// Observe adds a single observation.
+func (h *HistogramValue) Observe(v float64) {
+	i := 0
+	for i < len(h.Bounds) && v > h.Bounds[i] {
+		i++
+	}
+	h.Counts[i]++
+	h.Sum += v
+	h.Count++
+}
+
+// Merge adds the buckets of other, which must have the same bounds.
+func (h *HistogramValue) Merge(other *HistogramValue) error {
+	if len(h.Bounds) != len(other.Bounds) {
+		return ErrHistogramBuckets
+	}
+	for i := range h.Bounds {
+		if h.Bounds[i] != other.Bounds[i] {
+			return ErrHistogramBuckets
+		}
+	}
+	for i := range h.Counts {
+		h.Counts[i] += other.Counts[i]
+	}
+	h.Sum += other.Sum
+	h.Count += other.Count
+	return nil
+}
+
+// Cumulative returns the count of observations less than or equal to each
+// bound, the way Prometheus exposes buckets. The unbounded bucket is Count.
+func (h *HistogramValue) Cumulative() []uint64 {
+	cumulative := make([]uint64, len(h.Bounds))
+	var total uint64
+	for i := range h.Bounds {
+		total += h.Counts[i]
+		cumulative[i] = total
+	}
+	return cumulative
+}
+
+// String formats the histogram as count, sum and cumulative buckets, e.g.
+// "count=3 sum=0.75 buckets=[0.1:1 0.5:2 +Inf:3]".
+func (h *HistogramValue) String() string {
+	var b strings.Builder
+	b.WriteString("count=" + strconv.FormatUint(h.Count, 10))
+	b.WriteString(" sum=" + strconv.FormatFloat(h.Sum, 'f', -1, 64))
+	b.WriteString(" buckets=[")
+	for i, c := range h.Cumulative() {
+		b.WriteString(strconv.FormatFloat(h.Bounds[i], 'f', -1, 64) + ":" + strconv.FormatUint(c, 10) + " ")
+	}
+	b.WriteString("+Inf:" + strconv.FormatUint(h.Count, 10) + "]")
+	return b.String()
+}
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistogramValue_Observe(t *testing.T) {
+	h, err := NewHistogramValue([]float64{0.1, 0.5})
+	require.NoError(t, err)
+
+	for _, v := range []float64{0.05, 0.1, 0.3, 2} {
+		h.Observe(v)
+	}
+
+	assert.Equal(t, []uint64{2, 1, 1}, h.Counts)
+	assert.Equal(t, uint64(4), h.Count)
+	assert.InDelta(t, 2.45, h.Sum, 1e-9)
+	assert.Equal(t, []uint64{2, 3}, h.Cumulative())
+	assert.Equal(t, "count=4 sum=2.45 buckets=[0.1:2 0.5:3 +Inf:4]", h.String())
+	require.NoError(t, h.Validate())
+}
+
+func TestHistogramValue_Merge(t *testing.T) {
+	h := &HistogramValue{Bounds: []float64{1}, Counts: []uint64{1, 2}, Sum: 5, Count: 3}
+	clone := h.Clone()
+
+	require.NoError(t, h.Merge(&HistogramValue{Bounds: []float64{1}, Counts: []uint64{1, 0}, Sum: 0.5, Count: 1}))
+	assert.Equal(t, &HistogramValue{Bounds: []float64{1}, Counts: []uint64{2, 2}, Sum: 5.5, Count: 4}, h)
+	assert.Equal(t, []uint64{1, 2}, clone.Counts, "a clone does not share counts")
+
+	err := h.Merge(&HistogramValue{Bounds: []float64{2}, Counts: []uint64{1, 0}, Count: 1})
+	assert.ErrorIs(t, err, ErrHistogramBuckets)
+	err = h.Merge(&HistogramValue{Counts: []uint64{1}, Count: 1})
+	assert.ErrorIs(t, err, ErrHistogramBuckets)
+}
+
+func TestHistogramValue_Validate(t *testing.T) {
+	tests := []struct {
+		name      string
+		histogram HistogramValue
+	}{
+		{name: "descending bounds", histogram: HistogramValue{Bounds: []float64{1, 0.5}, Counts: []uint64{0, 0, 0}}},
+		{name: "duplicate bounds", histogram: HistogramValue{Bounds: []float64{1, 1}, Counts: []uint64{0, 0, 0}}},
+		{name: "infinite bound", histogram: HistogramValue{Bounds: []float64{math.Inf(1)}, Counts: []uint64{0, 0}}},
+		{name: "missing overflow bucket", histogram: HistogramValue{Bounds: []float64{1}, Counts: []uint64{1}, Count: 1}},
+		{name: "count does not match", histogram: HistogramValue{Bounds: []float64{1}, Counts: []uint64{1, 1}, Count: 3}},
+		{name: "sum is not finite", histogram: HistogramValue{Counts: []uint64{1}, Sum: math.NaN(), Count: 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Error(t, tt.histogram.Validate())
+		})
+	}
+
+	_, err := NewHistogramValue([]float64{2, 1})
+	assert.Error(t, err)
+}
@@ -0,0 +1,278 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Labels is a canonical label set: name="value" pairs sorted by name and
+// joined with commas, e.g. `method="GET",route="/a"`. Being a plain string it
+// is comparable and can be part of a map key. The zero value is the empty
+// set. Use NewLabels or ParseLabels to build one.
+type Labels string
+
+// NewLabels canonicalises a label map. Labels with an empty value are
+// dropped, as they are indistinguishable from absent ones.
+func NewLabels(m map[string]string) (Labels, error) {
+	names := make([]string, 0, len(m))
+	for name, value := range m {
+		if err := validateLabelName(name); err != nil {
+			return "", err
+		}
+		if value != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name)
+		b.WriteByte('=')
+		b.WriteString(quoteLabelValue(m[name]))
+	}
+
+	return Labels(b.String()), nil
+}
+
+// ParseLabels parses a label set written as name="value" pairs separated by
+// commas, optionally enclosed in braces, in any order.
+func ParseLabels(s string) (Labels, error) {
+	pairs, err := parseSelector(s)
+	if err != nil {
+		return "", err
+	}
+
+	m := make(map[string]string, len(pairs))
+	for _, p := range pairs {
+		if p.op != "=" {
+			return "", fmt.Errorf("label %q: unexpected operator %q", p.name, p.op)
+		}
+		if _, dup := m[p.name]; dup {
+			return "", fmt.Errorf("duplicate label %q", p.name)
+		}
+		m[p.name] = p.value
+	}
+
+	return NewLabels(m)
+}
+
+// Map returns the labels as a map, nil for the empty set.
+func (l Labels) Map() map[string]string {
+	if l == "" {
+		return nil
+	}
+	// A Labels value is only built from valid input, parsing cannot fail.
+	pairs, _ := parseSelector(string(l))
+	m := make(map[string]string, len(pairs))
+	for _, p := range pairs {
+		m[p.name] = p.value
+	}
+	return m
+}
+
+// Get returns the value of a label, empty when it is not set.
+func (l Labels) Get(name string) string {
+	return l.Map()[name]
+}
+
+// MarshalJSON encodes the labels as a JSON object.
+func (l Labels) MarshalJSON() ([]byte, error) {
+	m := l.Map()
+	if m == nil {
+		m = map[string]string{}
+	}
+	return json.Marshal(m)
+}
+
+// UnmarshalJSON decodes a JSON object of string values.
+func (l *Labels) UnmarshalJSON(data []byte) error {
+	var m map[string]string
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+	labels, err := NewLabels(m)
+	if err != nil {
+		return err
+	}
+	*l = labels
+	return nil
+}
+
+var labelNameRe = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+func validateLabelName(name string) error {
+	if !labelNameRe.MatchString(name) {
+		return fmt.Errorf("invalid label name %q", name)
+	}
+	if strings.HasPrefix(name, "__") {
+		return fmt.Errorf("label name %q is reserved", name)
+	}
+	return nil
+}
+
+// Matcher selects metrics by a label. Ops are =, !=, =~ and !~; regular
+// expressions are anchored. An absent label matches as the empty value.
+type Matcher struct {
+	Name  string
+	Op    string
+	Value string
+
+	re *regexp.Regexp
+}
+
+// ParseMatchers parses a selector such as {route=~"/api/.*",method!="POST"}.
+// The braces are optional.
+func ParseMatchers(s string) ([]Matcher, error) {
+	pairs, err := parseSelector(s)
+	if err != nil {
+		return nil, err
+	}
+
+	matchers := make([]Matcher, 0, len(pairs))
+	for _, p := range pairs {
+		m := Matcher{Name: p.name, Op: p.op, Value: p.value}
+		if p.op == "=~" || p.op == "!~" {
+			m.re, err = regexp.Compile("^(?:" + p.value + ")$")
+			if err != nil {
+				return nil, fmt.Errorf("label %q: %w", p.name, err)
+			}
+		}
+		matchers = append(matchers, m)
+	}
+
+	return matchers, nil
+}
+
+// Matches reports whether the labels satisfy the matcher.
+func (m Matcher) Matches(labels Labels) bool {
+	value := labels.Get(m.Name)
+	switch m.Op {
+	case "=":
+		return value == m.Value
+	case "!=":
+		return value != m.Value
+	case "=~":
+		return m.re.MatchString(value)
+	case "!~":
+		return !m.re.MatchString(value)
+	default:
+		return false
+	}
+}
+
+// MatchAll reports whether the labels satisfy every matcher.
+func MatchAll(labels Labels, matchers []Matcher) bool {
+	for _, m := range matchers {
+		if !m.Matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+type selectorPair struct {
+	name, op, value string
+}
+
+// parseSelector parses name op "value" items separated by commas, optionally
+// enclosed in braces.
+func parseSelector(s string) ([]selectorPair, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "{") {
+		if !strings.HasSuffix(s, "}") {
+			return nil, errors.New("unterminated label set")
+		}
+		s = strings.TrimSpace(s[1 : len(s)-1])
+	}
+
+	var pairs []selectorPair
+	for s != "" {
+		end := strings.IndexAny(s, "=!")
+		if end <= 0 {
+			return nil, fmt.Errorf("invalid label set near %q", s)
+		}
+		name := strings.TrimSpace(s[:end])
+		if err := validateLabelName(name); err != nil {
+			return nil, err
+		}
+		s = s[end:]
+
+		var op string
+		for _, candidate := range []string{"=~", "!=", "!~", "="} {
+			if strings.HasPrefix(s, candidate) {
+				op = candidate
+				break
+			}
+		}
+		if op == "" {
+			return nil, fmt.Errorf("label %q: invalid operator", name)
+		}
+		s = strings.TrimSpace(s[len(op):])
+
+		value, n, err := unquoteLabelValue(s)
+		if err != nil {
+			return nil, fmt.Errorf("label %q: %w", name, err)
+		}
+		pairs = append(pairs, selectorPair{name: name, op: op, value: value})
+
+		s = strings.TrimSpace(s[n:])
+		if s == "" {
+			break
+		}
+		if s[0] != ',' {
+			return nil, fmt.Errorf("label %q: expected comma", name)
+		}
+		s = strings.TrimSpace(s[1:])
+	}
+
+	return pairs, nil
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// quoteLabelValue quotes a value the way the Prometheus exposition format
+// does: only backslash, double quote and newline are escaped.
+func quoteLabelValue(value string) string {
+	return `"` + labelValueEscaper.Replace(value) + `"`
+}
+
+// unquoteLabelValue reads a quoted value at the start of s and returns it
+// together with the number of bytes consumed.
+func unquoteLabelValue(s string) (string, int, error) {
+	if !strings.HasPrefix(s, `"`) {
+		return "", 0, errors.New("value must be a double quoted string")
+	}
+
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"':
+			return b.String(), i + 1, nil
+		case '\\':
+			if i+1 == len(s) {
+				return "", 0, errors.New("unterminated value")
+			}
+			i++
+			switch s[i] {
+			case '\\', '"':
+				b.WriteByte(s[i])
+			case 'n':
+				b.WriteByte('\n')
+			default:
+				return "", 0, fmt.Errorf("invalid escape \\%c", s[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	return "", 0, errors.New("unterminated value")
+}
@@ -0,0 +1,121 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewLabels(t *testing.T) {
+	tests := []struct {
+		name     string
+		labels   map[string]string
+		expected Labels
+		wantErr  bool
+	}{
+		{name: "empty", labels: nil, expected: ""},
+		{
+			name:     "sorted by name",
+			labels:   map[string]string{"route": "/a", "method": "GET"},
+			expected: `method="GET",route="/a"`,
+		},
+		{
+			name:     "empty values are dropped",
+			labels:   map[string]string{"route": "/a", "method": ""},
+			expected: `route="/a"`,
+		},
+		{
+			name:     "values are escaped",
+			labels:   map[string]string{"msg": "a \"b\"\\c\nd"},
+			expected: `msg="a \"b\"\\c\nd"`,
+		},
+		{name: "invalid name", labels: map[string]string{"1st": "x"}, wantErr: true},
+		{name: "reserved name", labels: map[string]string{"__name__": "x"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			labels, err := NewLabels(tt.labels)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, labels)
+		})
+	}
+}
+
+func TestParseLabels(t *testing.T) {
+	labels, err := ParseLabels(`{ route = "/a" , method="GET", msg="x\"y\n" }`)
+	require.NoError(t, err)
+	assert.Equal(t, Labels(`method="GET",msg="x\"y\n",route="/a"`), labels)
+	assert.Equal(t, map[string]string{"method": "GET", "msg": "x\"y\n", "route": "/a"}, labels.Map())
+	assert.Equal(t, "GET", labels.Get("method"))
+	assert.Equal(t, "", labels.Get("missing"))
+
+	for _, s := range []string{
+		`{route="/a"`,
+		`route="/a",route="/b"`,
+		`route!="/a"`,
+		`route=/a`,
+		`route="/a`,
+		`route="\t"`,
+		`route="/a" method="GET"`,
+	} {
+		_, err := ParseLabels(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestLabelsJSON(t *testing.T) {
+	metric := Metrics{ID: "requests", MType: Counter}
+	require.NoError(t, json.Unmarshal([]byte(`{"id":"requests","type":"counter","labels":{"route":"/a","method":"GET"}}`), &metric))
+	assert.Equal(t, Labels(`method="GET",route="/a"`), metric.Labels)
+
+	data, err := json.Marshal(metric)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"id":"requests","type":"counter","labels":{"method":"GET","route":"/a"}}`, string(data))
+
+	data, err = json.Marshal(Metrics{ID: "plain", MType: Counter})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"id":"plain","type":"counter"}`, string(data))
+
+	assert.Error(t, json.Unmarshal([]byte(`{"labels":{"a-b":"x"}}`), &metric))
+}
+
+func TestMatchers(t *testing.T) {
+	labels := Labels(`method="GET",route="/api/users"`)
+
+	tests := []struct {
+		selector string
+		expected bool
+	}{
+		{selector: `{method="GET"}`, expected: true},
+		{selector: `method="POST"`, expected: false},
+		{selector: `{method!="POST"}`, expected: true},
+		{selector: `{route=~"/api/.*"}`, expected: true},
+		{selector: `{route=~"/api"}`, expected: false},
+		{selector: `{route!~"/api/.*"}`, expected: false},
+		{selector: `{host=""}`, expected: true},
+		{selector: `{host!=""}`, expected: false},
+		{selector: `{method="GET",route=~".*users"}`, expected: true},
+		{selector: `{method="GET",route=~".*orders"}`, expected: false},
+		{selector: ``, expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.selector, func(t *testing.T) {
+			matchers, err := ParseMatchers(tt.selector)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, MatchAll(labels, matchers))
+		})
+	}
+
+	for _, s := range []string{`{route=~"("}`, `{route~"/a"}`, `{="x"}`} {
+		_, err := ParseMatchers(s)
+		assert.Error(t, err, s)
+	}
+}
@@ -1,19 +1,160 @@
 package models
 
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/ddsketch"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/hyperloglog"
+)
+
 const (
-	Counter = "counter"
-	Gauge   = "gauge"
+	Counter   = "counter"
+	Gauge     = "gauge"
+	Histogram = "histogram"
+	Summary   = "summary"
+	Set       = "set"
 )
 
+// HistogramBucketLabel holds the upper bound of a histogram bucket in the
+// Prometheus exposition, histograms cannot use it as a label.
+const HistogramBucketLabel = "le"
+
+// SummaryQuantileLabel holds the quantile of a summary sample in the
+// Prometheus exposition, summaries cannot use it as a label.
+const SummaryQuantileLabel = "quantile"
+
+// ErrMetricIDRequired is returned by Validate for a metric without an id.
+var ErrMetricIDRequired = errors.New("metric id is required")
+
 type MetricID struct {
-	ID    string `json:"id"`
-	MType string `json:"type"`
+	ID     string `json:"id"`
+	MType  string `json:"type"`
+	Labels Labels `json:"labels,omitempty"`
 }
 
+// Metrics is a metric value or update. Counters carry Delta and gauges carry
+// Value. A histogram update carries either a single observation in Value or
+// pre-aggregated buckets in Histogram, a stored histogram always the latter.
+// Summaries do the same with a quantile sketch in Summary. A set update
+// carries members, a HyperLogLog sketch in Set or both, a stored set only
+// the sketch.
 type Metrics struct {
-	ID    string   `json:"id"`
-	MType string   `json:"type"`
-	Delta *int64   `json:"delta,omitempty"`
-	Value *float64 `json:"value,omitempty"`
-	Hash  string   `json:"hash,omitempty"`
+	ID        string              `json:"id"`
+	MType     string              `json:"type"`
+	Labels    Labels              `json:"labels,omitempty"`
+	Delta     *int64              `json:"delta,omitempty"`
+	Value     *float64            `json:"value,omitempty"`
+	Histogram *HistogramValue     `json:"histogram,omitempty"`
+	Summary   *ddsketch.Sketch    `json:"summary,omitempty"`
+	Members   []string            `json:"members,omitempty"`
+	Set       *hyperloglog.Sketch `json:"set,omitempty"`
+}
+
+// Key returns the identity of the metric.
+func (m *Metrics) Key() MetricID {
+	return MetricID{ID: m.ID, MType: m.MType, Labels: m.Labels}
+}
+
+// Validate checks that an update received from a client carries exactly the
+// fields of its type and can be passed to the updater.
+func (m *Metrics) Validate() error {
+	if m == nil {
+		return errors.New("metric is required")
+	}
+	if m.ID == "" {
+		return ErrMetricIDRequired
+	}
+
+	switch m.MType {
+	case Counter:
+		if m.Delta == nil || m.Value != nil || m.Histogram != nil || m.Summary != nil || m.hasSet() {
+			return fmt.Errorf("counter %q needs a delta only", m.ID)
+		}
+	case Gauge:
+		if m.Value == nil || m.Delta != nil || m.Histogram != nil || m.Summary != nil || m.hasSet() {
+			return fmt.Errorf("gauge %q needs a value only", m.ID)
+		}
+		// Snapshots and JSON responses cannot encode NaN and infinities.
+		if !isFinite(*m.Value) {
+			return fmt.Errorf("gauge %q: value is not finite", m.ID)
+		}
+	case Histogram:
+		// Either a single observation or a set of buckets.
+		if m.Delta != nil || m.Summary != nil || m.hasSet() || (m.Value == nil) == (m.Histogram == nil) {
+			return fmt.Errorf("histogram %q needs either a value or buckets", m.ID)
+		}
+		if m.Labels.Get(HistogramBucketLabel) != "" {
+			return fmt.Errorf("histogram %q: label %q is reserved", m.ID, HistogramBucketLabel)
+		}
+		if m.Value != nil && !isFinite(*m.Value) {
+			return fmt.Errorf("histogram %q: observation is not finite", m.ID)
+		}
+		if m.Histogram != nil {
+			if err := m.Histogram.Validate(); err != nil {
+				return fmt.Errorf("histogram %q: %w", m.ID, err)
+			}
+		}
+	case Summary:
+		// Either a single observation or a sketch.
+		if m.Delta != nil || m.Histogram != nil || m.hasSet() || (m.Value == nil) == (m.Summary == nil) {
+			return fmt.Errorf("summary %q needs either a value or a sketch", m.ID)
+		}
+		if m.Labels.Get(SummaryQuantileLabel) != "" {
+			return fmt.Errorf("summary %q: label %q is reserved", m.ID, SummaryQuantileLabel)
+		}
+		if m.Value != nil && !isFinite(*m.Value) {
+			return fmt.Errorf("summary %q: observation is not finite", m.ID)
+		}
+		if m.Summary != nil {
+			if err := m.Summary.Validate(); err != nil {
+				return fmt.Errorf("summary %q: %w", m.ID, err)
+			}
+		}
+	case Set:
+		// Members, a sketch or both. The sketch is checked when decoded.
+		if m.Delta != nil || m.Value != nil || m.Histogram != nil || m.Summary != nil || !m.hasSet() {
+			return fmt.Errorf("set %q needs members or a sketch", m.ID)
+		}
+	default:
+		return fmt.Errorf("unknown metric type %q", m.MType)
+	}
+
+	return nil
+}
+
+// hasSet reports whether a metric carries set members or a set sketch.
+func (m *Metrics) hasSet() bool {
+	return len(m.Members) > 0 || m.Set != nil
+}
+
+func isFinite(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
+
+// IsMergeConflict reports whether an update failed because it cannot be
+// merged into the stored value: histograms with other bucket bounds,
+// summaries with another accuracy and sets with another precision. Such an
+// update is the client's fault, unlike a storage failure.
+func IsMergeConflict(err error) bool {
+	return errors.Is(err, ErrHistogramBuckets) ||
+		errors.Is(err, ddsketch.ErrAccuracyMismatch) ||
+		errors.Is(err, hyperloglog.ErrPrecisionMismatch)
+}
+
+// PartialUpdateError is returned when only part of a batch was applied before
+// a failure. Pending holds the metrics that were not acknowledged and have to
+// be sent again, the others must not be.
+type PartialUpdateError struct {
+	Pending []*Metrics
+	Err     error
+}
+
+func (e *PartialUpdateError) Error() string {
+	return fmt.Sprintf("%d metrics not acknowledged: %v", len(e.Pending), e.Err)
+}
+
+func (e *PartialUpdateError) Unwrap() error {
+	return e.Err
 }
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/ddsketch"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/hyperloglog"
+)
+
+func TestMetrics_Validate(t *testing.T) {
+	delta := int64(1)
+	value := 1.5
+	nan := math.NaN()
+	sketch, _ := ddsketch.New(ddsketch.DefaultRelativeAccuracy)
+	set, _ := hyperloglog.New(hyperloglog.DefaultPrecision)
+
+	tests := []struct {
+		name    string
+		metric  *Metrics
+		wantErr bool
+	}{
+		{name: "counter", metric: &Metrics{ID: "a", MType: Counter, Delta: &delta}},
+		{name: "gauge", metric: &Metrics{ID: "a", MType: Gauge, Value: &value}},
+		{name: "gauge with le label", metric: &Metrics{ID: "a", MType: Gauge, Labels: `le="x"`, Value: &value}},
+		{name: "histogram observation", metric: &Metrics{ID: "a", MType: Histogram, Value: &value}},
+		{name: "histogram buckets", metric: &Metrics{ID: "a", MType: Histogram, Histogram: &HistogramValue{Bounds: []float64{1}, Counts: []uint64{0, 1}, Sum: 2, Count: 1}}},
+		{name: "summary observation", metric: &Metrics{ID: "a", MType: Summary, Value: &value}},
+		{name: "summary sketch", metric: &Metrics{ID: "a", MType: Summary, Summary: sketch}},
+		{name: "set members", metric: &Metrics{ID: "a", MType: Set, Members: []string{"x"}}},
+		{name: "set sketch and members", metric: &Metrics{ID: "a", MType: Set, Members: []string{"x"}, Set: set}},
+		{name: "nil metric", wantErr: true},
+		{name: "unknown type", metric: &Metrics{ID: "a", MType: "other", Value: &value}, wantErr: true},
+		{name: "counter without delta", metric: &Metrics{ID: "a", MType: Counter, Value: &value}, wantErr: true},
+		{name: "gauge with delta", metric: &Metrics{ID: "a", MType: Gauge, Value: &value, Delta: &delta}, wantErr: true},
+		{name: "gauge not finite", metric: &Metrics{ID: "a", MType: Gauge, Value: &nan}, wantErr: true},
+		{name: "gauge with members", metric: &Metrics{ID: "a", MType: Gauge, Value: &value, Members: []string{"x"}}, wantErr: true},
+		{name: "histogram with both", metric: &Metrics{ID: "a", MType: Histogram, Value: &value, Histogram: &HistogramValue{Counts: []uint64{0}}}, wantErr: true},
+		{name: "histogram with le label", metric: &Metrics{ID: "a", MType: Histogram, Labels: `le="x"`, Value: &value}, wantErr: true},
+		{name: "histogram not finite", metric: &Metrics{ID: "a", MType: Histogram, Value: &nan}, wantErr: true},
+		{name: "histogram invalid buckets", metric: &Metrics{ID: "a", MType: Histogram, Histogram: &HistogramValue{Bounds: []float64{1}, Counts: []uint64{1}}}, wantErr: true},
+		{name: "summary without value", metric: &Metrics{ID: "a", MType: Summary}, wantErr: true},
+		{name: "summary with quantile label", metric: &Metrics{ID: "a", MType: Summary, Labels: `quantile="x"`, Value: &value}, wantErr: true},
+		{name: "summary not finite", metric: &Metrics{ID: "a", MType: Summary, Value: &nan}, wantErr: true},
+		{name: "summary invalid sketch", metric: &Metrics{ID: "a", MType: Summary, Summary: &ddsketch.Sketch{}}, wantErr: true},
+		{name: "set without members", metric: &Metrics{ID: "a", MType: Set}, wantErr: true},
+		{name: "set with value", metric: &Metrics{ID: "a", MType: Set, Value: &value, Members: []string{"x"}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.metric.Validate()
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+
+	assert.ErrorIs(t, (&Metrics{MType: Gauge, Value: &value}).Validate(), ErrMetricIDRequired)
+}
+
+func TestIsMergeConflict(t *testing.T) {
+	assert.True(t, IsMergeConflict(fmt.Errorf("histogram %q: %w", "a", ErrHistogramBuckets)))
+	assert.True(t, IsMergeConflict(ddsketch.ErrAccuracyMismatch))
+	assert.True(t, IsMergeConflict(hyperloglog.ErrPrecisionMismatch))
+	assert.False(t, IsMergeConflict(errors.New("db down")))
+	assert.False(t, IsMergeConflict(nil))
+}
@@ -0,0 +1,104 @@
+package pb
+
+import (
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/ddsketch"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/hyperloglog"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/models"
+)
+
+// FromModel converts a metric into its protobuf message, nil stays nil.
+func FromModel(metric *models.Metrics) *Metric {
+	if metric == nil {
+		return nil
+	}
+	m := &Metric{
+		Id:        metric.ID,
+		Type:      metric.MType,
+		Delta:     metric.Delta,
+		Value:     metric.Value,
+		Labels:    metric.Labels.Map(),
+		Histogram: histogramFromModel(metric.Histogram),
+		Summary:   sketchFromModel(metric.Summary),
+		Members:   metric.Members,
+	}
+	if metric.Set != nil {
+		m.Set, _ = metric.Set.MarshalBinary()
+		m.Cardinality = metric.Set.Count()
+	}
+	return m
+}
+
+// ToModel converts the message into a metric, nil stays nil. It fails when a
+// label name or the set sketch is invalid.
+func (x *Metric) ToModel() (*models.Metrics, error) {
+	if x == nil {
+		return nil, nil
+	}
+	labels, err := models.NewLabels(x.Labels)
+	if err != nil {
+		return nil, err
+	}
+	metric := &models.Metrics{
+		ID:        x.Id,
+		MType:     x.Type,
+		Labels:    labels,
+		Delta:     x.Delta,
+		Value:     x.Value,
+		Histogram: x.GetHistogram().toModel(),
+		Summary:   x.GetSummary().toModel(),
+		Members:   x.Members,
+	}
+	if len(x.Set) > 0 {
+		metric.Set = &hyperloglog.Sketch{}
+		if err := metric.Set.UnmarshalBinary(x.Set); err != nil {
+			return nil, err
+		}
+	}
+	return metric, nil
+}
+
+func histogramFromModel(h *models.HistogramValue) *Histogram {
+	if h == nil {
+		return nil
+	}
+	return &Histogram{Bounds: h.Bounds, Counts: h.Counts, Sum: h.Sum, Count: h.Count}
+}
+
+func (x *Histogram) toModel() *models.HistogramValue {
+	if x == nil {
+		return nil
+	}
+	return &models.HistogramValue{Bounds: x.Bounds, Counts: x.Counts, Sum: x.Sum, Count: x.Count}
+}
+
+func sketchFromModel(s *ddsketch.Sketch) *Sketch {
+	if s == nil {
+		return nil
+	}
+	return &Sketch{
+		RelativeAccuracy: s.RelativeAccuracy,
+		Positive:         s.Positive,
+		Negative:         s.Negative,
+		Zero:             s.Zero,
+		Count:            s.Count,
+		Sum:              s.Sum,
+		Min:              s.Min,
+		Max:              s.Max,
+	}
+}
+
+func (x *Sketch) toModel() *ddsketch.Sketch {
+	if x == nil {
+		return nil
+	}
+	return &ddsketch.Sketch{
+		RelativeAccuracy: x.RelativeAccuracy,
+		Positive:         x.Positive,
+		Negative:         x.Negative,
+		Zero:             x.Zero,
+		Count:            x.Count,
+		Sum:              x.Sum,
+		Min:              x.Min,
+		Max:              x.Max,
+	}
+}
@@ -0,0 +1,67 @@
+package pb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/ddsketch"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/hyperloglog"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/models"
+)
+
+func TestConvert(t *testing.T) {
+	delta := int64(5)
+	value := 1.5
+	set, _ := hyperloglog.New(hyperloglog.DefaultPrecision)
+	set.Add("alice")
+
+	tests := []struct {
+		name   string
+		metric *models.Metrics
+	}{
+		{name: "counter", metric: &models.Metrics{ID: "PollCount", MType: models.Counter, Delta: &delta}},
+		{name: "gauge", metric: &models.Metrics{ID: "Alloc", MType: models.Gauge, Value: &value}},
+		{name: "labels", metric: &models.Metrics{ID: "requests", MType: models.Counter, Labels: `method="GET",route="/a"`, Delta: &delta}},
+		{name: "histogram", metric: &models.Metrics{
+			ID: "latency", MType: models.Histogram,
+			Histogram: &models.HistogramValue{Bounds: []float64{0.1, 1}, Counts: []uint64{1, 0, 2}, Sum: 7, Count: 3},
+		}},
+		{name: "summary", metric: &models.Metrics{
+			ID: "latency", MType: models.Summary,
+			Summary: &ddsketch.Sketch{
+				RelativeAccuracy: 0.01, Positive: map[int32]uint64{35: 2}, Negative: map[int32]uint64{-4: 1},
+				Zero: 1, Count: 4, Sum: 3.98, Min: -0.02, Max: 2,
+			},
+		}},
+		{name: "set", metric: &models.Metrics{ID: "users", MType: models.Set, Members: []string{"bob"}, Set: set}},
+		{name: "nil"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metric, err := FromModel(tt.metric).ToModel()
+			require.NoError(t, err)
+			assert.Equal(t, tt.metric, metric)
+		})
+	}
+}
+
+func TestFromModel_SetCardinality(t *testing.T) {
+	set, _ := hyperloglog.New(hyperloglog.DefaultPrecision)
+	set.Add("alice")
+	set.Add("bob")
+
+	assert.Equal(t, uint64(2), FromModel(&models.Metrics{ID: "users", MType: models.Set, Set: set}).GetCardinality())
+}
+
+func TestToModel_InvalidSet(t *testing.T) {
+	_, err := (&Metric{Id: "users", Type: models.Set, Set: []byte{4, 0}}).ToModel()
+	assert.Error(t, err)
+}
+
+func TestToModel_InvalidLabel(t *testing.T) {
+	_, err := (&Metric{Id: "requests", Type: models.Counter, Labels: map[string]string{"1st": "x"}}).ToModel()
+	assert.Error(t, err)
+}
@@ -0,0 +1,13 @@
+package pb
+
+import "google.golang.org/protobuf/proto"
+
+// SignedPayload returns the part of the message covered by Hash.
+func (x *StreamUpdatesRequest) SignedPayload() proto.Message {
+	return x.GetMetric()
+}
+
+// SetHash stores the signature of SignedPayload in the message.
+func (x *StreamUpdatesRequest) SetHash(hash string) {
+	x.Hash = hash
+}
@@ -0,0 +1,288 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/ddsketch"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/hyperloglog"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/models"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/retry"
+)
+
+// DBRepositoryOpt is a functional option for configuring the database repositories
+type DBRepositoryOpt func(*dbRepository)
+
+// WithDBRetryDelays sets the pauses between attempts for retriable storage errors
+func WithDBRetryDelays(delays ...time.Duration) DBRepositoryOpt {
+	return func(r *dbRepository) {
+		r.retryDelays = delays
+	}
+}
+
+// dbRepository holds what the database repositories share.
+type dbRepository struct {
+	db          *sql.DB
+	retryDelays []time.Duration
+}
+
+func newDBRepository(db *sql.DB, opts ...DBRepositoryOpt) dbRepository {
+	r := dbRepository{
+		db:          db,
+		retryDelays: retry.DefaultDelays,
+	}
+	for _, opt := range opts {
+		opt(&r)
+	}
+	return r
+}
+
+func (r *dbRepository) withRetry(ctx context.Context, fn func(ctx context.Context) error) error {
+	return retry.Do(ctx, r.retryDelays, isRetriableDBError, fn)
+}
+
+// isRetriableDBError reports whether a storage error is transient: a lost or
+// refused connection, a server that is starting up or a transaction that lost
+// a serialization conflict. Constraint and syntax errors are never retried.
+func isRetriableDBError(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch {
+		case strings.HasPrefix(pgErr.Code, "08"), // connection_exception
+			pgErr.Code == "40001", // serialization_failure
+			pgErr.Code == "40P01", // deadlock_detected
+			pgErr.Code == "57P03": // cannot_connect_now
+			return true
+		default:
+			return false
+		}
+	}
+
+	if errors.Is(err, driver.ErrBadConn) {
+		return true
+	}
+
+	var connectErr *pgconn.ConnectError
+	if errors.As(err, &connectErr) {
+		return true
+	}
+
+	return retry.IsRetriable(err)
+}
+
+type MetricsDBSaveRepository struct {
+	dbRepository
+}
+
+func NewMetricsDBSaveRepository(db *sql.DB, opts ...DBRepositoryOpt) *MetricsDBSaveRepository {
+	return &MetricsDBSaveRepository{dbRepository: newDBRepository(db, opts...)}
+}
+
+const metricsUpsertQuery = `
+INSERT INTO metrics (id, type, labels, delta, value, histogram, summary, set_sketch)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+ON CONFLICT (id, type, labels) DO UPDATE
+SET delta = EXCLUDED.delta, value = EXCLUDED.value,
+	histogram = EXCLUDED.histogram, summary = EXCLUDED.summary, set_sketch = EXCLUDED.set_sketch`
+
+// Save upserts all metrics inside a single transaction, retrying the whole
+// transaction on transient storage errors.
+func (r *MetricsDBSaveRepository) Save(
+	ctx context.Context,
+	metrics ...models.Metrics,
+) error {
+	return r.withRetry(ctx, func(ctx context.Context) error {
+		return r.save(ctx, metrics)
+	})
+}
+
+func (r *MetricsDBSaveRepository) save(
+	ctx context.Context,
+	metrics []models.Metrics,
+) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, metricsUpsertQuery)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, metric := range metrics {
+		histogram, err := jsonbArg(metric.Histogram)
+		if err != nil {
+			return err
+		}
+		summary, err := jsonbArg(metric.Summary)
+		if err != nil {
+			return err
+		}
+		set, err := setArg(metric.Set)
+		if err != nil {
+			return err
+		}
+		_, err = stmt.ExecContext(ctx,
+			metric.ID, metric.MType, string(metric.Labels), metric.Delta, metric.Value, histogram, summary, set)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+type MetricsDBGetRepository struct {
+	dbRepository
+}
+
+func NewMetricsDBGetRepository(db *sql.DB, opts ...DBRepositoryOpt) *MetricsDBGetRepository {
+	return &MetricsDBGetRepository{dbRepository: newDBRepository(db, opts...)}
+}
+
+const metricsGetQuery = `
+SELECT id, type, labels, delta, value, histogram, summary, set_sketch
+FROM metrics
+WHERE id = $1 AND type = $2 AND labels = $3`
+
+func (r *MetricsDBGetRepository) Get(
+	ctx context.Context,
+	metricID models.MetricID,
+) (*models.Metrics, error) {
+	var metric *models.Metrics
+
+	err := r.withRetry(ctx, func(ctx context.Context) error {
+		row := r.db.QueryRowContext(ctx, metricsGetQuery, metricID.ID, metricID.MType, string(metricID.Labels))
+		var err error
+		metric, err = scanMetric(row)
+		return err
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return metric, nil
+}
+
+type MetricsDBListRepository struct {
+	dbRepository
+}
+
+func NewMetricsDBListRepository(db *sql.DB, opts ...DBRepositoryOpt) *MetricsDBListRepository {
+	return &MetricsDBListRepository{dbRepository: newDBRepository(db, opts...)}
+}
+
+const metricsListQuery = `
+SELECT id, type, labels, delta, value, histogram, summary, set_sketch
+FROM metrics
+ORDER BY type, id, labels`
+
+// List returns every stored metric sorted by type, name and labels.
+func (r *MetricsDBListRepository) List(
+	ctx context.Context,
+) ([]*models.Metrics, error) {
+	var metrics []*models.Metrics
+
+	err := r.withRetry(ctx, func(ctx context.Context) error {
+		var err error
+		metrics, err = r.list(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return metrics, nil
+}
+
+func (r *MetricsDBListRepository) list(
+	ctx context.Context,
+) ([]*models.Metrics, error) {
+	rows, err := r.db.QueryContext(ctx, metricsListQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	metrics := make([]*models.Metrics, 0)
+	for rows.Next() {
+		metric, err := scanMetric(rows)
+		if err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, metric)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return metrics, nil
+}
+
+// jsonbArg encodes a histogram or summary for its JSONB column, NULL for
+// other types.
+func jsonbArg[T any](v *T) (any, error) {
+	if v == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// setArg encodes a set sketch for the BYTEA column, NULL for other types.
+func setArg(set *hyperloglog.Sketch) (any, error) {
+	if set == nil {
+		return nil, nil
+	}
+	return set.MarshalBinary()
+}
+
+// scanMetric reads a row selected with the columns of metricsListQuery.
+func scanMetric(row interface{ Scan(dest ...any) error }) (*models.Metrics, error) {
+	var (
+		metric    models.Metrics
+		histogram []byte
+		summary   []byte
+		set       []byte
+	)
+	err := row.Scan(&metric.ID, &metric.MType, &metric.Labels, &metric.Delta, &metric.Value, &histogram, &summary, &set)
+	if err != nil {
+		return nil, err
+	}
+	if histogram != nil {
+		metric.Histogram = &models.HistogramValue{}
+		if err := json.Unmarshal(histogram, metric.Histogram); err != nil {
+			return nil, err
+		}
+	}
+	if summary != nil {
+		metric.Summary = &ddsketch.Sketch{}
+		if err := json.Unmarshal(summary, metric.Summary); err != nil {
+			return nil, err
+		}
+	}
+	if set != nil {
+		metric.Set = &hyperloglog.Sketch{}
+		if err := metric.Set.UnmarshalBinary(set); err != nil {
+			return nil, err
+		}
+	}
+	return &metric, nil
+}
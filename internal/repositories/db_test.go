@@ -0,0 +1,236 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/ddsketch"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/hyperloglog"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/models"
+)
+
+func newMockDB(t *testing.T) (*sql.DB, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db, mock
+}
+
+func TestMetricsDBSaveRepository_Save(t *testing.T) {
+	db, mock := newMockDB(t)
+	repo := NewMetricsDBSaveRepository(db)
+
+	delta := int64(5)
+	value := 1.5
+	set, _ := hyperloglog.New(4)
+	set.Add("alice")
+	setBytes, _ := set.MarshalBinary()
+
+	mock.ExpectBegin()
+	prep := mock.ExpectPrepare(regexp.QuoteMeta("INSERT INTO metrics"))
+	prep.ExpectExec().
+		WithArgs("c", models.Counter, "", &delta, nil, nil, nil, nil).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	prep.ExpectExec().
+		WithArgs("g", models.Gauge, "", nil, &value, nil, nil, nil).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	prep.ExpectExec().
+		WithArgs("h", models.Histogram, `route="/a"`, nil, nil, `{"bounds":[1],"counts":[1,0],"sum":0.5,"count":1}`, nil, nil).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	prep.ExpectExec().
+		WithArgs("s", models.Summary, "", nil, nil, nil, `{"relative_accuracy":0.01,"zero":1,"count":1,"sum":0,"min":0,"max":0}`, nil).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	prep.ExpectExec().
+		WithArgs("u", models.Set, "", nil, nil, nil, nil, setBytes).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err := repo.Save(
+		context.Background(),
+		models.Metrics{ID: "c", MType: models.Counter, Delta: &delta},
+		models.Metrics{ID: "g", MType: models.Gauge, Value: &value},
+		models.Metrics{
+			ID: "h", MType: models.Histogram, Labels: `route="/a"`,
+			Histogram: &models.HistogramValue{Bounds: []float64{1}, Counts: []uint64{1, 0}, Sum: 0.5, Count: 1},
+		},
+		models.Metrics{ID: "s", MType: models.Summary, Summary: &ddsketch.Sketch{RelativeAccuracy: 0.01, Zero: 1, Count: 1}},
+		models.Metrics{ID: "u", MType: models.Set, Set: set},
+	)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMetricsDBSaveRepository_Save_RollbackOnError(t *testing.T) {
+	db, mock := newMockDB(t)
+	repo := NewMetricsDBSaveRepository(db)
+
+	value := 1.5
+
+	mock.ExpectBegin()
+	prep := mock.ExpectPrepare(regexp.QuoteMeta("INSERT INTO metrics"))
+	prep.ExpectExec().
+		WithArgs("g", models.Gauge, "", nil, &value, nil, nil, nil).
+		WillReturnError(errors.New("exec failed"))
+	mock.ExpectRollback()
+
+	err := repo.Save(context.Background(), models.Metrics{ID: "g", MType: models.Gauge, Value: &value})
+	require.Error(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMetricsDBSaveRepository_Save_BeginError(t *testing.T) {
+	db, mock := newMockDB(t)
+	repo := NewMetricsDBSaveRepository(db)
+
+	mock.ExpectBegin().WillReturnError(errors.New("connection lost"))
+
+	err := repo.Save(context.Background(), models.Metrics{ID: "g", MType: models.Gauge})
+	require.Error(t, err)
+}
+
+func TestMetricsDBGetRepository_Get(t *testing.T) {
+	db, mock := newMockDB(t)
+	repo := NewMetricsDBGetRepository(db)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, type, labels, delta, value, histogram, summary, set_sketch")).
+		WithArgs("c", models.Counter, "").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "type", "labels", "delta", "value", "histogram", "summary", "set_sketch"}).
+			AddRow("c", models.Counter, "", int64(7), nil, nil, nil, nil))
+
+	got, err := repo.Get(context.Background(), models.MetricID{ID: "c", MType: models.Counter})
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, "c", got.ID)
+	assert.Equal(t, int64(7), *got.Delta)
+	assert.Nil(t, got.Value)
+}
+
+func TestMetricsDBGetRepository_Get_NotFound(t *testing.T) {
+	db, mock := newMockDB(t)
+	repo := NewMetricsDBGetRepository(db)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, type, labels, delta, value, histogram, summary, set_sketch")).
+		WithArgs("missing", models.Gauge, "").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "type", "labels", "delta", "value", "histogram", "summary", "set_sketch"}))
+
+	got, err := repo.Get(context.Background(), models.MetricID{ID: "missing", MType: models.Gauge})
+	require.NoError(t, err)
+	assert.Nil(t, got)
+}
+
+func TestMetricsDBGetRepository_Get_Error(t *testing.T) {
+	db, mock := newMockDB(t)
+	repo := NewMetricsDBGetRepository(db)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, type, labels, delta, value, histogram, summary, set_sketch")).
+		WillReturnError(errors.New("query failed"))
+
+	_, err := repo.Get(context.Background(), models.MetricID{ID: "c", MType: models.Counter})
+	require.Error(t, err)
+}
+
+func TestMetricsDBListRepository_List(t *testing.T) {
+	db, mock := newMockDB(t)
+	repo := NewMetricsDBListRepository(db)
+
+	set, _ := hyperloglog.New(4)
+	set.Add("alice")
+	setBytes, _ := set.MarshalBinary()
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, type, labels, delta, value, histogram, summary, set_sketch")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "type", "labels", "delta", "value", "histogram", "summary", "set_sketch"}).
+			AddRow("c", models.Counter, "", int64(1), nil, nil, nil, nil).
+			AddRow("g", models.Gauge, "", nil, 2.5, nil, nil, nil).
+			AddRow("h", models.Histogram, "", nil, nil, []byte(`{"bounds":[1],"counts":[1,2],"sum":4,"count":3}`), nil, nil).
+			AddRow("s", models.Summary, "", nil, nil, nil, []byte(`{"relative_accuracy":0.01,"positive":{"35":2},"count":2,"sum":4,"min":2,"max":2}`), nil).
+			AddRow("u", models.Set, "", nil, nil, nil, nil, setBytes))
+
+	got, err := repo.List(context.Background())
+	require.NoError(t, err)
+	require.Len(t, got, 5)
+	assert.Equal(t, "c", got[0].ID)
+	assert.Equal(t, 2.5, *got[1].Value)
+	assert.Equal(t, &models.HistogramValue{Bounds: []float64{1}, Counts: []uint64{1, 2}, Sum: 4, Count: 3}, got[2].Histogram)
+	assert.Equal(t, &ddsketch.Sketch{
+		RelativeAccuracy: 0.01, Positive: map[int32]uint64{35: 2}, Count: 2, Sum: 4, Min: 2, Max: 2,
+	}, got[3].Summary)
+	assert.Equal(t, set, got[4].Set)
+}
+
+func TestMetricsDBListRepository_List_Error(t *testing.T) {
+	db, mock := newMockDB(t)
+	repo := NewMetricsDBListRepository(db)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, type, labels, delta, value, histogram, summary, set_sketch")).
+		WillReturnError(errors.New("query failed"))
+
+	_, err := repo.List(context.Background())
+	require.Error(t, err)
+}
+
+func TestMetricsDBSaveRepository_Save_RetriesTransientErrors(t *testing.T) {
+	db, mock := newMockDB(t)
+	repo := NewMetricsDBSaveRepository(db, WithDBRetryDelays(time.Millisecond, time.Millisecond))
+
+	value := 1.5
+
+	mock.ExpectBegin().WillReturnError(&pgconn.PgError{Code: "08006"})
+	mock.ExpectBegin()
+	prep := mock.ExpectPrepare(regexp.QuoteMeta("INSERT INTO metrics"))
+	prep.ExpectExec().
+		WithArgs("g", models.Gauge, "", nil, &value, nil, nil, nil).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err := repo.Save(context.Background(), models.Metrics{ID: "g", MType: models.Gauge, Value: &value})
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMetricsDBGetRepository_Get_RetriesTransientErrors(t *testing.T) {
+	db, mock := newMockDB(t)
+	repo := NewMetricsDBGetRepository(db, WithDBRetryDelays(time.Millisecond))
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, type, labels, delta, value, histogram, summary, set_sketch")).
+		WillReturnError(&pgconn.PgError{Code: "57P03"})
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, type, labels, delta, value, histogram, summary, set_sketch")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "type", "labels", "delta", "value", "histogram", "summary", "set_sketch"}).
+			AddRow("c", models.Counter, "", int64(7), nil, nil, nil, nil))
+
+	got, err := repo.Get(context.Background(), models.MetricID{ID: "c", MType: models.Counter})
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), *got.Delta)
+}
+
+func TestIsRetriableDBError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "connection failure", err: &pgconn.PgError{Code: "08001"}, want: true},
+		{name: "serialization failure", err: &pgconn.PgError{Code: "40001"}, want: true},
+		{name: "server starting up", err: &pgconn.PgError{Code: "57P03"}, want: true},
+		{name: "unique violation", err: &pgconn.PgError{Code: "23505"}, want: false},
+		{name: "undefined table", err: &pgconn.PgError{Code: "42P01"}, want: false},
+		{name: "bad connection", err: driver.ErrBadConn, want: true},
+		{name: "no rows", err: sql.ErrNoRows, want: false},
+		{name: "plain error", err: errors.New("boom"), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, isRetriableDBError(tt.err))
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/configs/memory"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/models"
+)
+
+// MetricsFileRepository snapshots the in-memory storage to a JSON file.
+type MetricsFileRepository struct {
+	storage *memory.Memory[models.MetricID, models.Metrics]
+	path    string
+}
+
+func NewMetricsFileRepository(
+	storage *memory.Memory[models.MetricID, models.Metrics],
+	path string,
+) *MetricsFileRepository {
+	return &MetricsFileRepository{storage: storage, path: path}
+}
+
+// Dump writes the current content of the storage to the snapshot file.
+func (r *MetricsFileRepository) Dump(ctx context.Context) error {
+	r.storage.Mu.RLock()
+	metrics := snapshotMetrics(r.storage.Data)
+	r.storage.Mu.RUnlock()
+
+	return writeSnapshot(r.path, metrics)
+}
+
+// Restore loads the snapshot file into the storage. A missing file is not an error.
+func (r *MetricsFileRepository) Restore(ctx context.Context) error {
+	data, err := os.ReadFile(r.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var metrics []models.Metrics
+	if err := json.Unmarshal(data, &metrics); err != nil {
+		return err
+	}
+
+	r.storage.Mu.Lock()
+	defer r.storage.Mu.Unlock()
+
+	for _, metric := range metrics {
+		r.storage.Data[metric.Key()] = metric
+	}
+
+	return nil
+}
+
+// MetricsFileSaveRepository saves metrics to the storage and synchronously
+// persists the resulting snapshot. The snapshot is written before the storage
+// is modified, so a failed write leaves both untouched.
+type MetricsFileSaveRepository struct {
+	storage *memory.Memory[models.MetricID, models.Metrics]
+	path    string
+}
+
+func NewMetricsFileSaveRepository(
+	storage *memory.Memory[models.MetricID, models.Metrics],
+	path string,
+) *MetricsFileSaveRepository {
+	return &MetricsFileSaveRepository{storage: storage, path: path}
+}
+
+func (r *MetricsFileSaveRepository) Save(
+	ctx context.Context,
+	metrics ...models.Metrics,
+) error {
+	r.storage.Mu.Lock()
+	defer r.storage.Mu.Unlock()
+
+	next := make(map[models.MetricID]models.Metrics, len(r.storage.Data)+len(metrics))
+	for id, metric := range r.storage.Data {
+		next[id] = metric
+	}
+	for _, metric := range metrics {
+		next[metric.Key()] = metric
+	}
+
+	if err := writeSnapshot(r.path, snapshotMetrics(next)); err != nil {
+		return err
+	}
+
+	for _, metric := range metrics {
+		r.storage.Data[metric.Key()] = metric
+	}
+
+	return nil
+}
+
+func snapshotMetrics(data map[models.MetricID]models.Metrics) []models.Metrics {
+	metrics := make([]models.Metrics, 0, len(data))
+	for _, metric := range data {
+		metrics = append(metrics, metric)
+	}
+
+	sort.Slice(metrics, func(i, j int) bool {
+		if metrics[i].MType != metrics[j].MType {
+			return metrics[i].MType < metrics[j].MType
+		}
+		if metrics[i].ID != metrics[j].ID {
+			return metrics[i].ID < metrics[j].ID
+		}
+		return metrics[i].Labels < metrics[j].Labels
+	})
+
+	return metrics
+}
+
+// writeSnapshot writes metrics to a temporary file next to path and renames it
+// over path, so readers never observe a partially written snapshot.
+func writeSnapshot(path string, metrics []models.Metrics) error {
+	data, err := json.MarshalIndent(metrics, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
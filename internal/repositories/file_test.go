@@ -0,0 +1,89 @@
+package repositories
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/configs/memory"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/hyperloglog"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/models"
+)
+
+func TestMetricsFileRepository_DumpAndRestore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.json")
+	ctx := context.Background()
+
+	delta := int64(3)
+	value := 2.5
+	set, _ := hyperloglog.New(hyperloglog.DefaultPrecision)
+	set.Add("alice")
+
+	src := memory.NewMemory[models.MetricID, models.Metrics]()
+	src.Data[models.MetricID{ID: "c", MType: models.Counter}] = models.Metrics{ID: "c", MType: models.Counter, Delta: &delta}
+	src.Data[models.MetricID{ID: "g", MType: models.Gauge}] = models.Metrics{ID: "g", MType: models.Gauge, Value: &value}
+	src.Data[models.MetricID{ID: "g", MType: models.Gauge, Labels: `host="a"`}] = models.Metrics{ID: "g", MType: models.Gauge, Labels: `host="a"`, Value: &value}
+	src.Data[models.MetricID{ID: "u", MType: models.Set}] = models.Metrics{ID: "u", MType: models.Set, Set: set}
+
+	require.NoError(t, NewMetricsFileRepository(src, path).Dump(ctx))
+
+	dst := memory.NewMemory[models.MetricID, models.Metrics]()
+	require.NoError(t, NewMetricsFileRepository(dst, path).Restore(ctx))
+
+	assert.Equal(t, src.Data, dst.Data)
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "temporary snapshot file must be renamed away")
+}
+
+func TestMetricsFileRepository_Restore_MissingFile(t *testing.T) {
+	mem := memory.NewMemory[models.MetricID, models.Metrics]()
+	repo := NewMetricsFileRepository(mem, filepath.Join(t.TempDir(), "missing.json"))
+
+	require.NoError(t, repo.Restore(context.Background()))
+	assert.Empty(t, mem.Data)
+}
+
+func TestMetricsFileRepository_Restore_Corrupted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.json")
+	require.NoError(t, os.WriteFile(path, []byte("{not json"), 0o644))
+
+	repo := NewMetricsFileRepository(memory.NewMemory[models.MetricID, models.Metrics](), path)
+
+	assert.Error(t, repo.Restore(context.Background()))
+}
+
+func TestMetricsFileSaveRepository_Save(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.json")
+	ctx := context.Background()
+
+	mem := memory.NewMemory[models.MetricID, models.Metrics]()
+	repo := NewMetricsFileSaveRepository(mem, path)
+
+	value := 1.0
+	require.NoError(t, repo.Save(ctx, models.Metrics{ID: "g", MType: models.Gauge, Value: &value}))
+
+	assert.Len(t, mem.Data, 1)
+
+	restored := memory.NewMemory[models.MetricID, models.Metrics]()
+	require.NoError(t, NewMetricsFileRepository(restored, path).Restore(ctx))
+	assert.Equal(t, mem.Data, restored.Data)
+}
+
+func TestMetricsFileSaveRepository_Save_WriteError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing-dir", "metrics.json")
+
+	mem := memory.NewMemory[models.MetricID, models.Metrics]()
+	repo := NewMetricsFileSaveRepository(mem, path)
+
+	value := 1.0
+	err := repo.Save(context.Background(), models.Metrics{ID: "g", MType: models.Gauge, Value: &value})
+
+	assert.Error(t, err)
+	assert.Empty(t, mem.Data, "storage must stay untouched when the snapshot cannot be written")
+}
@@ -2,6 +2,7 @@ package repositories
 
 import (
 	"context"
+	"sort"
 
 	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/configs/memory"
 	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/models"
@@ -19,12 +20,14 @@ func NewMetricsMemorySaveRepository(
 
 func (r *MetricsMemorySaveRepository) Save(
 	ctx context.Context,
-	metric models.Metrics,
+	metrics ...models.Metrics,
 ) error {
 	r.storage.Mu.Lock()
 	defer r.storage.Mu.Unlock()
 
-	r.storage.Data[models.MetricID{ID: metric.ID, MType: metric.MType}] = metric
+	for _, metric := range metrics {
+		r.storage.Data[metric.Key()] = metric
+	}
 
 	return nil
 }
@@ -46,10 +49,46 @@ func (r *MetricsMemoryGetRepository) Get(
 	r.storage.Mu.RLock()
 	defer r.storage.Mu.RUnlock()
 
-	metric, found := r.storage.Data[models.MetricID{ID: metricID.ID, MType: metricID.MType}]
+	metric, found := r.storage.Data[metricID]
 	if !found {
 		return nil, nil
 	}
 
 	return &metric, nil
 }
+
+type MetricsMemoryListRepository struct {
+	storage *memory.Memory[models.MetricID, models.Metrics]
+}
+
+func NewMetricsMemoryListRepository(
+	storage *memory.Memory[models.MetricID, models.Metrics],
+) *MetricsMemoryListRepository {
+	return &MetricsMemoryListRepository{storage: storage}
+}
+
+// List returns every stored metric sorted by type, name and labels.
+func (r *MetricsMemoryListRepository) List(
+	ctx context.Context,
+) ([]*models.Metrics, error) {
+	r.storage.Mu.RLock()
+	defer r.storage.Mu.RUnlock()
+
+	metrics := make([]*models.Metrics, 0, len(r.storage.Data))
+	for _, metric := range r.storage.Data {
+		m := metric
+		metrics = append(metrics, &m)
+	}
+
+	sort.Slice(metrics, func(i, j int) bool {
+		if metrics[i].MType != metrics[j].MType {
+			return metrics[i].MType < metrics[j].MType
+		}
+		if metrics[i].ID != metrics[j].ID {
+			return metrics[i].ID < metrics[j].ID
+		}
+		return metrics[i].Labels < metrics[j].Labels
+	})
+
+	return metrics, nil
+}
@@ -97,3 +97,69 @@ func TestMetricsMemoryGetRepository_Get_NotFound(t *testing.T) {
 	require.NoError(t, err)
 	assert.Nil(t, got)
 }
+
+func TestMetricsMemoryListRepository_List(t *testing.T) {
+	mem := memory.NewMemory[models.MetricID, models.Metrics]()
+
+	metrics := []models.Metrics{
+		{ID: "b", MType: models.Gauge},
+		{ID: "z", MType: models.Counter},
+		{ID: "a", MType: models.Gauge},
+		{ID: "c", MType: models.Counter},
+	}
+	for _, m := range metrics {
+		mem.Data[models.MetricID{ID: m.ID, MType: m.MType}] = m
+	}
+
+	repo := NewMetricsMemoryListRepository(mem)
+
+	got, err := repo.List(context.Background())
+	require.NoError(t, err)
+
+	require.Len(t, got, 4)
+	assert.Equal(t, models.Metrics{ID: "c", MType: models.Counter}, *got[0])
+	assert.Equal(t, models.Metrics{ID: "z", MType: models.Counter}, *got[1])
+	assert.Equal(t, models.Metrics{ID: "a", MType: models.Gauge}, *got[2])
+	assert.Equal(t, models.Metrics{ID: "b", MType: models.Gauge}, *got[3])
+}
+
+func TestMetricsMemoryRepositories_Labels(t *testing.T) {
+	mem := memory.NewMemory[models.MetricID, models.Metrics]()
+	ctx := context.Background()
+
+	plain := models.Metrics{ID: "requests", MType: models.Counter}
+	routeB := models.Metrics{ID: "requests", MType: models.Counter, Labels: `route="/b"`}
+	routeA := models.Metrics{ID: "requests", MType: models.Counter, Labels: `route="/a"`}
+	require.NoError(t, NewMetricsMemorySaveRepository(mem).Save(ctx, plain, routeB, routeA))
+
+	got, err := NewMetricsMemoryGetRepository(mem).Get(ctx, routeA.Key())
+	require.NoError(t, err)
+	assert.Equal(t, &routeA, got)
+
+	list, err := NewMetricsMemoryListRepository(mem).List(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []*models.Metrics{&plain, &routeA, &routeB}, list)
+}
+
+func TestMetricsMemoryListRepository_List_Empty(t *testing.T) {
+	mem := memory.NewMemory[models.MetricID, models.Metrics]()
+	repo := NewMetricsMemoryListRepository(mem)
+
+	got, err := repo.List(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, got)
+}
+
+func TestMetricsMemorySaveRepository_Save_Batch(t *testing.T) {
+	mem := memory.NewMemory[models.MetricID, models.Metrics]()
+	repo := NewMetricsMemorySaveRepository(mem)
+
+	err := repo.Save(
+		context.Background(),
+		models.Metrics{ID: "metric1", MType: models.Gauge},
+		models.Metrics{ID: "metric2", MType: models.Counter},
+	)
+	require.NoError(t, err)
+
+	assert.Len(t, mem.Data, 2)
+}
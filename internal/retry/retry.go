@@ -0,0 +1,103 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/logger"
+)
+
+// DefaultDelays are the pauses between attempts used unless configured otherwise.
+var DefaultDelays = []time.Duration{1 * time.Second, 3 * time.Second, 5 * time.Second}
+
+// Classifier reports whether an error is worth retrying.
+type Classifier func(err error) bool
+
+// Do calls fn and, while it fails with an error accepted by isRetriable,
+// calls it again after each of delays in turn. The last error is returned
+// once the delays are exhausted or ctx is done.
+func Do(
+	ctx context.Context,
+	delays []time.Duration,
+	isRetriable Classifier,
+	fn func(ctx context.Context) error,
+) error {
+	err := fn(ctx)
+
+	for attempt, delay := range delays {
+		if err == nil || !isRetriable(err) {
+			return err
+		}
+
+		logger.Log.Warnw("retrying after retriable error",
+			"attempt", attempt+1,
+			"delay", delay,
+			"error", err,
+		)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return errors.Join(err, ctx.Err())
+		case <-timer.C:
+		}
+
+		err = fn(ctx)
+	}
+
+	return err
+}
+
+// retriableError marks an error as retriable regardless of its type.
+type retriableError struct {
+	err error
+}
+
+func (e *retriableError) Error() string {
+	return e.err.Error()
+}
+
+func (e *retriableError) Unwrap() error {
+	return e.err
+}
+
+// MarkRetriable wraps err so that IsRetriable reports true for it.
+func MarkRetriable(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &retriableError{err: err}
+}
+
+// IsRetriable reports whether err was marked retriable or is a transient
+// network failure such as a refused or reset connection or a timeout.
+func IsRetriable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var marked *retriableError
+	if errors.As(err, &marked) {
+		return true
+	}
+
+	if errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
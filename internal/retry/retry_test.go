@@ -0,0 +1,101 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDo(t *testing.T) {
+	errTransient := errors.New("transient")
+	errPermanent := errors.New("permanent")
+
+	isRetriable := func(err error) bool { return errors.Is(err, errTransient) }
+	delays := []time.Duration{time.Millisecond, time.Millisecond, time.Millisecond}
+
+	tests := []struct {
+		name          string
+		results       []error
+		expectedErr   error
+		expectedCalls int
+	}{
+		{name: "success on first attempt", results: []error{nil}, expectedCalls: 1},
+		{name: "success after retries", results: []error{errTransient, errTransient, nil}, expectedCalls: 3},
+		{name: "permanent error is not retried", results: []error{errPermanent}, expectedErr: errPermanent, expectedCalls: 1},
+		{
+			name:          "delays exhausted",
+			results:       []error{errTransient, errTransient, errTransient, errTransient},
+			expectedErr:   errTransient,
+			expectedCalls: 4,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := Do(context.Background(), delays, isRetriable, func(ctx context.Context) error {
+				err := tt.results[calls]
+				calls++
+				return err
+			})
+
+			assert.Equal(t, tt.expectedCalls, calls)
+			if tt.expectedErr == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.expectedErr)
+			}
+		})
+	}
+}
+
+func TestDo_StopsWhenContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	calls := 0
+	err := Do(ctx, []time.Duration{time.Hour}, func(error) bool { return true }, func(ctx context.Context) error {
+		calls++
+		return errors.New("transient")
+	})
+
+	assert.Equal(t, 1, calls)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestIsRetriable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "nil", err: nil, want: false},
+		{name: "plain error", err: errors.New("bad request"), want: false},
+		{name: "marked", err: fmt.Errorf("wrapped: %w", MarkRetriable(errors.New("503"))), want: true},
+		{name: "connection refused", err: &net.OpError{Op: "read", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}, want: true},
+		{name: "connection reset", err: fmt.Errorf("post: %w", syscall.ECONNRESET), want: true},
+		{name: "timeout", err: timeoutError{}, want: true},
+		{name: "dial failure", err: &net.OpError{Op: "dial", Err: errors.New("no route to host")}, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsRetriable(tt.err))
+		})
+	}
+
+	assert.Nil(t, MarkRetriable(nil))
+}
@@ -2,8 +2,13 @@ package services
 
 import (
 	"context"
+	"fmt"
 	"sort"
+	"sync"
 
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/ddsketch"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/hyperloglog"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/logger"
 	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/models"
 )
 
@@ -11,17 +16,26 @@ type Getter interface {
 	Get(ctx context.Context, metricID models.MetricID) (*models.Metrics, error)
 }
 
+// Saver persists metrics. Implementations must treat a single call as a
+// transaction: either every passed metric is stored or none of them is.
 type Saver interface {
-	Save(ctx context.Context, metric models.Metrics) error
+	Save(ctx context.Context, metrics ...models.Metrics) error
 }
 
 type MetricUpdateService struct {
-	getter Getter
-	saver  Saver
+	// mu serialises Update: the merge reads through the Getter and writes
+	// through the Saver, so concurrent batches would overwrite each other.
+	mu sync.Mutex
+
+	getter           Getter
+	saver            Saver
+	histogramBuckets []float64
 }
 
 func NewMetricUpdateService(opts ...MetricUpdateOpt) *MetricUpdateService {
-	svc := &MetricUpdateService{}
+	svc := &MetricUpdateService{
+		histogramBuckets: models.DefaultHistogramBuckets,
+	}
 	for _, opt := range opts {
 		opt(svc)
 	}
@@ -42,10 +56,30 @@ func WithMetricUpdateSaver(saver Saver) MetricUpdateOpt {
 	}
 }
 
+// WithMetricUpdateHistogramBuckets sets the bucket bounds of histograms created by an observation
+func WithMetricUpdateHistogramBuckets(bounds ...float64) MetricUpdateOpt {
+	return func(svc *MetricUpdateService) {
+		svc.histogramBuckets = bounds
+	}
+}
+
+// Update applies the metrics as a single batch. Counter deltas are added to the
+// stored value and to earlier deltas for the same metric within the batch,
+// histogram and summary observations, buckets and sketches and set members
+// are merged the same way. The resulting values are saved in one Saver call so
+// that the batch is either applied completely or not at all. Merging
+// histograms with different bucket bounds fails with models.ErrHistogramBuckets,
+// summaries with a different sketch accuracy with ddsketch.ErrAccuracyMismatch
+// and sets with a different precision with hyperloglog.ErrPrecisionMismatch.
+// Batches are applied one at a time, the storage must not be updated by
+// another service instance.
 func (svc *MetricUpdateService) Update(
 	ctx context.Context,
 	metrics []*models.Metrics,
 ) ([]*models.Metrics, error) {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+
 	updated := make(map[models.MetricID]models.Metrics)
 
 	for _, metric := range metrics {
@@ -53,23 +87,59 @@ func (svc *MetricUpdateService) Update(
 			continue
 		}
 
+		metricID := metric.Key()
+
 		switch metric.MType {
 		case models.Counter:
-			current, err := svc.getter.Get(ctx, models.MetricID{ID: metric.ID, MType: metric.MType})
+			current, err := svc.current(ctx, updated, metricID)
 			if err != nil {
 				return nil, err
 			}
-			if current != nil && current.Delta != nil && metric.Delta != nil {
+			if current.Delta != nil && metric.Delta != nil {
 				*metric.Delta += *current.Delta
 			}
+		case models.Histogram:
+			current, err := svc.current(ctx, updated, metricID)
+			if err != nil {
+				return nil, err
+			}
+			histogram, err := svc.mergeHistogram(current.Histogram, metric)
+			if err != nil {
+				return nil, fmt.Errorf("histogram %q: %w", metric.ID, err)
+			}
+			merged := *metric
+			merged.Value = nil
+			merged.Histogram = histogram
+			metric = &merged
+		case models.Summary:
+			current, err := svc.current(ctx, updated, metricID)
+			if err != nil {
+				return nil, err
+			}
+			summary, err := mergeSummary(current.Summary, metric)
+			if err != nil {
+				return nil, fmt.Errorf("summary %q: %w", metric.ID, err)
+			}
+			merged := *metric
+			merged.Value = nil
+			merged.Summary = summary
+			metric = &merged
+		case models.Set:
+			current, err := svc.current(ctx, updated, metricID)
+			if err != nil {
+				return nil, err
+			}
+			set, err := mergeSet(current.Set, metric)
+			if err != nil {
+				return nil, fmt.Errorf("set %q: %w", metric.ID, err)
+			}
+			merged := *metric
+			merged.Members = nil
+			merged.Set = set
+			metric = &merged
 		}
 
-		err := svc.saver.Save(ctx, *metric)
-		if err != nil {
-			return nil, err
-		}
-
-		updated[models.MetricID{ID: metric.ID, MType: metric.MType}] = *metric
+		updated[metricID] = *metric
 	}
 
 	updatedSlice := make([]*models.Metrics, 0, len(updated))
@@ -80,8 +150,148 @@ func (svc *MetricUpdateService) Update(
 	}
 
 	sort.Slice(updatedSlice, func(i, j int) bool {
-		return updatedSlice[i].ID < updatedSlice[j].ID
+		a, b := updatedSlice[i], updatedSlice[j]
+		if a.ID != b.ID {
+			return a.ID < b.ID
+		}
+		if a.MType != b.MType {
+			return a.MType < b.MType
+		}
+		return a.Labels < b.Labels
 	})
 
+	if len(updatedSlice) == 0 {
+		return updatedSlice, nil
+	}
+
+	batch := make([]models.Metrics, 0, len(updatedSlice))
+	for _, m := range updatedSlice {
+		batch = append(batch, *m)
+	}
+
+	err := svc.saver.Save(ctx, batch...)
+	if err != nil {
+		logger.Log.Errorw("failed to save metrics",
+			"count", len(batch),
+			"error", err,
+		)
+		return nil, err
+	}
+
 	return updatedSlice, nil
 }
+
+// current returns the value of a metric updated earlier in the batch, or the
+// stored one.
+func (svc *MetricUpdateService) current(
+	ctx context.Context,
+	updated map[models.MetricID]models.Metrics,
+	metricID models.MetricID,
+) (models.Metrics, error) {
+	if current, found := updated[metricID]; found {
+		return current, nil
+	}
+
+	stored, err := svc.getter.Get(ctx, metricID)
+	if err != nil {
+		logger.Log.Errorw("failed to get metric",
+			"id", metricID.ID,
+			"type", metricID.MType,
+			"error", err,
+		)
+		return models.Metrics{}, err
+	}
+	if stored == nil {
+		return models.Metrics{}, nil
+	}
+	return *stored, nil
+}
+
+// mergeHistogram adds an observation or pre-aggregated buckets to a copy of
+// current. A new histogram takes the bounds of the buckets sent, or the
+// configured ones for an observation.
+func (svc *MetricUpdateService) mergeHistogram(
+	current *models.HistogramValue,
+	metric *models.Metrics,
+) (*models.HistogramValue, error) {
+	histogram := current.Clone()
+	if histogram == nil {
+		bounds := svc.histogramBuckets
+		if metric.Histogram != nil {
+			bounds = metric.Histogram.Bounds
+		}
+		var err error
+		histogram, err = models.NewHistogramValue(bounds)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case metric.Histogram != nil:
+		if err := histogram.Merge(metric.Histogram); err != nil {
+			return nil, err
+		}
+	case metric.Value != nil:
+		histogram.Observe(*metric.Value)
+	}
+
+	return histogram, nil
+}
+
+// mergeSummary adds an observation or a sketch to a copy of current. A new
+// summary takes the accuracy of the sketch sent, or the default one for an
+// observation.
+func mergeSummary(current *ddsketch.Sketch, metric *models.Metrics) (*ddsketch.Sketch, error) {
+	summary := current.Clone()
+	if summary == nil {
+		accuracy := ddsketch.DefaultRelativeAccuracy
+		if metric.Summary != nil {
+			accuracy = metric.Summary.RelativeAccuracy
+		}
+		var err error
+		summary, err = ddsketch.New(accuracy)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case metric.Summary != nil:
+		if err := summary.Merge(metric.Summary); err != nil {
+			return nil, err
+		}
+	case metric.Value != nil:
+		summary.Add(*metric.Value)
+	}
+
+	return summary, nil
+}
+
+// mergeSet adds members and a sketch to a copy of current. A new set takes
+// the precision of the sketch sent, or the default one for members.
+func mergeSet(current *hyperloglog.Sketch, metric *models.Metrics) (*hyperloglog.Sketch, error) {
+	set := current.Clone()
+	if set == nil {
+		precision := uint8(hyperloglog.DefaultPrecision)
+		if metric.Set != nil {
+			precision = metric.Set.Precision()
+		}
+		var err error
+		set, err = hyperloglog.New(precision)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if metric.Set != nil {
+		if err := set.Merge(metric.Set); err != nil {
+			return nil, err
+		}
+	}
+	for _, member := range metric.Members {
+		set.Add(member)
+	}
+
+	return set, nil
+}
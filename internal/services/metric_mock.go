@@ -74,15 +74,20 @@ func (m *MockSaver) EXPECT() *MockSaverMockRecorder {
 }
 
 // Save mocks base method.
-func (m *MockSaver) Save(ctx context.Context, metric models.Metrics) error {
+func (m *MockSaver) Save(ctx context.Context, metrics ...models.Metrics) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Save", ctx, metric)
+	varargs := []interface{}{ctx}
+	for _, a := range metrics {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Save", varargs...)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Save indicates an expected call of Save.
-func (mr *MockSaverMockRecorder) Save(ctx, metric interface{}) *gomock.Call {
+func (mr *MockSaverMockRecorder) Save(ctx interface{}, metrics ...interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Save", reflect.TypeOf((*MockSaver)(nil).Save), ctx, metric)
+	varargs := append([]interface{}{ctx}, metrics...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Save", reflect.TypeOf((*MockSaver)(nil).Save), varargs...)
 }
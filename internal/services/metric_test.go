@@ -3,12 +3,24 @@ package services
 import (
 	"context"
 	"errors"
+	"runtime"
+	"sync"
 	"testing"
 
 	"github.com/golang/mock/gomock"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/configs/memory"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/ddsketch"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/hyperloglog"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/logger"
 	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/models"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/repositories"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestMetricUpdateService_Update(t *testing.T) {
@@ -172,7 +184,7 @@ func TestMetricUpdateService_Update(t *testing.T) {
 			},
 			mockGetterFunc: func() {},
 			mockSaverFunc: func() error {
-				mockSaver.EXPECT().Save(gomock.Any(), gomock.Any()).Return(nil).Times(3)
+				mockSaver.EXPECT().Save(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
 				return nil
 			},
 			expected: []*models.Metrics{
@@ -231,3 +243,385 @@ func TestMetricUpdateService_Update(t *testing.T) {
 		})
 	}
 }
+
+func TestMetricUpdateService_Update_Batch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockGetter := NewMockGetter(ctrl)
+	mockSaver := NewMockSaver(ctrl)
+
+	svc := NewMetricUpdateService(
+		WithMetricUpdateGetter(mockGetter),
+		WithMetricUpdateSaver(mockSaver),
+	)
+
+	ctx := context.Background()
+
+	int64Ptr := func(v int64) *int64 { return &v }
+	float64Ptr := func(v float64) *float64 { return &v }
+
+	t.Run("duplicate counters are summed and saved in one call", func(t *testing.T) {
+		mockGetter.EXPECT().
+			Get(ctx, models.MetricID{ID: "requests", MType: models.Counter}).
+			Return(&models.Metrics{ID: "requests", MType: models.Counter, Delta: int64Ptr(10)}, nil).
+			Times(1)
+
+		mockSaver.EXPECT().
+			Save(ctx,
+				models.Metrics{ID: "load", MType: models.Gauge, Value: float64Ptr(2)},
+				gomock.Any(),
+			).
+			DoAndReturn(func(_ context.Context, metrics ...models.Metrics) error {
+				assert.Equal(t, "requests", metrics[1].ID)
+				assert.Equal(t, int64(16), *metrics[1].Delta)
+				return nil
+			})
+
+		got, err := svc.Update(ctx, []*models.Metrics{
+			{ID: "requests", MType: models.Counter, Delta: int64Ptr(1)},
+			{ID: "load", MType: models.Gauge, Value: float64Ptr(1)},
+			{ID: "requests", MType: models.Counter, Delta: int64Ptr(5)},
+			{ID: "load", MType: models.Gauge, Value: float64Ptr(2)},
+		})
+		assert.NoError(t, err)
+		assert.Len(t, got, 2)
+		assert.Equal(t, int64(16), *got[1].Delta)
+		assert.Equal(t, 2.0, *got[0].Value)
+	})
+
+	t.Run("counters with different labels are summed separately", func(t *testing.T) {
+		mockGetter.EXPECT().
+			Get(ctx, models.MetricID{ID: "requests", MType: models.Counter, Labels: `route="/a"`}).
+			Return(&models.Metrics{ID: "requests", MType: models.Counter, Labels: `route="/a"`, Delta: int64Ptr(10)}, nil)
+		mockGetter.EXPECT().
+			Get(ctx, models.MetricID{ID: "requests", MType: models.Counter, Labels: `route="/b"`}).
+			Return(nil, nil)
+
+		mockSaver.EXPECT().Save(ctx, gomock.Any(), gomock.Any()).Return(nil)
+
+		got, err := svc.Update(ctx, []*models.Metrics{
+			{ID: "requests", MType: models.Counter, Labels: `route="/b"`, Delta: int64Ptr(1)},
+			{ID: "requests", MType: models.Counter, Labels: `route="/a"`, Delta: int64Ptr(2)},
+			{ID: "requests", MType: models.Counter, Labels: `route="/b"`, Delta: int64Ptr(3)},
+		})
+		require.NoError(t, err)
+		require.Len(t, got, 2)
+		assert.Equal(t, models.Labels(`route="/a"`), got[0].Labels)
+		assert.Equal(t, int64(12), *got[0].Delta)
+		assert.Equal(t, models.Labels(`route="/b"`), got[1].Labels)
+		assert.Equal(t, int64(4), *got[1].Delta)
+	})
+
+	t.Run("failed save returns no metrics", func(t *testing.T) {
+		mockSaver.EXPECT().
+			Save(ctx, gomock.Any(), gomock.Any()).
+			Return(errors.New("save error"))
+
+		got, err := svc.Update(ctx, []*models.Metrics{
+			{ID: "a", MType: models.Gauge, Value: float64Ptr(1)},
+			{ID: "b", MType: models.Gauge, Value: float64Ptr(2)},
+		})
+		assert.Error(t, err)
+		assert.Nil(t, got)
+	})
+
+	t.Run("empty batch skips saver", func(t *testing.T) {
+		got, err := svc.Update(ctx, []*models.Metrics{nil})
+		assert.NoError(t, err)
+		assert.Empty(t, got)
+	})
+}
+
+func TestMetricUpdateService_Update_LogsFailures(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	core, logs := observer.New(zapcore.ErrorLevel)
+	original := logger.Log
+	logger.Log = zap.New(core).Sugar()
+	defer func() { logger.Log = original }()
+
+	mockGetter := NewMockGetter(ctrl)
+	mockSaver := NewMockSaver(ctrl)
+
+	svc := NewMetricUpdateService(
+		WithMetricUpdateGetter(mockGetter),
+		WithMetricUpdateSaver(mockSaver),
+	)
+
+	ctx := context.Background()
+	delta := int64(1)
+	value := 1.0
+
+	mockGetter.EXPECT().Get(ctx, gomock.Any()).Return(nil, errors.New("getter error"))
+	_, err := svc.Update(ctx, []*models.Metrics{{ID: "c", MType: models.Counter, Delta: &delta}})
+	assert.Error(t, err)
+
+	mockSaver.EXPECT().Save(ctx, gomock.Any()).Return(errors.New("save error"))
+	_, err = svc.Update(ctx, []*models.Metrics{{ID: "g", MType: models.Gauge, Value: &value}})
+	assert.Error(t, err)
+
+	entries := logs.All()
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, "failed to get metric", entries[0].Message)
+		assert.Equal(t, "c", entries[0].ContextMap()["id"])
+		assert.Equal(t, "failed to save metrics", entries[1].Message)
+	}
+}
+
+func TestMetricUpdateService_Update_Histogram(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockGetter := NewMockGetter(ctrl)
+	mockSaver := NewMockSaver(ctrl)
+
+	svc := NewMetricUpdateService(
+		WithMetricUpdateGetter(mockGetter),
+		WithMetricUpdateSaver(mockSaver),
+		WithMetricUpdateHistogramBuckets(0.1, 1),
+	)
+
+	ctx := context.Background()
+	id := models.MetricID{ID: "latency", MType: models.Histogram}
+	float64Ptr := func(v float64) *float64 { return &v }
+
+	t.Run("observations create a histogram with the configured buckets", func(t *testing.T) {
+		mockGetter.EXPECT().Get(ctx, id).Return(nil, nil)
+		mockSaver.EXPECT().Save(ctx, gomock.Any()).Return(nil)
+
+		got, err := svc.Update(ctx, []*models.Metrics{
+			{ID: "latency", MType: models.Histogram, Value: float64Ptr(0.05)},
+			{ID: "latency", MType: models.Histogram, Value: float64Ptr(0.5)},
+			{ID: "latency", MType: models.Histogram, Value: float64Ptr(3)},
+		})
+		require.NoError(t, err)
+		require.Len(t, got, 1)
+		assert.Nil(t, got[0].Value)
+		assert.Equal(t, &models.HistogramValue{
+			Bounds: []float64{0.1, 1}, Counts: []uint64{1, 1, 1}, Sum: 3.55, Count: 3,
+		}, got[0].Histogram)
+	})
+
+	t.Run("buckets are merged into the stored histogram", func(t *testing.T) {
+		stored := &models.HistogramValue{Bounds: []float64{0.1, 1}, Counts: []uint64{1, 0, 0}, Sum: 0.05, Count: 1}
+		mockGetter.EXPECT().Get(ctx, id).Return(&models.Metrics{ID: "latency", MType: models.Histogram, Histogram: stored}, nil)
+		mockSaver.EXPECT().Save(ctx, gomock.Any()).Return(nil)
+
+		got, err := svc.Update(ctx, []*models.Metrics{
+			{ID: "latency", MType: models.Histogram, Histogram: &models.HistogramValue{
+				Bounds: []float64{0.1, 1}, Counts: []uint64{0, 2, 0}, Sum: 1, Count: 2,
+			}},
+			{ID: "latency", MType: models.Histogram, Value: float64Ptr(0.01)},
+		})
+		require.NoError(t, err)
+		require.Len(t, got, 1)
+		assert.Equal(t, []uint64{2, 2, 0}, got[0].Histogram.Counts)
+		assert.Equal(t, uint64(4), got[0].Histogram.Count)
+		assert.Equal(t, []uint64{1, 0, 0}, stored.Counts, "the stored value must not be modified")
+	})
+
+	t.Run("buckets of a new histogram are taken from the update", func(t *testing.T) {
+		mockGetter.EXPECT().Get(ctx, id).Return(nil, nil)
+		mockSaver.EXPECT().Save(ctx, gomock.Any()).Return(nil)
+
+		got, err := svc.Update(ctx, []*models.Metrics{
+			{ID: "latency", MType: models.Histogram, Histogram: &models.HistogramValue{
+				Bounds: []float64{5}, Counts: []uint64{1, 1}, Sum: 8, Count: 2,
+			}},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []float64{5}, got[0].Histogram.Bounds)
+	})
+
+	t.Run("different bounds reject the batch", func(t *testing.T) {
+		mockGetter.EXPECT().Get(ctx, id).Return(nil, nil)
+
+		got, err := svc.Update(ctx, []*models.Metrics{
+			{ID: "latency", MType: models.Histogram, Value: float64Ptr(1)},
+			{ID: "latency", MType: models.Histogram, Histogram: &models.HistogramValue{
+				Bounds: []float64{5}, Counts: []uint64{1, 0}, Sum: 1, Count: 1,
+			}},
+		})
+		assert.ErrorIs(t, err, models.ErrHistogramBuckets)
+		assert.Nil(t, got)
+	})
+}
+
+func TestMetricUpdateService_Update_Summary(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockGetter := NewMockGetter(ctrl)
+	mockSaver := NewMockSaver(ctrl)
+
+	svc := NewMetricUpdateService(
+		WithMetricUpdateGetter(mockGetter),
+		WithMetricUpdateSaver(mockSaver),
+	)
+
+	ctx := context.Background()
+	id := models.MetricID{ID: "latency", MType: models.Summary}
+	float64Ptr := func(v float64) *float64 { return &v }
+
+	t.Run("observations and sketches are merged into the stored summary", func(t *testing.T) {
+		stored, _ := ddsketch.New(ddsketch.DefaultRelativeAccuracy)
+		stored.Add(1)
+		mockGetter.EXPECT().Get(ctx, id).Return(&models.Metrics{ID: "latency", MType: models.Summary, Summary: stored}, nil)
+		mockSaver.EXPECT().Save(ctx, gomock.Any()).Return(nil)
+
+		agent, _ := ddsketch.New(ddsketch.DefaultRelativeAccuracy)
+		agent.Add(2)
+		agent.Add(3)
+
+		got, err := svc.Update(ctx, []*models.Metrics{
+			{ID: "latency", MType: models.Summary, Summary: agent},
+			{ID: "latency", MType: models.Summary, Value: float64Ptr(4)},
+		})
+		require.NoError(t, err)
+		require.Len(t, got, 1)
+		assert.Nil(t, got[0].Value)
+		assert.Equal(t, uint64(4), got[0].Summary.Count)
+		assert.Equal(t, 10.0, got[0].Summary.Sum)
+		assert.InDelta(t, 4, got[0].Summary.Quantile(1), 1e-9)
+		assert.Equal(t, uint64(1), stored.Count, "the stored value must not be modified")
+	})
+
+	t.Run("accuracy of a new summary is taken from the update", func(t *testing.T) {
+		mockGetter.EXPECT().Get(ctx, id).Return(nil, nil)
+		mockSaver.EXPECT().Save(ctx, gomock.Any()).Return(nil)
+
+		sketch, _ := ddsketch.New(0.05)
+		sketch.Add(1)
+
+		got, err := svc.Update(ctx, []*models.Metrics{{ID: "latency", MType: models.Summary, Summary: sketch}})
+		require.NoError(t, err)
+		assert.Equal(t, 0.05, got[0].Summary.RelativeAccuracy)
+	})
+
+	t.Run("different accuracy rejects the batch", func(t *testing.T) {
+		mockGetter.EXPECT().Get(ctx, id).Return(nil, nil)
+
+		sketch, _ := ddsketch.New(0.05)
+		sketch.Add(1)
+
+		got, err := svc.Update(ctx, []*models.Metrics{
+			{ID: "latency", MType: models.Summary, Value: float64Ptr(1)},
+			{ID: "latency", MType: models.Summary, Summary: sketch},
+		})
+		assert.ErrorIs(t, err, ddsketch.ErrAccuracyMismatch)
+		assert.Nil(t, got)
+	})
+}
+
+func TestMetricUpdateService_Update_Set(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockGetter := NewMockGetter(ctrl)
+	mockSaver := NewMockSaver(ctrl)
+
+	svc := NewMetricUpdateService(
+		WithMetricUpdateGetter(mockGetter),
+		WithMetricUpdateSaver(mockSaver),
+	)
+
+	ctx := context.Background()
+	id := models.MetricID{ID: "users", MType: models.Set}
+
+	t.Run("members and sketches are merged into the stored set", func(t *testing.T) {
+		stored, _ := hyperloglog.New(hyperloglog.DefaultPrecision)
+		stored.Add("alice")
+		mockGetter.EXPECT().Get(ctx, id).Return(&models.Metrics{ID: "users", MType: models.Set, Set: stored}, nil)
+		mockSaver.EXPECT().Save(ctx, gomock.Any()).Return(nil)
+
+		agent, _ := hyperloglog.New(hyperloglog.DefaultPrecision)
+		agent.Add("bob")
+		agent.Add("alice")
+
+		got, err := svc.Update(ctx, []*models.Metrics{
+			{ID: "users", MType: models.Set, Set: agent},
+			{ID: "users", MType: models.Set, Members: []string{"carol", "bob"}},
+		})
+		require.NoError(t, err)
+		require.Len(t, got, 1)
+		assert.Nil(t, got[0].Members)
+		assert.Equal(t, uint64(3), got[0].Set.Count())
+		assert.Equal(t, uint64(1), stored.Count(), "the stored value must not be modified")
+	})
+
+	t.Run("precision of a new set is taken from the update", func(t *testing.T) {
+		mockGetter.EXPECT().Get(ctx, id).Return(nil, nil)
+		mockSaver.EXPECT().Save(ctx, gomock.Any()).Return(nil)
+
+		sketch, _ := hyperloglog.New(10)
+
+		got, err := svc.Update(ctx, []*models.Metrics{{ID: "users", MType: models.Set, Set: sketch, Members: []string{"alice"}}})
+		require.NoError(t, err)
+		assert.Equal(t, uint8(10), got[0].Set.Precision())
+		assert.Equal(t, uint64(1), got[0].Set.Count())
+	})
+
+	t.Run("different precision rejects the batch", func(t *testing.T) {
+		mockGetter.EXPECT().Get(ctx, id).Return(nil, nil)
+
+		sketch, _ := hyperloglog.New(10)
+
+		got, err := svc.Update(ctx, []*models.Metrics{
+			{ID: "users", MType: models.Set, Members: []string{"alice"}},
+			{ID: "users", MType: models.Set, Set: sketch},
+		})
+		assert.ErrorIs(t, err, hyperloglog.ErrPrecisionMismatch)
+		assert.Nil(t, got)
+	})
+}
+
+// yieldingGetter lets other goroutines run between reading and saving a
+// metric, so that lost updates show up even on a single CPU.
+type yieldingGetter struct {
+	Getter
+}
+
+func (g yieldingGetter) Get(ctx context.Context, metricID models.MetricID) (*models.Metrics, error) {
+	runtime.Gosched()
+	return g.Getter.Get(ctx, metricID)
+}
+
+func TestMetricUpdateService_Update_Concurrent(t *testing.T) {
+	storage := memory.NewMemory[models.MetricID, models.Metrics]()
+	svc := NewMetricUpdateService(
+		WithMetricUpdateGetter(yieldingGetter{repositories.NewMetricsMemoryGetRepository(storage)}),
+		WithMetricUpdateSaver(repositories.NewMetricsMemorySaveRepository(storage)),
+	)
+
+	const (
+		workers = 50
+		updates = 200
+	)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < updates; i++ {
+				delta, value := int64(1), 0.5
+				_, err := svc.Update(context.Background(), []*models.Metrics{
+					{ID: "hits", MType: models.Counter, Delta: &delta},
+					{ID: "latency", MType: models.Histogram, Value: &value},
+				})
+				assert.NoError(t, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	hits := storage.Data[models.MetricID{ID: "hits", MType: models.Counter}]
+	require.NotNil(t, hits.Delta)
+	assert.Equal(t, int64(workers*updates), *hits.Delta)
+
+	latency := storage.Data[models.MetricID{ID: "latency", MType: models.Histogram}]
+	require.NotNil(t, latency.Histogram)
+	assert.Equal(t, uint64(workers*updates), latency.Histogram.Count)
+}
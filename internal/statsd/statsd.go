@@ -0,0 +1,167 @@
+// Package statsd parses StatsD packets and aggregates the samples received
+// between two flushes.
+package statsd
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Sample types understood by the parser.
+const (
+	TypeCounter = "c"
+	TypeGauge   = "g"
+)
+
+// ErrUnsupportedType is returned for valid StatsD lines of a type that cannot
+// be stored, such as timers and sets.
+var ErrUnsupportedType = errors.New("unsupported metric type")
+
+// Sample is a single name:value|type line.
+type Sample struct {
+	Name  string
+	Type  string
+	Value float64
+	// Relative is set for gauges written with an explicit sign, which adjust
+	// the current value instead of replacing it.
+	Relative bool
+	// SampleRate is the @rate of the line, 1 when absent.
+	SampleRate float64
+}
+
+// Parse splits a packet into lines and parses each of them. Lines that fail
+// to parse are returned as errors and do not affect the other lines.
+func Parse(packet []byte) ([]Sample, []error) {
+	var (
+		samples []Sample
+		errs    []error
+	)
+
+	for _, line := range strings.Split(string(packet), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		sample, err := ParseLine(line)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%q: %w", line, err))
+			continue
+		}
+		samples = append(samples, sample)
+	}
+
+	return samples, errs
+}
+
+// ParseLine parses name:value|type[|@rate][|#tags]. Tags are accepted and
+// ignored.
+func ParseLine(line string) (Sample, error) {
+	name, rest, ok := strings.Cut(line, ":")
+	if !ok || name == "" {
+		return Sample{}, errors.New("missing name")
+	}
+
+	parts := strings.Split(rest, "|")
+	if len(parts) < 2 {
+		return Sample{}, errors.New("missing type")
+	}
+
+	sample := Sample{Name: name, Type: parts[1], SampleRate: 1}
+	if sample.Type != TypeCounter && sample.Type != TypeGauge {
+		return Sample{}, fmt.Errorf("%w %q", ErrUnsupportedType, sample.Type)
+	}
+
+	raw := parts[0]
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return Sample{}, fmt.Errorf("invalid value %q", raw)
+	}
+	sample.Value = value
+	sample.Relative = sample.Type == TypeGauge && (raw[0] == '+' || raw[0] == '-')
+
+	for _, part := range parts[2:] {
+		switch {
+		case strings.HasPrefix(part, "@"):
+			rate, err := strconv.ParseFloat(part[1:], 64)
+			if err != nil || rate <= 0 || rate > 1 {
+				return Sample{}, fmt.Errorf("invalid sample rate %q", part)
+			}
+			sample.SampleRate = rate
+		case strings.HasPrefix(part, "#"):
+		default:
+			return Sample{}, fmt.Errorf("unexpected %q", part)
+		}
+	}
+
+	return sample, nil
+}
+
+// Gauge is the aggregated state of a gauge.
+type Gauge struct {
+	Value float64
+	// Relative is set when no absolute value was received in the window, in
+	// which case Value is to be added to the stored value.
+	Relative bool
+}
+
+// Batch is the result of a flush.
+type Batch struct {
+	Counters map[string]int64
+	Gauges   map[string]Gauge
+}
+
+// Aggregator folds samples into one value per metric. Counters are summed
+// after scaling by their sample rate, gauges keep the last absolute value
+// adjusted by the relative changes that followed it. It is not safe for
+// concurrent use.
+type Aggregator struct {
+	counters map[string]float64
+	gauges   map[string]Gauge
+}
+
+func NewAggregator() *Aggregator {
+	return &Aggregator{
+		counters: make(map[string]float64),
+		gauges:   make(map[string]Gauge),
+	}
+}
+
+// Add folds a sample into the current window.
+func (a *Aggregator) Add(s Sample) {
+	switch s.Type {
+	case TypeCounter:
+		a.counters[s.Name] += s.Value / s.SampleRate
+	case TypeGauge:
+		if !s.Relative {
+			a.gauges[s.Name] = Gauge{Value: s.Value}
+			return
+		}
+		g, ok := a.gauges[s.Name]
+		if !ok {
+			g.Relative = true
+		}
+		g.Value += s.Value
+		a.gauges[s.Name] = g
+	}
+}
+
+// Flush returns the window aggregated so far and starts a new one. Counter
+// totals are rounded to whole increments.
+func (a *Aggregator) Flush() Batch {
+	batch := Batch{
+		Counters: make(map[string]int64, len(a.counters)),
+		Gauges:   a.gauges,
+	}
+	for name, total := range a.counters {
+		batch.Counters[name] = int64(math.Round(total))
+	}
+
+	a.counters = make(map[string]float64)
+	a.gauges = make(map[string]Gauge)
+
+	return batch
+}
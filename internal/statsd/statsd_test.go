@@ -0,0 +1,129 @@
+package statsd
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLine(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		expected Sample
+	}{
+		{
+			name:     "counter",
+			line:     "requests:3|c",
+			expected: Sample{Name: "requests", Type: TypeCounter, Value: 3, SampleRate: 1},
+		},
+		{
+			name:     "sampled counter with tags",
+			line:     "requests:1|c|@0.1|#env:prod",
+			expected: Sample{Name: "requests", Type: TypeCounter, Value: 1, SampleRate: 0.1},
+		},
+		{
+			name:     "absolute gauge",
+			line:     "queue.size:42.5|g",
+			expected: Sample{Name: "queue.size", Type: TypeGauge, Value: 42.5, SampleRate: 1},
+		},
+		{
+			name:     "increment gauge",
+			line:     "queue.size:+4|g",
+			expected: Sample{Name: "queue.size", Type: TypeGauge, Value: 4, Relative: true, SampleRate: 1},
+		},
+		{
+			name:     "decrement gauge",
+			line:     "queue.size:-4|g",
+			expected: Sample{Name: "queue.size", Type: TypeGauge, Value: -4, Relative: true, SampleRate: 1},
+		},
+		{
+			name:     "negative counter is not relative",
+			line:     "balance:-2|c",
+			expected: Sample{Name: "balance", Type: TypeCounter, Value: -2, SampleRate: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sample, err := ParseLine(tt.line)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, sample)
+		})
+	}
+}
+
+func TestParseLine_Errors(t *testing.T) {
+	tests := []struct {
+		name        string
+		line        string
+		unsupported bool
+	}{
+		{name: "missing name", line: ":1|c"},
+		{name: "missing value separator", line: "requests|c"},
+		{name: "missing type", line: "requests:1"},
+		{name: "invalid value", line: "requests:many|c"},
+		{name: "infinite value", line: "requests:Inf|g"},
+		{name: "zero sample rate", line: "requests:1|c|@0"},
+		{name: "sample rate above one", line: "requests:1|c|@2"},
+		{name: "unknown section", line: "requests:1|c|x"},
+		{name: "timer", line: "latency:320|ms", unsupported: true},
+		{name: "set", line: "users:42|s", unsupported: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseLine(tt.line)
+			require.Error(t, err)
+			assert.Equal(t, tt.unsupported, errors.Is(err, ErrUnsupportedType))
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	samples, errs := Parse([]byte("a:1|c\n\nbroken\nb:2|g\n"))
+
+	require.Len(t, samples, 2)
+	assert.Equal(t, "a", samples[0].Name)
+	assert.Equal(t, "b", samples[1].Name)
+	require.Len(t, errs, 1)
+	assert.Contains(t, errs[0].Error(), `"broken"`)
+}
+
+func TestAggregator(t *testing.T) {
+	a := NewAggregator()
+
+	for _, line := range []string{
+		"requests:1|c",
+		"requests:2|c",
+		"sampled:1|c|@0.5",
+		"sampled:1|c|@0.5",
+		"sampled:0.2|c",
+		"set:10|g",
+		"set:+5|g",
+		"set:-1|g",
+		"adjusted:+3|g",
+		"adjusted:-1|g",
+		"reset:+3|g",
+		"reset:7|g",
+	} {
+		sample, err := ParseLine(line)
+		require.NoError(t, err)
+		a.Add(sample)
+	}
+
+	batch := a.Flush()
+
+	assert.Equal(t, map[string]int64{"requests": 3, "sampled": 4}, batch.Counters)
+	assert.Equal(t, map[string]Gauge{
+		"set":      {Value: 14},
+		"adjusted": {Value: 2, Relative: true},
+		"reset":    {Value: 7},
+	}, batch.Gauges)
+
+	empty := a.Flush()
+	assert.Empty(t, empty.Counters)
+	assert.Empty(t, empty.Gauges)
+}
@@ -0,0 +1,324 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/logger"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/models"
+)
+
+// MetricUpdater defines an interface for sending a batch of metrics to the server.
+type MetricUpdater interface {
+	Updates(ctx context.Context, metrics []*models.Metrics) error
+}
+
+// MetricCollector takes one sample of metrics.
+type MetricCollector func(ctx context.Context) ([]*models.Metrics, error)
+
+// scheduledCollector is a collector together with its own poll interval.
+type scheduledCollector struct {
+	collect  MetricCollector
+	interval time.Duration
+}
+
+// MetricAgentWorkerOpt is a functional option for configuring MetricAgentWorker
+type MetricAgentWorkerOpt func(*MetricAgentWorker)
+
+// WithMetricAgentUpdater sets the updater used to report metrics
+func WithMetricAgentUpdater(updater MetricUpdater) MetricAgentWorkerOpt {
+	return func(w *MetricAgentWorker) {
+		w.updater = updater
+	}
+}
+
+// WithMetricAgentCollector adds a collector polled every interval in its own goroutine
+func WithMetricAgentCollector(collect MetricCollector, interval time.Duration) MetricAgentWorkerOpt {
+	return func(w *MetricAgentWorker) {
+		w.collectors = append(w.collectors, scheduledCollector{collect: collect, interval: interval})
+	}
+}
+
+// WithMetricAgentReportInterval sets how often collected metrics are reported
+func WithMetricAgentReportInterval(interval time.Duration) MetricAgentWorkerOpt {
+	return func(w *MetricAgentWorker) {
+		w.reportInterval = interval
+	}
+}
+
+// WithMetricAgentRateLimit sets the maximum number of concurrent requests to the server
+func WithMetricAgentRateLimit(limit int) MetricAgentWorkerOpt {
+	return func(w *MetricAgentWorker) {
+		w.rateLimit = limit
+	}
+}
+
+// WithMetricAgentShutdownTimeout bounds how long pending batches are sent after shutdown
+func WithMetricAgentShutdownTimeout(timeout time.Duration) MetricAgentWorkerOpt {
+	return func(w *MetricAgentWorker) {
+		w.shutdownTimeout = timeout
+	}
+}
+
+// MetricAgentWorker runs the agent pipeline: every collector pushes samples onto
+// a channel, the samples are accumulated and on each report tick the batch is
+// handed to a pool of rateLimit senders, so at most rateLimit requests are in
+// flight at any time.
+type MetricAgentWorker struct {
+	updater         MetricUpdater
+	collectors      []scheduledCollector
+	reportInterval  time.Duration
+	rateLimit       int
+	shutdownTimeout time.Duration
+
+	buffer *metricBuffer
+}
+
+func NewMetricAgentWorker(opts ...MetricAgentWorkerOpt) *MetricAgentWorker {
+	w := &MetricAgentWorker{
+		reportInterval:  10 * time.Second,
+		rateLimit:       1,
+		shutdownTimeout: 5 * time.Second,
+		buffer:          newMetricBuffer(),
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	if w.rateLimit < 1 {
+		w.rateLimit = 1
+	}
+	return w
+}
+
+// Start runs the pipeline until ctx is cancelled. On cancellation the
+// collectors stop, the accumulated metrics are reported one last time and the
+// senders drain every pending batch within the shutdown timeout.
+func (w *MetricAgentWorker) Start(ctx context.Context) error {
+	// Senders outlive ctx so that pending batches can be drained, but no
+	// longer than the shutdown timeout.
+	sendCtx, cancelSend := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelSend()
+	stopDrain := context.AfterFunc(ctx, func() {
+		time.AfterFunc(w.shutdownTimeout, cancelSend)
+	})
+	defer stopDrain()
+
+	samples := make(chan []*models.Metrics, len(w.collectors))
+	batches := make(chan []*models.Metrics, w.rateLimit)
+
+	var collectorsWG sync.WaitGroup
+	for _, c := range w.collectors {
+		collectorsWG.Add(1)
+		go func(c scheduledCollector) {
+			defer collectorsWG.Done()
+			w.runCollector(ctx, c, samples)
+		}(c)
+	}
+	go func() {
+		collectorsWG.Wait()
+		close(samples)
+	}()
+
+	var sendersWG sync.WaitGroup
+	for i := 0; i < w.rateLimit; i++ {
+		sendersWG.Add(1)
+		go func() {
+			defer sendersWG.Done()
+			w.runSender(sendCtx, batches)
+		}()
+	}
+
+	w.runAggregator(samples, batches)
+
+	sendersWG.Wait()
+
+	return nil
+}
+
+// runCollector polls a collector on its own schedule and pushes every sample
+// onto samples until ctx is cancelled.
+func (w *MetricAgentWorker) runCollector(
+	ctx context.Context,
+	c scheduledCollector,
+	samples chan<- []*models.Metrics,
+) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			metrics, err := c.collect(ctx)
+			if err != nil {
+				logger.Log.Errorw("failed to collect metrics", "error", err)
+				continue
+			}
+
+			// The aggregator reads samples until every collector has
+			// returned, so a collected sample is never dropped.
+			samples <- metrics
+		}
+	}
+}
+
+// runAggregator accumulates samples and emits a batch on every report tick.
+// Once samples is closed it emits the final batch and closes batches.
+func (w *MetricAgentWorker) runAggregator(
+	samples <-chan []*models.Metrics,
+	batches chan<- []*models.Metrics,
+) {
+	defer close(batches)
+
+	ticker := time.NewTicker(w.reportInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case metrics, ok := <-samples:
+			if !ok {
+				if batch := w.buffer.drain(); len(batch) > 0 {
+					batches <- batch
+				}
+				return
+			}
+			w.buffer.add(metrics)
+		case <-ticker.C:
+			if batch := w.buffer.drain(); len(batch) > 0 {
+				batches <- batch
+			}
+		}
+	}
+}
+
+// runSender sends batches until the channel is closed. Counters of a batch
+// that failed to send are put back so that the next report carries them, only
+// the pending ones when the batch was applied partially.
+func (w *MetricAgentWorker) runSender(
+	ctx context.Context,
+	batches <-chan []*models.Metrics,
+) {
+	for batch := range batches {
+		if err := w.updater.Updates(ctx, batch); err != nil {
+			logger.Log.Errorw("failed to report metrics", "count", len(batch), "error", err)
+
+			var partial *models.PartialUpdateError
+			if errors.As(err, &partial) {
+				batch = partial.Pending
+			}
+			w.buffer.restoreDeltas(batch)
+		}
+	}
+}
+
+// metricBuffer accumulates collected metrics between reports: gauges keep the
+// latest value, counters sum their deltas, histograms with the same buckets
+// and summaries with the same accuracy are merged, sets collect their members
+// and merge sketches of the same precision.
+type metricBuffer struct {
+	mu      sync.Mutex
+	metrics map[models.MetricID]*models.Metrics
+}
+
+func newMetricBuffer() *metricBuffer {
+	return &metricBuffer{metrics: make(map[models.MetricID]*models.Metrics)}
+}
+
+func (b *metricBuffer) add(metrics []*models.Metrics) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, metric := range metrics {
+		if metric == nil {
+			continue
+		}
+		b.merge(metric)
+	}
+}
+
+// restoreDeltas puts back the counters, histograms, summaries and sets of a
+// batch that failed to be sent, gauges are superseded by newer samples anyway.
+func (b *metricBuffer) restoreDeltas(metrics []*models.Metrics) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, metric := range metrics {
+		if metric != nil && metric.MType != models.Gauge {
+			b.merge(metric)
+		}
+	}
+}
+
+func (b *metricBuffer) merge(metric *models.Metrics) {
+	metricID := metric.Key()
+
+	current, found := b.metrics[metricID]
+	if found && metric.MType == models.Counter && current.Delta != nil && metric.Delta != nil {
+		delta := *current.Delta + *metric.Delta
+		current.Delta = &delta
+		return
+	}
+	if found && metric.MType == models.Histogram && current.Histogram != nil && metric.Histogram != nil {
+		merged := current.Histogram.Clone()
+		if merged.Merge(metric.Histogram) == nil {
+			current.Histogram = merged
+			return
+		}
+	}
+	if found && metric.MType == models.Summary && current.Summary != nil && metric.Summary != nil {
+		merged := current.Summary.Clone()
+		if merged.Merge(metric.Summary) == nil {
+			current.Summary = merged
+			return
+		}
+	}
+
+	if found && metric.MType == models.Set &&
+		(current.Set == nil || metric.Set == nil || current.Set.Precision() == metric.Set.Precision()) {
+		merged := current.Set.Clone()
+		switch {
+		case merged == nil:
+			merged = metric.Set.Clone()
+		case metric.Set != nil:
+			merged.Merge(metric.Set)
+		}
+		current.Set = merged
+		current.Members = append(append([]string(nil), current.Members...), metric.Members...)
+		return
+	}
+
+	m := *metric
+	m.Histogram = metric.Histogram.Clone()
+	m.Summary = metric.Summary.Clone()
+	m.Set = metric.Set.Clone()
+	m.Members = append([]string(nil), metric.Members...)
+	b.metrics[metricID] = &m
+}
+
+// drain returns the accumulated metrics sorted by type, name and labels and empties the buffer.
+func (b *metricBuffer) drain() []*models.Metrics {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	batch := make([]*models.Metrics, 0, len(b.metrics))
+	for _, metric := range b.metrics {
+		batch = append(batch, metric)
+	}
+	b.metrics = make(map[models.MetricID]*models.Metrics)
+
+	sort.Slice(batch, func(i, j int) bool {
+		if batch[i].MType != batch[j].MType {
+			return batch[i].MType < batch[j].MType
+		}
+		if batch[i].ID != batch[j].ID {
+			return batch[i].ID < batch[j].ID
+		}
+		return batch[i].Labels < batch[j].Labels
+	})
+
+	return batch
+}
@@ -0,0 +1,376 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/ddsketch"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/hyperloglog"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/models"
+)
+
+func findMetric(metrics []*models.Metrics, id string) *models.Metrics {
+	for _, m := range metrics {
+		if m.ID == id {
+			return m
+		}
+	}
+	return nil
+}
+
+func counter(id string, delta int64) *models.Metrics {
+	return &models.Metrics{ID: id, MType: models.Counter, Delta: &delta}
+}
+
+func gauge(id string, value float64) *models.Metrics {
+	return &models.Metrics{ID: id, MType: models.Gauge, Value: &value}
+}
+
+func TestCollectRuntimeMetrics(t *testing.T) {
+	metrics, err := CollectRuntimeMetrics(context.Background())
+	require.NoError(t, err)
+
+	pollCount := findMetric(metrics, "PollCount")
+	require.NotNil(t, pollCount)
+	assert.Equal(t, models.Counter, pollCount.MType)
+	assert.Equal(t, int64(1), *pollCount.Delta)
+
+	for _, id := range []string{"Alloc", "HeapInuse", "GCSys", "NumGC", "RandomValue"} {
+		m := findMetric(metrics, id)
+		require.NotNil(t, m, id)
+		assert.Equal(t, models.Gauge, m.MType)
+		assert.NotNil(t, m.Value)
+	}
+}
+
+func TestMetricBuffer(t *testing.T) {
+	b := newMetricBuffer()
+
+	b.add([]*models.Metrics{counter("PollCount", 1), gauge("Alloc", 1), nil})
+	b.add([]*models.Metrics{counter("PollCount", 1), gauge("Alloc", 2)})
+
+	batch := b.drain()
+	require.Len(t, batch, 2)
+	assert.Equal(t, int64(2), *findMetric(batch, "PollCount").Delta)
+	assert.Equal(t, 2.0, *findMetric(batch, "Alloc").Value)
+
+	assert.Empty(t, b.drain())
+
+	b.add([]*models.Metrics{counter("PollCount", 1)})
+	b.restoreDeltas(batch)
+
+	batch = b.drain()
+	require.Len(t, batch, 1, "gauges of a failed batch are not restored")
+	assert.Equal(t, int64(3), *findMetric(batch, "PollCount").Delta)
+}
+
+func TestMetricBuffer_Histograms(t *testing.T) {
+	b := newMetricBuffer()
+
+	histogram := func(counts ...uint64) *models.Metrics {
+		var total uint64
+		for _, c := range counts {
+			total += c
+		}
+		return &models.Metrics{ID: "latency", MType: models.Histogram, Histogram: &models.HistogramValue{
+			Bounds: []float64{1}, Counts: counts, Sum: float64(total), Count: total,
+		}}
+	}
+
+	first := histogram(1, 0)
+	b.add([]*models.Metrics{first})
+	b.add([]*models.Metrics{histogram(2, 1)})
+
+	batch := b.drain()
+	require.Len(t, batch, 1)
+	assert.Equal(t, []uint64{3, 1}, batch[0].Histogram.Counts)
+	assert.Equal(t, uint64(4), batch[0].Histogram.Count)
+	assert.Equal(t, []uint64{1, 0}, first.Histogram.Counts, "merging must not modify collected samples")
+
+	b.add([]*models.Metrics{histogram(0, 1)})
+	b.restoreDeltas(batch)
+
+	batch = b.drain()
+	require.Len(t, batch, 1)
+	assert.Equal(t, []uint64{3, 2}, batch[0].Histogram.Counts)
+}
+
+func TestMetricBuffer_Summaries(t *testing.T) {
+	b := newMetricBuffer()
+
+	summary := func(values ...float64) *models.Metrics {
+		s, _ := ddsketch.New(ddsketch.DefaultRelativeAccuracy)
+		for _, v := range values {
+			s.Add(v)
+		}
+		return &models.Metrics{ID: "latency", MType: models.Summary, Summary: s}
+	}
+
+	first := summary(1)
+	b.add([]*models.Metrics{first})
+	b.add([]*models.Metrics{summary(2, 3)})
+
+	batch := b.drain()
+	require.Len(t, batch, 1)
+	assert.Equal(t, uint64(3), batch[0].Summary.Count)
+	assert.Equal(t, 6.0, batch[0].Summary.Sum)
+	assert.Equal(t, uint64(1), first.Summary.Count, "merging must not modify collected samples")
+
+	b.add([]*models.Metrics{summary(4)})
+	b.restoreDeltas(batch)
+
+	batch = b.drain()
+	require.Len(t, batch, 1)
+	assert.Equal(t, uint64(4), batch[0].Summary.Count)
+	assert.Equal(t, 4.0, batch[0].Summary.Max)
+}
+
+func TestMetricBuffer_Sets(t *testing.T) {
+	b := newMetricBuffer()
+
+	sketch := func(members ...string) *hyperloglog.Sketch {
+		s, _ := hyperloglog.New(hyperloglog.DefaultPrecision)
+		for _, m := range members {
+			s.Add(m)
+		}
+		return s
+	}
+
+	first := &models.Metrics{ID: "users", MType: models.Set, Members: []string{"alice"}}
+	b.add([]*models.Metrics{first})
+	b.add([]*models.Metrics{{ID: "users", MType: models.Set, Members: []string{"bob"}, Set: sketch("carol")}})
+	b.add([]*models.Metrics{{ID: "users", MType: models.Set, Set: sketch("dave")}})
+
+	batch := b.drain()
+	require.Len(t, batch, 1)
+	assert.Equal(t, []string{"alice", "bob"}, batch[0].Members)
+	assert.Equal(t, uint64(2), batch[0].Set.Count())
+	assert.Equal(t, []string{"alice"}, first.Members, "merging must not modify collected samples")
+
+	b.add([]*models.Metrics{{ID: "users", MType: models.Set, Members: []string{"erin"}}})
+	b.restoreDeltas(batch)
+
+	batch = b.drain()
+	require.Len(t, batch, 1)
+	assert.Equal(t, []string{"erin", "alice", "bob"}, batch[0].Members)
+	assert.Equal(t, uint64(2), batch[0].Set.Count())
+}
+
+func TestMetricAgentWorker_ReportsCollectedMetrics(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var (
+		mu        sync.Mutex
+		pollCount int64
+	)
+
+	mockUpdater := NewMockMetricUpdater(ctrl)
+	mockUpdater.EXPECT().
+		Updates(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, metrics []*models.Metrics) error {
+			mu.Lock()
+			defer mu.Unlock()
+			if m := findMetric(metrics, "PollCount"); m != nil {
+				pollCount += *m.Delta
+			}
+			return nil
+		}).
+		MinTimes(1)
+
+	var polls atomic.Int64
+	collect := func(ctx context.Context) ([]*models.Metrics, error) {
+		polls.Add(1)
+		return []*models.Metrics{counter("PollCount", 1)}, nil
+	}
+
+	w := NewMetricAgentWorker(
+		WithMetricAgentUpdater(mockUpdater),
+		WithMetricAgentCollector(collect, 5*time.Millisecond),
+		WithMetricAgentReportInterval(20*time.Millisecond),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	require.NoError(t, w.Start(ctx))
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, polls.Load(), pollCount, "every poll is reported exactly once, including the final flush")
+}
+
+func TestMetricAgentWorker_RateLimit(t *testing.T) {
+	const limit = 2
+
+	var inFlight, maxInFlight atomic.Int32
+
+	updater := updaterFunc(func(ctx context.Context, metrics []*models.Metrics) error {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			m := maxInFlight.Load()
+			if n <= m || maxInFlight.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(15 * time.Millisecond)
+		return nil
+	})
+
+	collect := func(ctx context.Context) ([]*models.Metrics, error) {
+		return []*models.Metrics{gauge("Alloc", 1)}, nil
+	}
+
+	w := NewMetricAgentWorker(
+		WithMetricAgentUpdater(updater),
+		WithMetricAgentCollector(collect, time.Millisecond),
+		WithMetricAgentReportInterval(time.Millisecond),
+		WithMetricAgentRateLimit(limit),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	require.NoError(t, w.Start(ctx))
+
+	assert.LessOrEqual(t, maxInFlight.Load(), int32(limit))
+	assert.Positive(t, maxInFlight.Load())
+}
+
+func TestMetricAgentWorker_FailedReportKeepsCounters(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		attempts int
+		reported int64
+	)
+
+	updater := updaterFunc(func(ctx context.Context, metrics []*models.Metrics) error {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts++
+		if attempts == 1 {
+			return errors.New("connection refused")
+		}
+		if m := findMetric(metrics, "PollCount"); m != nil {
+			reported += *m.Delta
+		}
+		return nil
+	})
+
+	var polls atomic.Int64
+	collect := func(ctx context.Context) ([]*models.Metrics, error) {
+		polls.Add(1)
+		return []*models.Metrics{counter("PollCount", 1)}, nil
+	}
+
+	w := NewMetricAgentWorker(
+		WithMetricAgentUpdater(updater),
+		WithMetricAgentCollector(collect, 5*time.Millisecond),
+		WithMetricAgentReportInterval(20*time.Millisecond),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	require.NoError(t, w.Start(ctx))
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, polls.Load(), reported)
+}
+
+func TestMetricAgentWorker_PartialReportKeepsPendingCounters(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		attempts int
+		reported = map[string]int64{}
+	)
+
+	updater := updaterFunc(func(ctx context.Context, metrics []*models.Metrics) error {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts++
+
+		// The first report is applied up to Alloc, Sys stays pending.
+		var pending []*models.Metrics
+		for _, m := range metrics {
+			if attempts == 1 && m.ID == "Sys" {
+				pending = append(pending, m)
+				continue
+			}
+			reported[m.ID] += *m.Delta
+		}
+		if len(pending) > 0 {
+			return &models.PartialUpdateError{Pending: pending, Err: errors.New("stream closed")}
+		}
+		return nil
+	})
+
+	var polls atomic.Int64
+	collect := func(ctx context.Context) ([]*models.Metrics, error) {
+		polls.Add(1)
+		return []*models.Metrics{counter("Alloc", 1), counter("Sys", 1)}, nil
+	}
+
+	w := NewMetricAgentWorker(
+		WithMetricAgentUpdater(updater),
+		WithMetricAgentCollector(collect, 5*time.Millisecond),
+		WithMetricAgentReportInterval(20*time.Millisecond),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	require.NoError(t, w.Start(ctx))
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, polls.Load(), reported["Alloc"], "applied counters are not sent twice")
+	assert.Equal(t, polls.Load(), reported["Sys"])
+}
+
+func TestMetricAgentWorker_ShutdownTimeoutBoundsDrain(t *testing.T) {
+	updater := updaterFunc(func(ctx context.Context, metrics []*models.Metrics) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	collect := func(ctx context.Context) ([]*models.Metrics, error) {
+		return []*models.Metrics{gauge("Alloc", 1)}, nil
+	}
+
+	w := NewMetricAgentWorker(
+		WithMetricAgentUpdater(updater),
+		WithMetricAgentCollector(collect, time.Millisecond),
+		WithMetricAgentReportInterval(time.Hour),
+		WithMetricAgentShutdownTimeout(20*time.Millisecond),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	done := make(chan error)
+	go func() { done <- w.Start(ctx) }()
+
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("worker did not stop after the shutdown timeout")
+	}
+}
+
+type updaterFunc func(ctx context.Context, metrics []*models.Metrics) error
+
+func (f updaterFunc) Updates(ctx context.Context, metrics []*models.Metrics) error {
+	return f(ctx, metrics)
+}
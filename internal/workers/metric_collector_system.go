@@ -0,0 +1,35 @@
+package workers
+
+import (
+	"errors"
+	"sync"
+)
+
+// errSystemMetricsUnsupported is returned on platforms without a system metrics source.
+var errSystemMetricsUnsupported = errors.New("system metrics are not supported on this platform")
+
+// SystemMetricsCollectorOpt is a functional option for configuring SystemMetricsCollector
+type SystemMetricsCollectorOpt func(*SystemMetricsCollector)
+
+// WithSystemMetricsProcPath sets the mount point of procfs, "/proc" by default
+func WithSystemMetricsProcPath(path string) SystemMetricsCollectorOpt {
+	return func(c *SystemMetricsCollector) {
+		c.procPath = path
+	}
+}
+
+// SystemMetricsCollector samples host memory and per-core CPU utilization.
+// CPU utilization is computed from the difference between two consecutive
+// samples, so the first sample reports memory only.
+type SystemMetricsCollector struct {
+	procPath string
+
+	mu      sync.Mutex
+	prevCPU []cpuTimes
+}
+
+// cpuTimes holds the busy and total jiffies of one core.
+type cpuTimes struct {
+	busy  uint64
+	total uint64
+}
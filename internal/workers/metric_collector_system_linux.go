@@ -0,0 +1,169 @@
+//go:build linux
+
+package workers
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/models"
+)
+
+// NewSystemMetricsCollector creates a collector reading from procfs.
+func NewSystemMetricsCollector(opts ...SystemMetricsCollectorOpt) (*SystemMetricsCollector, error) {
+	c := &SystemMetricsCollector{procPath: "/proc"}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// Collect reads /proc/meminfo and /proc/stat and returns TotalMemory,
+// FreeMemory and CPUutilization1..N gauges.
+func (c *SystemMetricsCollector) Collect(ctx context.Context) ([]*models.Metrics, error) {
+	total, free, err := c.readMemory()
+	if err != nil {
+		return nil, err
+	}
+
+	cores, err := c.readCPU()
+	if err != nil {
+		return nil, err
+	}
+
+	metrics := []*models.Metrics{
+		{ID: "TotalMemory", MType: models.Gauge, Value: &total},
+		{ID: "FreeMemory", MType: models.Gauge, Value: &free},
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.prevCPU) == len(cores) {
+		for i, cur := range cores {
+			utilization := cpuUtilization(c.prevCPU[i], cur)
+			metrics = append(metrics, &models.Metrics{
+				ID:    fmt.Sprintf("CPUutilization%d", i+1),
+				MType: models.Gauge,
+				Value: &utilization,
+			})
+		}
+	}
+	c.prevCPU = cores
+
+	return metrics, nil
+}
+
+func (c *SystemMetricsCollector) readMemory() (total, free float64, err error) {
+	f, err := os.Open(filepath.Join(c.procPath, "meminfo"))
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	return parseMeminfo(f)
+}
+
+func (c *SystemMetricsCollector) readCPU() ([]cpuTimes, error) {
+	f, err := os.Open(filepath.Join(c.procPath, "stat"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return parseCPUStat(f)
+}
+
+// parseMeminfo returns MemTotal and MemFree in bytes.
+func parseMeminfo(r io.Reader) (total, free float64, err error) {
+	var foundTotal, foundFree bool
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+
+		var dst *float64
+		switch fields[0] {
+		case "MemTotal:":
+			dst, foundTotal = &total, true
+		case "MemFree:":
+			dst, foundFree = &free, true
+		default:
+			continue
+		}
+
+		value, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("meminfo %s: %w", fields[0], err)
+		}
+		*dst = float64(value)
+		if len(fields) > 2 && fields[2] == "kB" {
+			*dst *= 1024
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, 0, err
+	}
+	if !foundTotal || !foundFree {
+		return 0, 0, fmt.Errorf("meminfo: MemTotal or MemFree is missing")
+	}
+
+	return total, free, nil
+}
+
+// parseCPUStat returns the jiffies of every "cpuN" line in core order.
+func parseCPUStat(r io.Reader) ([]cpuTimes, error) {
+	var cores []cpuTimes
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		// The aggregate "cpu" line is skipped, only per-core lines are used.
+		if len(fields) < 5 || !strings.HasPrefix(fields[0], "cpu") || fields[0] == "cpu" {
+			continue
+		}
+
+		var times cpuTimes
+		for i, field := range fields[1:] {
+			// guest and guest_nice are already included in user and nice.
+			if i >= 8 {
+				break
+			}
+			value, err := strconv.ParseUint(field, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("stat %s: %w", fields[0], err)
+			}
+			times.total += value
+			// idle and iowait are the only non-busy states.
+			if i != 3 && i != 4 {
+				times.busy += value
+			}
+		}
+		cores = append(cores, times)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(cores) == 0 {
+		return nil, fmt.Errorf("stat: no per-core cpu lines")
+	}
+
+	return cores, nil
+}
+
+// cpuUtilization returns the busy share of a core between two samples in percent.
+func cpuUtilization(prev, cur cpuTimes) float64 {
+	if cur.total <= prev.total || cur.busy < prev.busy {
+		return 0
+	}
+	return float64(cur.busy-prev.busy) / float64(cur.total-prev.total) * 100
+}
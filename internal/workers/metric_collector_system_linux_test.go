@@ -0,0 +1,106 @@
+//go:build linux
+
+package workers
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testMeminfo = `MemTotal:       16000000 kB
+MemFree:         4000000 kB
+MemAvailable:    8000000 kB
+`
+
+func writeProcFiles(t *testing.T, dir, stat string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "meminfo"), []byte(testMeminfo), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "stat"), []byte(stat), 0o644))
+}
+
+func TestSystemMetricsCollector_Collect(t *testing.T) {
+	dir := t.TempDir()
+	collector, err := NewSystemMetricsCollector(WithSystemMetricsProcPath(dir))
+	require.NoError(t, err)
+
+	writeProcFiles(t, dir, `cpu  200 0 200 1600 0 0 0 0 0 0
+cpu0 100 0 100 800 0 0 0 0 0 0
+cpu1 100 0 100 800 0 0 0 0 0 0
+intr 12345
+`)
+
+	metrics, err := collector.Collect(context.Background())
+	require.NoError(t, err)
+	require.Len(t, metrics, 2, "first sample has no cpu baseline")
+	assert.Equal(t, 16000000.0*1024, *findMetric(metrics, "TotalMemory").Value)
+	assert.Equal(t, 4000000.0*1024, *findMetric(metrics, "FreeMemory").Value)
+
+	// cpu0 is busy for 50 of 100 jiffies, cpu1 spends all of them idle or in iowait.
+	writeProcFiles(t, dir, `cpu  250 0 250 1700 50 0 0 0 0 0
+cpu0 125 0 125 850 0 0 0 0 0 0
+cpu1 100 0 100 850 50 0 0 0 0 0
+`)
+
+	metrics, err = collector.Collect(context.Background())
+	require.NoError(t, err)
+	require.Len(t, metrics, 4)
+	assert.InDelta(t, 50.0, *findMetric(metrics, "CPUutilization1").Value, 1e-9)
+	assert.InDelta(t, 0.0, *findMetric(metrics, "CPUutilization2").Value, 1e-9)
+}
+
+func TestSystemMetricsCollector_Collect_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		meminfo string
+		stat    string
+	}{
+		{name: "missing files"},
+		{name: "missing MemFree", meminfo: "MemTotal: 1 kB\n", stat: "cpu0 1 1 1 1 1\n"},
+		{name: "malformed meminfo", meminfo: "MemTotal: x kB\nMemFree: 1 kB\n", stat: "cpu0 1 1 1 1 1\n"},
+		{name: "no cores", meminfo: testMeminfo, stat: "cpu 1 1 1 1 1\n"},
+		{name: "malformed stat", meminfo: testMeminfo, stat: "cpu0 1 x 1 1 1\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tt.meminfo != "" {
+				require.NoError(t, os.WriteFile(filepath.Join(dir, "meminfo"), []byte(tt.meminfo), 0o644))
+			}
+			if tt.stat != "" {
+				require.NoError(t, os.WriteFile(filepath.Join(dir, "stat"), []byte(tt.stat), 0o644))
+			}
+
+			collector, err := NewSystemMetricsCollector(WithSystemMetricsProcPath(dir))
+			require.NoError(t, err)
+
+			_, err = collector.Collect(context.Background())
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestSystemMetricsCollector_CollectHost(t *testing.T) {
+	collector, err := NewSystemMetricsCollector()
+	require.NoError(t, err)
+
+	metrics, err := collector.Collect(context.Background())
+	require.NoError(t, err)
+	assert.NotNil(t, findMetric(metrics, "TotalMemory"))
+
+	metrics, err = collector.Collect(context.Background())
+	require.NoError(t, err)
+	for _, m := range metrics {
+		if strings.HasPrefix(m.ID, "CPUutilization") {
+			assert.GreaterOrEqual(t, *m.Value, 0.0)
+			assert.LessOrEqual(t, *m.Value, 100.0)
+		}
+	}
+	assert.NotNil(t, findMetric(metrics, "CPUutilization1"))
+}
@@ -0,0 +1,19 @@
+//go:build !linux
+
+package workers
+
+import (
+	"context"
+
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/models"
+)
+
+// NewSystemMetricsCollector reports that system metrics are unavailable on this platform.
+func NewSystemMetricsCollector(opts ...SystemMetricsCollectorOpt) (*SystemMetricsCollector, error) {
+	return nil, errSystemMetricsUnsupported
+}
+
+// Collect is never called on this platform since no collector can be created.
+func (c *SystemMetricsCollector) Collect(ctx context.Context) ([]*models.Metrics, error) {
+	return nil, errSystemMetricsUnsupported
+}
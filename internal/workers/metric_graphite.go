@@ -0,0 +1,225 @@
+package workers
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/graphite"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/logger"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/models"
+)
+
+// MetricGraphiteWorkerOpt is a functional option for configuring MetricGraphiteWorker
+type MetricGraphiteWorkerOpt func(*MetricGraphiteWorker)
+
+// WithMetricGraphiteAddress sets the TCP address host:port to listen on
+func WithMetricGraphiteAddress(addr string) MetricGraphiteWorkerOpt {
+	return func(w *MetricGraphiteWorker) {
+		w.address = addr
+	}
+}
+
+// WithMetricGraphiteListener sets an already bound listener to accept connections from instead of listening on the address
+func WithMetricGraphiteListener(lis net.Listener) MetricGraphiteWorkerOpt {
+	return func(w *MetricGraphiteWorker) {
+		w.listener = lis
+	}
+}
+
+// WithMetricGraphiteRules sets the rules deciding which paths are counters
+func WithMetricGraphiteRules(rules *graphite.Rules) MetricGraphiteWorkerOpt {
+	return func(w *MetricGraphiteWorker) {
+		w.rules = rules
+	}
+}
+
+// WithMetricGraphiteUpdater sets the service storing received metrics
+func WithMetricGraphiteUpdater(updater MetricBatchUpdater) MetricGraphiteWorkerOpt {
+	return func(w *MetricGraphiteWorker) {
+		w.updater = updater
+	}
+}
+
+// WithMetricGraphiteTrustedSubnet refuses connections from outside subnet, nil accepts every sender
+func WithMetricGraphiteTrustedSubnet(subnet *net.IPNet) MetricGraphiteWorkerOpt {
+	return func(w *MetricGraphiteWorker) {
+		w.trustedSubnet = subnet
+	}
+}
+
+// maxGraphiteBatchSize bounds how many lines are stored in a single batch.
+const maxGraphiteBatchSize = 1000
+
+// maxGraphiteLineSize bounds a single line, a longer one closes the connection.
+const maxGraphiteLineSize = 64 * 1024
+
+// MetricGraphiteWorker accepts Graphite plaintext connections over TCP.
+type MetricGraphiteWorker struct {
+	address       string
+	listener      net.Listener
+	rules         *graphite.Rules
+	updater       MetricBatchUpdater
+	trustedSubnet *net.IPNet
+}
+
+func NewMetricGraphiteWorker(opts ...MetricGraphiteWorkerOpt) *MetricGraphiteWorker {
+	w := &MetricGraphiteWorker{}
+	for _, opt := range opts {
+		opt(w)
+	}
+	if w.rules == nil {
+		w.rules, _ = graphite.NewRules()
+	}
+	return w
+}
+
+// Start accepts connections until ctx is cancelled, then closes the listener
+// and every open connection and waits for their pending batches to be stored.
+func (w *MetricGraphiteWorker) Start(ctx context.Context) error {
+	lis := w.listener
+	if lis == nil {
+		var err error
+		lis, err = net.Listen("tcp", w.address)
+		if err != nil {
+			return err
+		}
+	}
+
+	logger.Log.Infow("starting Graphite listener", "address", lis.Addr().String())
+
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		conns = make(map[net.Conn]struct{})
+	)
+
+	stopped := context.AfterFunc(ctx, func() {
+		lis.Close()
+
+		mu.Lock()
+		for conn := range conns {
+			conn.Close()
+		}
+		mu.Unlock()
+	})
+	defer stopped()
+
+	for {
+		conn, err := lis.Accept()
+		if err != nil {
+			wg.Wait()
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		if !trusted(w.trustedSubnet, conn.RemoteAddr()) {
+			logger.Log.Debugw("refusing Graphite connection from untrusted sender", "remote", conn.RemoteAddr().String())
+			conn.Close()
+			continue
+		}
+
+		mu.Lock()
+		if ctx.Err() != nil {
+			mu.Unlock()
+			conn.Close()
+			continue
+		}
+		conns[conn] = struct{}{}
+		mu.Unlock()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				mu.Lock()
+				delete(conns, conn)
+				mu.Unlock()
+				conn.Close()
+			}()
+
+			w.serve(context.WithoutCancel(ctx), conn)
+		}()
+	}
+}
+
+// serve reads lines until the connection is closed. Lines are stored in
+// batches: whatever has arrived is stored before waiting for more input, so a
+// slow repository stops the connection from being read. Malformed lines are
+// logged and skipped, a line over maxGraphiteLineSize closes the connection.
+func (w *MetricGraphiteWorker) serve(ctx context.Context, conn net.Conn) {
+	batch := make([]*models.Metrics, 0, maxGraphiteBatchSize)
+
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if _, err := w.updater.Update(ctx, batch); err != nil {
+			logger.Log.Errorw("failed to store Graphite metrics",
+				"remote", conn.RemoteAddr().String(),
+				"count", len(batch),
+				"error", err,
+			)
+		}
+		batch = batch[:0]
+	}
+	defer flush()
+
+	// The scanner only reads once its buffer holds no complete line. A line
+	// cut short by shutdown or a broken connection is never returned, only a
+	// sender closing its side ends the last line.
+	scanner := bufio.NewScanner(readerFunc(func(p []byte) (int, error) {
+		flush()
+		return conn.Read(p)
+	}))
+	scanner.Buffer(make([]byte, 0, 4096), maxGraphiteLineSize)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		metric, err := w.parse(line)
+		if err != nil {
+			logger.Log.Debugw("skipping Graphite line",
+				"remote", conn.RemoteAddr().String(),
+				"line", line,
+				"error", err,
+			)
+			continue
+		}
+
+		batch = append(batch, metric)
+		if len(batch) >= maxGraphiteBatchSize {
+			flush()
+		}
+	}
+
+	if err := scanner.Err(); err != nil && !errors.Is(err, net.ErrClosed) {
+		logger.Log.Warnw("failed to read Graphite connection",
+			"remote", conn.RemoteAddr().String(),
+			"error", err,
+		)
+	}
+}
+
+// readerFunc adapts a function to io.Reader.
+type readerFunc func(p []byte) (int, error)
+
+func (f readerFunc) Read(p []byte) (int, error) {
+	return f(p)
+}
+
+func (w *MetricGraphiteWorker) parse(text string) (*models.Metrics, error) {
+	line, err := graphite.ParseLine(text)
+	if err != nil {
+		return nil, err
+	}
+	return w.rules.Metric(line)
+}
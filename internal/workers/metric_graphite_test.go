@@ -0,0 +1,206 @@
+package workers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/graphite"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/models"
+)
+
+// startGraphiteWorker runs the worker on a loopback listener until the test ends.
+func startGraphiteWorker(t *testing.T, updater MetricBatchUpdater, rules *graphite.Rules, opts ...MetricGraphiteWorkerOpt) (string, context.CancelFunc, <-chan error) {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	w := NewMetricGraphiteWorker(append([]MetricGraphiteWorkerOpt{
+		WithMetricGraphiteListener(lis),
+		WithMetricGraphiteRules(rules),
+		WithMetricGraphiteUpdater(updater),
+	}, opts...)...)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- w.Start(ctx)
+	}()
+	t.Cleanup(cancel)
+
+	return lis.Addr().String(), cancel, done
+}
+
+func TestMetricGraphiteWorker(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var (
+		mu       sync.Mutex
+		received []*models.Metrics
+	)
+	mockUpdater := NewMockMetricBatchUpdater(ctrl)
+	mockUpdater.EXPECT().
+		Update(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, metrics []*models.Metrics) ([]*models.Metrics, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			for _, m := range metrics {
+				copied := *m
+				received = append(received, &copied)
+			}
+			return metrics, nil
+		}).
+		AnyTimes()
+
+	rules, err := graphite.NewRules("stats.counters")
+	require.NoError(t, err)
+
+	addr, cancel, done := startGraphiteWorker(t, mockUpdater, rules)
+
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+
+	_, err = conn.Write([]byte("servers.web1.load 0.5 1700000000\nbroken line\nstats.counters.hits 3 1700000000\nstats.counters.hits 2 -1"))
+	require.NoError(t, err)
+	// Closing the connection ends the last, unterminated line.
+	require.NoError(t, conn.Close())
+
+	hits, load := int64(3), 0.5
+	lastHits := int64(2)
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(received) == 3
+	}, 2*time.Second, 10*time.Millisecond)
+
+	mu.Lock()
+	assert.Equal(t, []*models.Metrics{
+		{ID: "servers.web1.load", MType: models.Gauge, Value: &load},
+		{ID: "stats.counters.hits", MType: models.Counter, Delta: &hits},
+		{ID: "stats.counters.hits", MType: models.Counter, Delta: &lastHits},
+	}, received)
+	mu.Unlock()
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestMetricGraphiteWorker_ShutdownClosesConnections(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	stored := make(chan []*models.Metrics, 1)
+	mockUpdater := NewMockMetricBatchUpdater(ctrl)
+	mockUpdater.EXPECT().
+		Update(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, metrics []*models.Metrics) ([]*models.Metrics, error) {
+			stored <- metrics
+			return nil, errors.New("db down")
+		})
+
+	addr, cancel, done := startGraphiteWorker(t, mockUpdater, nil)
+
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("a.b 1 1700000000\n"))
+	require.NoError(t, err)
+
+	select {
+	case metrics := <-stored:
+		require.Len(t, metrics, 1)
+		assert.Equal(t, models.Gauge, metrics[0].MType)
+	case <-time.After(2 * time.Second):
+		t.Fatal("metrics were not stored")
+	}
+
+	// The connection is still open, stopping must not wait for the sender.
+	cancel()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("worker did not stop")
+	}
+}
+
+func TestMetricGraphiteWorker_LongLineClosesConnection(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	stored := make(chan []*models.Metrics, 1)
+	mockUpdater := NewMockMetricBatchUpdater(ctrl)
+	mockUpdater.EXPECT().
+		Update(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, metrics []*models.Metrics) ([]*models.Metrics, error) {
+			stored <- metrics
+			return metrics, nil
+		})
+
+	addr, _, _ := startGraphiteWorker(t, mockUpdater, nil)
+
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write(append([]byte("a.b 1 1700000000\n"), bytes.Repeat([]byte("x"), maxGraphiteLineSize+1)...))
+	require.NoError(t, err)
+
+	select {
+	case metrics := <-stored:
+		require.Len(t, metrics, 1)
+	case <-time.After(2 * time.Second):
+		t.Fatal("metrics before the long line were not stored")
+	}
+
+	assertClosedByServer(t, conn)
+}
+
+func TestMetricGraphiteWorker_RefusesUntrustedConnections(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	_, subnet, err := net.ParseCIDR("10.0.0.0/8")
+	require.NoError(t, err)
+
+	// No Update is expected, the only connection comes from loopback.
+	addr, _, _ := startGraphiteWorker(t, NewMockMetricBatchUpdater(ctrl), nil, WithMetricGraphiteTrustedSubnet(subnet))
+
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, _ = conn.Write([]byte("a.b 1 1700000000\n"))
+
+	assertClosedByServer(t, conn)
+}
+
+// assertClosedByServer expects the server to close conn, with an EOF or a
+// reset depending on whether it left unread input behind.
+func assertClosedByServer(t *testing.T, conn net.Conn) {
+	t.Helper()
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	_, err := conn.Read(make([]byte, 1))
+	require.Error(t, err)
+
+	var netErr net.Error
+	assert.False(t, errors.As(err, &netErr) && netErr.Timeout(), "the connection is still open")
+}
+
+func TestMetricGraphiteWorker_ListenError(t *testing.T) {
+	w := NewMetricGraphiteWorker(WithMetricGraphiteAddress("invalid_addr"))
+
+	assert.Error(t, w.Start(context.Background()))
+}
@@ -0,0 +1,70 @@
+package workers
+
+import (
+	"context"
+	"time"
+
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/logger"
+)
+
+// MetricDumper defines an interface for persisting a snapshot of stored metrics.
+type MetricDumper interface {
+	Dump(ctx context.Context) error
+}
+
+// MetricSnapshotWorkerOpt is a functional option for configuring MetricSnapshotWorker
+type MetricSnapshotWorkerOpt func(*MetricSnapshotWorker)
+
+// WithMetricSnapshotDumper sets the dumper used to persist snapshots
+func WithMetricSnapshotDumper(dumper MetricDumper) MetricSnapshotWorkerOpt {
+	return func(w *MetricSnapshotWorker) {
+		w.dumper = dumper
+	}
+}
+
+// WithMetricSnapshotInterval sets how often snapshots are taken, 0 disables periodic snapshots
+func WithMetricSnapshotInterval(interval time.Duration) MetricSnapshotWorkerOpt {
+	return func(w *MetricSnapshotWorker) {
+		w.interval = interval
+	}
+}
+
+// MetricSnapshotWorker periodically persists stored metrics and flushes them
+// one last time when stopped.
+type MetricSnapshotWorker struct {
+	dumper   MetricDumper
+	interval time.Duration
+}
+
+func NewMetricSnapshotWorker(opts ...MetricSnapshotWorkerOpt) *MetricSnapshotWorker {
+	w := &MetricSnapshotWorker{}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
+}
+
+// Start dumps a snapshot every interval until ctx is cancelled, then performs
+// a final dump. A failed periodic dump is logged and retried on the next tick,
+// only the error of the final dump is returned.
+func (w *MetricSnapshotWorker) Start(ctx context.Context) error {
+	if w.interval > 0 {
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return w.dumper.Dump(context.WithoutCancel(ctx))
+			case <-ticker.C:
+				if err := w.dumper.Dump(ctx); err != nil {
+					logger.Log.Errorw("failed to dump metrics snapshot", "error", err)
+				}
+			}
+		}
+	}
+
+	<-ctx.Done()
+
+	return w.dumper.Dump(context.WithoutCancel(ctx))
+}
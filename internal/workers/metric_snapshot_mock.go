@@ -0,0 +1,49 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: /home/sergey/Github/go-yandex-practicum-metric/internal/workers/metric_snapshot.go
+
+// Package workers is a generated GoMock package.
+package workers
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockMetricDumper is a mock of MetricDumper interface.
+type MockMetricDumper struct {
+	ctrl     *gomock.Controller
+	recorder *MockMetricDumperMockRecorder
+}
+
+// MockMetricDumperMockRecorder is the mock recorder for MockMetricDumper.
+type MockMetricDumperMockRecorder struct {
+	mock *MockMetricDumper
+}
+
+// NewMockMetricDumper creates a new mock instance.
+func NewMockMetricDumper(ctrl *gomock.Controller) *MockMetricDumper {
+	mock := &MockMetricDumper{ctrl: ctrl}
+	mock.recorder = &MockMetricDumperMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockMetricDumper) EXPECT() *MockMetricDumperMockRecorder {
+	return m.recorder
+}
+
+// Dump mocks base method.
+func (m *MockMetricDumper) Dump(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Dump", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Dump indicates an expected call of Dump.
+func (mr *MockMetricDumperMockRecorder) Dump(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Dump", reflect.TypeOf((*MockMetricDumper)(nil).Dump), ctx)
+}
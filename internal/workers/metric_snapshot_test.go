@@ -0,0 +1,90 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricSnapshotWorker_PeriodicAndFinalDump(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDumper := NewMockMetricDumper(ctrl)
+	mockDumper.EXPECT().Dump(gomock.Any()).Return(nil).MinTimes(2)
+
+	w := NewMetricSnapshotWorker(
+		WithMetricSnapshotDumper(mockDumper),
+		WithMetricSnapshotInterval(20*time.Millisecond),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	assert.NoError(t, w.Start(ctx))
+}
+
+func TestMetricSnapshotWorker_FinalDumpOnlyWhenSynchronous(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDumper := NewMockMetricDumper(ctrl)
+	mockDumper.EXPECT().Dump(gomock.Any()).Return(nil).Times(1)
+
+	w := NewMetricSnapshotWorker(WithMetricSnapshotDumper(mockDumper))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	assert.NoError(t, w.Start(ctx))
+}
+
+func TestMetricSnapshotWorker_PeriodicDumpErrorKeepsTicking(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	calls := 0
+	mockDumper := NewMockMetricDumper(ctrl)
+	mockDumper.EXPECT().
+		Dump(gomock.Any()).
+		DoAndReturn(func(context.Context) error {
+			calls++
+			if calls < 3 {
+				if calls == 2 {
+					cancel()
+				}
+				return errors.New("disk full")
+			}
+			return nil
+		}).
+		MinTimes(3)
+
+	w := NewMetricSnapshotWorker(
+		WithMetricSnapshotDumper(mockDumper),
+		WithMetricSnapshotInterval(10*time.Millisecond),
+	)
+
+	assert.NoError(t, w.Start(ctx))
+}
+
+func TestMetricSnapshotWorker_FinalDumpError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockDumper := NewMockMetricDumper(ctrl)
+	mockDumper.EXPECT().Dump(gomock.Any()).Return(errors.New("disk full"))
+
+	w := NewMetricSnapshotWorker(WithMetricSnapshotDumper(mockDumper))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	assert.Error(t, w.Start(ctx))
+}
@@ -0,0 +1,228 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/logger"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/models"
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/statsd"
+)
+
+// MetricBatchUpdater defines an interface for applying a batch of metrics on the server.
+type MetricBatchUpdater interface {
+	Update(ctx context.Context, metrics []*models.Metrics) ([]*models.Metrics, error)
+}
+
+// MetricGetter defines an interface for reading a single stored metric.
+type MetricGetter interface {
+	Get(ctx context.Context, id models.MetricID) (*models.Metrics, error)
+}
+
+// MetricStatsDWorkerOpt is a functional option for configuring MetricStatsDWorker
+type MetricStatsDWorkerOpt func(*MetricStatsDWorker)
+
+// WithMetricStatsDAddress sets the UDP address host:port to listen on
+func WithMetricStatsDAddress(addr string) MetricStatsDWorkerOpt {
+	return func(w *MetricStatsDWorker) {
+		w.address = addr
+	}
+}
+
+// WithMetricStatsDConn sets an already bound connection to read from instead of listening on the address
+func WithMetricStatsDConn(conn net.PacketConn) MetricStatsDWorkerOpt {
+	return func(w *MetricStatsDWorker) {
+		w.conn = conn
+	}
+}
+
+// WithMetricStatsDFlushInterval sets how long samples are aggregated before being stored
+func WithMetricStatsDFlushInterval(interval time.Duration) MetricStatsDWorkerOpt {
+	return func(w *MetricStatsDWorker) {
+		w.flushInterval = interval
+	}
+}
+
+// WithMetricStatsDUpdater sets the service applying flushed batches
+func WithMetricStatsDUpdater(updater MetricBatchUpdater) MetricStatsDWorkerOpt {
+	return func(w *MetricStatsDWorker) {
+		w.updater = updater
+	}
+}
+
+// WithMetricStatsDGetter sets the repository used to resolve relative gauges
+func WithMetricStatsDGetter(getter MetricGetter) MetricStatsDWorkerOpt {
+	return func(w *MetricStatsDWorker) {
+		w.getter = getter
+	}
+}
+
+// WithMetricStatsDTrustedSubnet drops packets sent from outside subnet, nil accepts every sender
+func WithMetricStatsDTrustedSubnet(subnet *net.IPNet) MetricStatsDWorkerOpt {
+	return func(w *MetricStatsDWorker) {
+		w.trustedSubnet = subnet
+	}
+}
+
+// defaultStatsDFlushInterval is the aggregation window used unless configured otherwise.
+const defaultStatsDFlushInterval = time.Second
+
+// maxStatsDPacketSize is the largest UDP payload.
+const maxStatsDPacketSize = 65535
+
+// MetricStatsDWorker receives StatsD packets over UDP and stores the samples
+// aggregated over each flush window as a single batch.
+type MetricStatsDWorker struct {
+	address       string
+	conn          net.PacketConn
+	flushInterval time.Duration
+	updater       MetricBatchUpdater
+	getter        MetricGetter
+	trustedSubnet *net.IPNet
+
+	mu         sync.Mutex
+	aggregator *statsd.Aggregator
+}
+
+func NewMetricStatsDWorker(opts ...MetricStatsDWorkerOpt) *MetricStatsDWorker {
+	w := &MetricStatsDWorker{
+		flushInterval: defaultStatsDFlushInterval,
+		aggregator:    statsd.NewAggregator(),
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
+}
+
+// Start listens until ctx is cancelled, then closes the socket and flushes the
+// last window. Failing to store a window is logged and the window dropped,
+// StatsD senders do not expect delivery guarantees.
+func (w *MetricStatsDWorker) Start(ctx context.Context) error {
+	conn := w.conn
+	if conn == nil {
+		var err error
+		conn, err = net.ListenPacket("udp", w.address)
+		if err != nil {
+			return err
+		}
+	}
+
+	logger.Log.Infow("starting StatsD listener", "address", conn.LocalAddr().String())
+
+	readDone := make(chan struct{})
+	go func() {
+		defer close(readDone)
+		w.read(conn)
+	}()
+
+	ticker := time.NewTicker(w.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+			<-readDone
+			w.flush(context.WithoutCancel(ctx))
+			return nil
+		case <-ticker.C:
+			w.flush(ctx)
+		}
+	}
+}
+
+// read folds incoming packets into the current window until conn is closed.
+func (w *MetricStatsDWorker) read(conn net.PacketConn) {
+	buf := make([]byte, maxStatsDPacketSize)
+
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			logger.Log.Warnw("failed to read StatsD packet", "error", err)
+			continue
+		}
+
+		if !trusted(w.trustedSubnet, addr) {
+			logger.Log.Debugw("dropping StatsD packet from untrusted sender", "address", addr.String())
+			continue
+		}
+
+		samples, errs := statsd.Parse(buf[:n])
+		for _, err := range errs {
+			logger.Log.Debugw("skipping StatsD line", "error", err)
+		}
+
+		w.mu.Lock()
+		for _, sample := range samples {
+			w.aggregator.Add(sample)
+		}
+		w.mu.Unlock()
+	}
+}
+
+func (w *MetricStatsDWorker) flush(ctx context.Context) {
+	w.mu.Lock()
+	batch := w.aggregator.Flush()
+	w.mu.Unlock()
+
+	metrics := make([]*models.Metrics, 0, len(batch.Counters)+len(batch.Gauges))
+
+	for name, delta := range batch.Counters {
+		metrics = append(metrics, &models.Metrics{ID: name, MType: models.Counter, Delta: &delta})
+	}
+
+	for name, gauge := range batch.Gauges {
+		value := gauge.Value
+		if gauge.Relative {
+			stored, err := w.getter.Get(ctx, models.MetricID{ID: name, MType: models.Gauge})
+			if err != nil {
+				logger.Log.Errorw("failed to resolve relative StatsD gauge", "id", name, "error", err)
+				continue
+			}
+			if stored != nil && stored.Value != nil {
+				value += *stored.Value
+			}
+		}
+		metrics = append(metrics, &models.Metrics{ID: name, MType: models.Gauge, Value: &value})
+	}
+
+	if len(metrics) == 0 {
+		return
+	}
+
+	sort.Slice(metrics, func(i, j int) bool {
+		if metrics[i].ID != metrics[j].ID {
+			return metrics[i].ID < metrics[j].ID
+		}
+		return metrics[i].MType < metrics[j].MType
+	})
+
+	if _, err := w.updater.Update(ctx, metrics); err != nil {
+		logger.Log.Errorw("failed to store StatsD metrics",
+			"count", len(metrics),
+			"error", err,
+		)
+	}
+}
+
+// trusted reports whether addr belongs to subnet, a nil subnet trusts every
+// address.
+func trusted(subnet *net.IPNet, addr net.Addr) bool {
+	if subnet == nil {
+		return true
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && subnet.Contains(ip)
+}
@@ -0,0 +1,190 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sbilibin2017/go-yandex-practicum-metric/internal/models"
+)
+
+func TestMetricStatsDWorker(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	mockUpdater := NewMockMetricBatchUpdater(ctrl)
+	mockGetter := NewMockMetricGetter(ctrl)
+
+	stored := 10.0
+	mockGetter.EXPECT().
+		Get(gomock.Any(), models.MetricID{ID: "queue", MType: models.Gauge}).
+		Return(&models.Metrics{ID: "queue", MType: models.Gauge, Value: &stored}, nil)
+
+	flushed := make(chan []*models.Metrics, 1)
+	mockUpdater.EXPECT().
+		Update(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, metrics []*models.Metrics) ([]*models.Metrics, error) {
+			flushed <- metrics
+			return metrics, nil
+		})
+
+	w := NewMetricStatsDWorker(
+		WithMetricStatsDConn(conn),
+		WithMetricStatsDFlushInterval(20*time.Millisecond),
+		WithMetricStatsDUpdater(mockUpdater),
+		WithMetricStatsDGetter(mockGetter),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- w.Start(ctx)
+	}()
+
+	client, err := net.Dial("udp", conn.LocalAddr().String())
+	require.NoError(t, err)
+	defer client.Close()
+
+	// A single datagram is aggregated as a whole, so it lands in one window.
+	_, err = client.Write([]byte("hits:1|c\nhits:1|c|@0.5\nqueue:+5|g\nload:0.5|g\nlatency:12|ms\nbroken\n"))
+	require.NoError(t, err)
+
+	select {
+	case metrics := <-flushed:
+		hits, queue, load := int64(3), 15.0, 0.5
+		assert.Equal(t, []*models.Metrics{
+			{ID: "hits", MType: models.Counter, Delta: &hits},
+			{ID: "load", MType: models.Gauge, Value: &load},
+			{ID: "queue", MType: models.Gauge, Value: &queue},
+		}, metrics)
+	case <-time.After(2 * time.Second):
+		t.Fatal("metrics were not flushed")
+	}
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestMetricStatsDWorker_UpdateErrorKeepsListening(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	mockUpdater := NewMockMetricBatchUpdater(ctrl)
+
+	calls := make(chan struct{}, 2)
+	mockUpdater.EXPECT().
+		Update(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, metrics []*models.Metrics) ([]*models.Metrics, error) {
+			calls <- struct{}{}
+			return nil, errors.New("db down")
+		}).
+		Times(2)
+
+	w := NewMetricStatsDWorker(
+		WithMetricStatsDConn(conn),
+		WithMetricStatsDFlushInterval(20*time.Millisecond),
+		WithMetricStatsDUpdater(mockUpdater),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- w.Start(ctx)
+	}()
+
+	client, err := net.Dial("udp", conn.LocalAddr().String())
+	require.NoError(t, err)
+	defer client.Close()
+
+	for i := 0; i < 2; i++ {
+		_, err = client.Write([]byte("hits:1|c"))
+		require.NoError(t, err)
+
+		select {
+		case <-calls:
+		case <-time.After(2 * time.Second):
+			t.Fatal("metrics were not flushed")
+		}
+	}
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestMetricStatsDWorker_ListenError(t *testing.T) {
+	w := NewMetricStatsDWorker(WithMetricStatsDAddress("invalid_addr"))
+
+	assert.Error(t, w.Start(context.Background()))
+}
+
+func TestMetricStatsDWorker_DropsUntrustedPackets(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	_, subnet, err := net.ParseCIDR("10.0.0.0/8")
+	require.NoError(t, err)
+
+	// No Update is expected, the only packet comes from loopback.
+	w := NewMetricStatsDWorker(
+		WithMetricStatsDConn(conn),
+		WithMetricStatsDFlushInterval(10*time.Millisecond),
+		WithMetricStatsDUpdater(NewMockMetricBatchUpdater(ctrl)),
+		WithMetricStatsDTrustedSubnet(subnet),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- w.Start(ctx)
+	}()
+
+	client, err := net.Dial("udp", conn.LocalAddr().String())
+	require.NoError(t, err)
+	defer client.Close()
+
+	_, err = client.Write([]byte("hits:1|c"))
+	require.NoError(t, err)
+
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestTrusted(t *testing.T) {
+	_, subnet, err := net.ParseCIDR("10.0.0.0/8")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		subnet   *net.IPNet
+		addr     net.Addr
+		expected bool
+	}{
+		{name: "no subnet", addr: &net.UDPAddr{IP: net.ParseIP("192.168.0.1"), Port: 1}, expected: true},
+		{name: "udp inside", subnet: subnet, addr: &net.UDPAddr{IP: net.ParseIP("10.1.2.3"), Port: 1}, expected: true},
+		{name: "tcp inside", subnet: subnet, addr: &net.TCPAddr{IP: net.ParseIP("10.1.2.3"), Port: 1}, expected: true},
+		{name: "outside", subnet: subnet, addr: &net.UDPAddr{IP: net.ParseIP("192.168.0.1"), Port: 1}},
+		{name: "ipv6 outside", subnet: subnet, addr: &net.TCPAddr{IP: net.ParseIP("::1"), Port: 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, trusted(tt.subnet, tt.addr))
+		})
+	}
+}
@@ -0,0 +1,11 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS metrics (
+    id    TEXT             NOT NULL,
+    type  TEXT             NOT NULL,
+    delta BIGINT,
+    value DOUBLE PRECISION,
+    PRIMARY KEY (id, type)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS metrics;
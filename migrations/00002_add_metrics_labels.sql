@@ -0,0 +1,10 @@
+-- +goose Up
+ALTER TABLE metrics ADD COLUMN labels TEXT NOT NULL DEFAULT '';
+ALTER TABLE metrics DROP CONSTRAINT metrics_pkey;
+ALTER TABLE metrics ADD PRIMARY KEY (id, type, labels);
+
+-- +goose Down
+DELETE FROM metrics WHERE labels <> '';
+ALTER TABLE metrics DROP CONSTRAINT metrics_pkey;
+ALTER TABLE metrics ADD PRIMARY KEY (id, type);
+ALTER TABLE metrics DROP COLUMN labels;
@@ -0,0 +1,6 @@
+-- +goose Up
+ALTER TABLE metrics ADD COLUMN histogram JSONB;
+
+-- +goose Down
+DELETE FROM metrics WHERE type = 'histogram';
+ALTER TABLE metrics DROP COLUMN histogram;
@@ -0,0 +1,6 @@
+-- +goose Up
+ALTER TABLE metrics ADD COLUMN summary JSONB;
+
+-- +goose Down
+DELETE FROM metrics WHERE type = 'summary';
+ALTER TABLE metrics DROP COLUMN summary;
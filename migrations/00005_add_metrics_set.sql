@@ -0,0 +1,6 @@
+-- +goose Up
+ALTER TABLE metrics ADD COLUMN set_sketch BYTEA;
+
+-- +goose Down
+DELETE FROM metrics WHERE type = 'set';
+ALTER TABLE metrics DROP COLUMN set_sketch;